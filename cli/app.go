@@ -1,10 +1,85 @@
 package cli
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
 	envParser "github.com/wojnosystems/go-env/v2"
+	"io"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 )
 
-func Run(cmd Commander) (err error) {
-	return cmd.Switch(os.Args, &envParser.OsEnv{})
+// Run runs cmd with the command line and environment of the process and returns the code to exit with:
+//
+//	func main() {
+//		os.Exit(cli.Run(&commander))
+//	}
+//
+// The context given to the command is canceled when the process receives SIGINT or SIGTERM, a second signal stops the
+// process immediately. Errors are written to stderr, followed by the stack of a cmd_definitions.ErrPanic when its
+// ShowStack is set. See ExitCode for how errors map to exit codes
+func Run(cmd Commander) (exitCode int) {
+	ctx, received, stop := signalContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return run(ctx, cmd, os.Args[1:], &envParser.OsEnv{}, os.Stderr, received)
+}
+
+// run runs cmd with args and env, reporting errors to stderr. received returns the signal that canceled ctx, if any
+func run(ctx context.Context, cmd Commander, args []string, env envParser.EnvReader, stderr io.Writer, received func() os.Signal) (exitCode int) {
+	err := cmd.Switch(ctx, args, env)
+	exitCode = ExitCode(err)
+	if sig := received(); sig != nil {
+		if number, ok := sig.(syscall.Signal); ok {
+			exitCode = exitSignalBase + int(number)
+		}
+		if errors.Is(err, context.Canceled) {
+			// the command stopped because it was asked to, that is not worth reporting
+			return
+		}
+	}
+	var exit *ErrExit
+	if err != nil && !(errors.As(err, &exit) && exit.Err == nil) {
+		_, _ = fmt.Fprintln(stderr, "error: "+err.Error())
+	}
+	var panicked *cmd_definitions.ErrPanic
+	if errors.As(err, &panicked) && panicked.ShowStack {
+		_, _ = stderr.Write(panicked.Stack)
+	}
+	return
+}
+
+// signalContext returns a copy of parent that is canceled when the process receives one of signals. received returns
+// the signal that canceled it, or nil. stop releases the signals, call it once the context is no longer needed
+func signalContext(parent context.Context, signals ...os.Signal) (ctx context.Context, received func() os.Signal, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	var mutex sync.Mutex
+	var receivedSignal os.Signal
+	notify := make(chan os.Signal, 1)
+	signal.Notify(notify, signals...)
+	go func() {
+		select {
+		case sig := <-notify:
+			mutex.Lock()
+			receivedSignal = sig
+			mutex.Unlock()
+			// let another signal stop the process, in case the command does not stop
+			signal.Stop(notify)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	received = func() os.Signal {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return receivedSignal
+	}
+	stop = func() {
+		signal.Stop(notify)
+		cancel()
+	}
+	return
 }
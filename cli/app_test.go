@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	envParser "github.com/wojnosystems/go-env/v2"
+	"github.com/wojnosystems/go-optional/v2"
+	"github.com/wojnosystems/okey-dokey/bad"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type commanderFunc func(ctx context.Context, args []string, receiver envParser.EnvReader) error
+
+func (f commanderFunc) Switch(ctx context.Context, args []string, receiver envParser.EnvReader) error {
+	return f(ctx, args, receiver)
+}
+
+func TestRun(t *testing.T) {
+	problems := bad.NewCollection()
+	problems.Into("port").Emit("must be at least 1")
+	cases := map[string]struct {
+		err            error
+		signal         os.Signal
+		expectedCode   int
+		expectedStderr string
+	}{
+		"ok": {
+			expectedCode: ExitOK,
+		},
+		"failure": {
+			err:            errors.New("boom"),
+			expectedCode:   ExitFailure,
+			expectedStderr: "error: boom\n",
+		},
+		"usage": {
+			err:            &ErrUsage{Reason: `unknown command "strat"`},
+			expectedCode:   ExitUsage,
+			expectedStderr: "error: unknown command \"strat\"\n",
+		},
+		"flag out of scope": {
+			err:            &parse.ErrFlagOutOfScope{Flag: "--secret", Path: []string{"server"}},
+			expectedCode:   ExitUsage,
+			expectedStderr: "error: flag --secret is not accepted by \"server\", only by the root command\n",
+		},
+		"validation": {
+			err:            &ErrValidation{Problems: problems},
+			expectedCode:   ExitValidation,
+			expectedStderr: "error: invalid options:\nport: must be at least 1\n",
+		},
+		"unimplemented": {
+			err:            fmt.Errorf("server start: %w", ErrCommandUnimplemented),
+			expectedCode:   ExitUnimplemented,
+			expectedStderr: "error: server start: command was declared, but not implemented\n",
+		},
+		"exit code": {
+			err:            NewErrExit(3, "not ready"),
+			expectedCode:   3,
+			expectedStderr: "error: not ready\n",
+		},
+		"exit code without error": {
+			err:          &ErrExit{Code: 4},
+			expectedCode: 4,
+		},
+		"panic": {
+			err:            &cmd_definitions.ErrPanic{Path: []string{"server", "start"}, Value: "oops", Stack: []byte("stack\n")},
+			expectedCode:   ExitFailure,
+			expectedStderr: "error: command \"server start\" panicked: oops\n",
+		},
+		"panic with stack": {
+			err:            &cmd_definitions.ErrPanic{Path: []string{"server", "start"}, Value: "oops", Stack: []byte("stack\n"), ShowStack: true},
+			expectedCode:   ExitFailure,
+			expectedStderr: "error: command \"server start\" panicked: oops\nstack\n",
+		},
+		"interrupted": {
+			err:          context.Canceled,
+			signal:       os.Interrupt,
+			expectedCode: 130,
+		},
+		"terminated with error": {
+			err:            errors.New("unable to stop cleanly"),
+			signal:         syscall.SIGTERM,
+			expectedCode:   143,
+			expectedStderr: "error: unable to stop cleanly\n",
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			var actualArgs []string
+			cmd := commanderFunc(func(_ context.Context, args []string, _ envParser.EnvReader) error {
+				actualArgs = args
+				return c.err
+			})
+			stderr := &bytes.Buffer{}
+			actualCode := run(context.Background(), cmd, []string{"server", "start"}, &envParser.OsEnv{}, stderr, func() os.Signal {
+				return c.signal
+			})
+			assert.Equal(t, []string{"server", "start"}, actualArgs)
+			assert.Equal(t, c.expectedCode, actualCode)
+			assert.Equal(t, c.expectedStderr, stderr.String())
+		})
+	}
+}
+
+type runStartOptions struct {
+	Port optional.Int `flag:"port"`
+}
+
+// parsingCommander only parses args, with the options of "server start" required
+func parsingCommander() Commander {
+	start := dsl.Command{
+		MaxArgs: 1,
+		Options: []dsl.OptionOrReference{
+			{Option: dsl.Option{Name: "Port", Type: "int", Required: true, Flag: dsl.FlagDef{Name: "port"}}},
+		},
+	}
+	service := &cmd_definitions.ServiceDesc{
+		Root: cmd_definitions.MethodDesc{
+			Meta: dsl.Command{Commands: dsl.NamedCommands{"server": {Commands: dsl.NamedCommands{"start": start}}}},
+		},
+	}
+	service.Methods.Put(cmd_definitions.MethodDesc{Meta: service.Root.Meta.Commands["server"]}, "server")
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta: start,
+		ObjectMaker: func() interface{} {
+			return &runStartOptions{}
+		},
+	}, "server", "start")
+	options := &parse.BeforeAndAfter{
+		Precedence: parse.Precedence{
+			parse.GroupFlags(),
+			parse.Ungrouped((&parse.Prompter{}).Required([]dsl.Option{start.Options[0].Option})),
+		},
+	}
+	return commanderFunc(func(_ context.Context, args []string, _ envParser.EnvReader) error {
+		_, err := parse.NewEnvFlagParser(service, options, args).Parse(nil)
+		return err
+	})
+}
+
+func TestRun_ParseErrors(t *testing.T) {
+	cases := map[string]struct {
+		args           []string
+		expectedCode   int
+		expectedStderr string
+	}{
+		"ok": {
+			args:         []string{"server", "start", "--port=80", "a"},
+			expectedCode: ExitOK,
+		},
+		"unknown command": {
+			args:           []string{"server", "strat"},
+			expectedCode:   ExitUsage,
+			expectedStderr: "error: unknown command \"server strat\"\n",
+		},
+		"too many arguments": {
+			args:           []string{"server", "start", "--port=80", "a", "b"},
+			expectedCode:   ExitUsage,
+			expectedStderr: "error: \"server start\" takes 0 to 1 arguments, but was given 2\n",
+		},
+		"invalid value": {
+			args:           []string{"server", "start", "--port", "abc"},
+			expectedCode:   ExitUsage,
+			expectedStderr: "error: flag '--port' failed to parse because strconv.ParseInt: parsing \"abc\": invalid syntax\n",
+		},
+		"unknown flag": {
+			args:           []string{"server", "start", "--host=example.com"},
+			expectedCode:   ExitUsage,
+			expectedStderr: "error: unknown flag --host for \"server start\"\n",
+		},
+		"missing required option": {
+			args:           []string{"server", "start"},
+			expectedCode:   ExitUsage,
+			expectedStderr: "error: missing required options: --port\n",
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			actualCode := run(context.Background(), parsingCommander(), c.args, &envParser.OsEnv{}, stderr, func() os.Signal {
+				return nil
+			})
+			assert.Equal(t, c.expectedCode, actualCode)
+			assert.Equal(t, c.expectedStderr, stderr.String())
+		})
+	}
+}
+
+// TestExitCode checks the codes documented in the README, which scripts calling the application depend on
+func TestExitCode(t *testing.T) {
+	cases := map[string]struct {
+		err          error
+		expectedCode int
+	}{
+		"ok": {
+			expectedCode: 0,
+		},
+		"failure": {
+			err:          errors.New("boom"),
+			expectedCode: 1,
+		},
+		"usage": {
+			err:          &ErrUsage{Reason: "unknown flag"},
+			expectedCode: 2,
+		},
+		"flag out of scope": {
+			err:          fmt.Errorf("server: %w", &parse.ErrFlagOutOfScope{Flag: "--secret", Path: []string{"server"}}),
+			expectedCode: 2,
+		},
+		"unknown flag": {
+			err:          &parse.ErrUnknownFlag{Flag: "--no-host", Path: []string{"server"}},
+			expectedCode: 2,
+		},
+		"flag without its value": {
+			err:          &parse.ErrFlagNeedsValue{Flag: "--host"},
+			expectedCode: 2,
+		},
+		"validation": {
+			err:          &ErrValidation{Problems: bad.NewCollection()},
+			expectedCode: 64,
+		},
+		"unimplemented": {
+			err:          ErrCommandUnimplemented,
+			expectedCode: 70,
+		},
+		"exit code": {
+			err:          &ErrExit{Code: 3},
+			expectedCode: 3,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			assert.Equal(t, c.expectedCode, ExitCode(c.err))
+		})
+	}
+}
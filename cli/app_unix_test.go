@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package cli
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSignalContext(t *testing.T) {
+	ctx, received, stop := signalContext(context.Background(), syscall.SIGUSR1)
+	defer stop()
+	assert.Nil(t, received())
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	<-ctx.Done()
+	assert.Equal(t, syscall.SIGUSR1, received())
+}
@@ -0,0 +1,235 @@
+package clitest
+
+import (
+	"fmt"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/generate/goland"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const helpFlag = "--help"
+
+// sampleValues are valid values for each option type
+var sampleValues = map[string]string{
+	"int":      "7",
+	"uint":     "7",
+	"int8":     "7",
+	"int16":    "7",
+	"int32":    "7",
+	"int64":    "7",
+	"uint8":    "7",
+	"uint16":   "7",
+	"uint32":   "7",
+	"uint64":   "7",
+	"byte":     "7",
+	"rune":     "7",
+	"string":   "sample",
+	"float32":  "1.5",
+	"float64":  "1.5",
+	"bool":     "true",
+	"duration": "5s",
+	"time":     "2006-01-02T15:04:05Z",
+	"count":    "7",
+}
+
+// Case is a command line generated from a specification, along with what a Commander must do when it is run
+type Case struct {
+	Name string
+	// Args are the words after the program name
+	Args []string
+	Env  Env
+	// Method is the command method that must be called, or blank when no method may be called
+	Method string
+	// ExpectErr is true when the Commander must return an error. Only checked when Method is blank
+	ExpectErr bool
+	// Expected holds the value that each option, by name, must have when Method is called, printed with fmt.Sprint
+	Expected map[string]string
+}
+
+// Cases generates the cases for every command in document that has no sub-commands:
+//   - the command given its required options and minArgs arguments calls its method, with defaults applied
+//   - each alias of the command calls the same method
+//   - required options may be given by their environment variable instead
+//   - leaving out a required option, passing fewer than minArgs or more than maxArgs arguments fails
+//   - --help calls no method
+//
+// Required options that have neither a flag nor an environment variable cannot be given, so the commands declaring
+// them only get the --help case
+func Cases(document *dsl.Document) (out []Case) {
+	_ = document.Commands.Walk(func(path []string, command dsl.Command) error {
+		if !command.Commands.HasAny() {
+			out = append(out, commandCases(document, path, command)...)
+		}
+		return nil
+	})
+	return
+}
+
+func commandCases(document *dsl.Document, path []string, command dsl.Command) (out []Case) {
+	name := strings.Join(path, " ")
+	method := goland.MethodName(path)
+	out = append(out, Case{
+		Name: name + " " + helpFlag,
+		Args: append(copyStrings(path), helpFlag),
+	})
+
+	accepted, _ := document.AcceptedOptions(path)
+	valid := Case{
+		Name:     name,
+		Args:     copyStrings(path),
+		Env:      Env{},
+		Method:   method,
+		Expected: make(map[string]string),
+	}
+	var required []dsl.Option
+	for _, option := range accepted {
+		option.Default.IfSet(func(value string) {
+			if printed, ok := printedOption(option, value); ok {
+				valid.Expected[option.Name] = printed
+			}
+		})
+		if !option.Required {
+			continue
+		}
+		value, ok := sampleValues[option.Type]
+		if !ok {
+			return
+		}
+		switch {
+		case len(option.Flag.Names()) != 0:
+			valid.Args = append(valid.Args, flagArg(option, value))
+		case option.Env.Name != "":
+			valid.Env[envName(option)] = value
+		default:
+			return
+		}
+		if printed, ok := printedOption(option, value); ok {
+			valid.Expected[option.Name] = printed
+		}
+		required = append(required, option)
+	}
+	arguments := make([]string, 0, command.MaxArgs+1)
+	for i := uint(0); i <= command.MaxArgs; i++ {
+		arguments = append(arguments, fmt.Sprintf("arg%d", i+1))
+	}
+	valid.Args = append(valid.Args, arguments[:command.MinArgs]...)
+	out = append(out, valid)
+
+	for _, alias := range command.Aliases {
+		aliased := valid
+		aliased.Name = name + " as " + alias
+		aliased.Args = append(copyStrings(valid.Args[:len(path)-1]), alias)
+		aliased.Args = append(aliased.Args, valid.Args[len(path):]...)
+		out = append(out, aliased)
+	}
+
+	for _, option := range required {
+		if option.Env.Name != "" && len(option.Flag.Names()) != 0 {
+			fromEnv := valid
+			fromEnv.Name = name + " with " + option.Env.Name
+			fromEnv.Args = withoutArg(valid.Args, flagArg(option, sampleValues[option.Type]))
+			fromEnv.Env = Env{envName(option): sampleValues[option.Type]}
+			out = append(out, fromEnv)
+		}
+		missing := Case{
+			Name:      name + " without " + option.Name,
+			Args:      withoutArg(valid.Args, flagArg(option, sampleValues[option.Type])),
+			Env:       Env{},
+			ExpectErr: true,
+		}
+		for name, value := range valid.Env {
+			if name != envName(option) {
+				missing.Env[name] = value
+			}
+		}
+		out = append(out, missing)
+	}
+
+	if command.MinArgs != 0 {
+		out = append(out, Case{
+			Name:      fmt.Sprintf("%s with fewer than %d arguments", name, command.MinArgs),
+			Args:      append(copyStrings(valid.Args[:len(valid.Args)-int(command.MinArgs)]), arguments[:command.MinArgs-1]...),
+			Env:       valid.Env,
+			ExpectErr: true,
+		})
+	}
+	out = append(out, Case{
+		Name:      fmt.Sprintf("%s with more than %d arguments", name, command.MaxArgs),
+		Args:      append(copyStrings(valid.Args[:len(valid.Args)-int(command.MinArgs)]), arguments...),
+		Env:       valid.Env,
+		ExpectErr: true,
+	})
+	return
+}
+
+// printedOption is value, given to option, as fmt.Sprint prints it once it is parsed. A value given to a list is its
+// only item
+func printedOption(option dsl.Option, value string) (printed string, ok bool) {
+	printed, ok = printedValue(option.Type, value)
+	if ok && option.List {
+		printed = "[" + printed + "]"
+	}
+	return
+}
+
+// printedValue is value, of the option type optionType, as fmt.Sprint prints it once it is parsed. ok is false for
+// types whose printed form is not checked, or for values that do not parse
+func printedValue(optionType, value string) (printed string, ok bool) {
+	var parsed interface{}
+	var err error
+	switch optionType {
+	case "string":
+		parsed = value
+	case "bool":
+		parsed, err = strconv.ParseBool(value)
+	case "float32", "float64":
+		parsed, err = strconv.ParseFloat(value, 64)
+	case "duration":
+		parsed, err = time.ParseDuration(value)
+	case "time":
+		return
+	default:
+		if strings.HasPrefix(optionType, "uint") || optionType == "byte" {
+			parsed, err = strconv.ParseUint(value, 0, 64)
+		} else {
+			parsed, err = strconv.ParseInt(value, 0, 64)
+		}
+	}
+	if err != nil {
+		return
+	}
+	return fmt.Sprint(parsed), true
+}
+
+// flagArg is how option is given value on the command line: --name=value, or just --name for bools
+func flagArg(option dsl.Option, value string) string {
+	name := option.Flag.Names()[0]
+	if option.Type == "bool" {
+		return name
+	}
+	return name + "=" + value
+}
+
+// envName is the environment variable that gives option a value. Lists are given their items one variable each,
+// starting with NAME_0
+func envName(option dsl.Option) string {
+	if option.List {
+		return option.Env.Name + "_0"
+	}
+	return option.Env.Name
+}
+
+func withoutArg(args []string, arg string) (out []string) {
+	for _, a := range args {
+		if a != arg {
+			out = append(out, a)
+		}
+	}
+	return
+}
+
+func copyStrings(s []string) []string {
+	return append(make([]string, 0, len(s)), s...)
+}
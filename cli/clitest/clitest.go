@@ -0,0 +1,171 @@
+// Package clitest checks that a generated CLI behaves as its optionapi specification says it should. It runs every
+// command with arguments and environment variables generated from the specification, and checks which method of the
+// application is called, with which options, and which command lines fail.
+//
+// Commanders report the method they call with cli.NotifyDispatch, which is how the calls are observed
+package clitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/cli"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/generate/goland"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Dispatch is a command method that a Commander called
+type Dispatch struct {
+	Method string
+	// Options is the options struct the method was called with, nil when the method has none
+	Options interface{}
+}
+
+// Run runs commander with args, the words after the program name, and env, returning every command method it called
+func Run(commander cli.Commander, args []string, env Env) (dispatched []Dispatch, err error) {
+	ctx := cli.WithDispatchObserver(context.Background(), func(method string, options interface{}) {
+		dispatched = append(dispatched, Dispatch{Method: method, Options: options})
+	})
+	if env == nil {
+		env = Env{}
+	}
+	err = commander.Switch(ctx, args, env)
+	return
+}
+
+// TestRun checks that app implements every method generated for document, then runs every case from Cases against
+// commander, which must call methods on app. Each check is a sub-test of t
+func TestRun(t *testing.T, document *dsl.Document, app interface{}, commander cli.Commander) {
+	t.Run("implementation", func(t *testing.T) {
+		CheckImplementation(t, document, app)
+	})
+	for _, c := range Cases(document) {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			c.Check(t, commander)
+		})
+	}
+}
+
+// Check runs commander with the arguments and environment of the case and checks what it did
+func (c Case) Check(t *testing.T, commander cli.Commander) {
+	t.Helper()
+	dispatched, err := Run(commander, c.Args, c.Env)
+	if c.Method == "" {
+		assert.Empty(t, dispatched, "no command method should be called for %s", strings.Join(c.Args, " "))
+		if c.ExpectErr {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		return
+	}
+	if err != nil && !errors.Is(err, cli.ErrCommandUnimplemented) {
+		assert.NoError(t, err)
+	}
+	require.Len(t, dispatched, 1, "exactly one command method should be called for %s", strings.Join(c.Args, " "))
+	assert.Equal(t, c.Method, dispatched[0].Method)
+	for name, expected := range c.Expected {
+		actual, ok := optionValue(dispatched[0].Options, name)
+		if !ok {
+			continue
+		}
+		assert.Equal(t, expected, actual, "option %s", name)
+	}
+}
+
+// CheckImplementation checks that app has the hooks and command methods generated for document, and that the options
+// structs they take have a field of the generated type for every option the command declares
+func CheckImplementation(t *testing.T, document *dsl.Document, app interface{}) {
+	t.Helper()
+	appType := reflect.TypeOf(app)
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	_ = document.Commands.Walk(func(path []string, command dsl.Command) error {
+		methodName := goland.MethodName(path)
+		methodNames := []string{methodName}
+		if command.Commands.HasAny() {
+			methodNames = []string{methodName + "HookBefore", methodName + "HookAfter"}
+		}
+		for _, name := range methodNames {
+			method, ok := appType.MethodByName(name)
+			if !assert.True(t, ok, "%s is missing method %s", appType, name) {
+				continue
+			}
+			// the receiver is the first input
+			in := method.Type.NumIn()
+			if !assert.True(t, in >= 2 && method.Type.In(1) == contextType, "%s must take a context.Context first", name) {
+				continue
+			}
+			assert.True(t, method.Type.NumOut() == 1 && method.Type.Out(0) == errorType, "%s must return only an error", name)
+			if command.Commands.HasAny() || in < 3 {
+				continue
+			}
+			checkOptionFields(t, name, method.Type.In(2), command)
+		}
+		return nil
+	})
+}
+
+func checkOptionFields(t *testing.T, methodName string, optionsType reflect.Type, command dsl.Command) {
+	t.Helper()
+	if !assert.Equal(t, reflect.Ptr, optionsType.Kind(), "%s must take a pointer to its options", methodName) {
+		return
+	}
+	for _, reference := range command.Options {
+		option := reference.Option
+		expected, err := goland.FieldType(option)
+		if err != nil {
+			continue
+		}
+		field, ok := optionsType.Elem().FieldByName(option.Name)
+		if !assert.True(t, ok, "options of %s are missing field %s", methodName, option.Name) {
+			continue
+		}
+		assert.Equal(t, normalizeTypeName(expected), field.Type.String(), "type of %s option %s", methodName, option.Name)
+	}
+}
+
+// normalizeTypeName spells the generated type the way reflect does, which uses the names byte and rune alias
+func normalizeTypeName(goType string) string {
+	switch goType {
+	case "byte":
+		return "uint8"
+	case "rune":
+		return "int32"
+	}
+	return goType
+}
+
+// optionValue prints the value of the field called name in options, or in the structs options embeds. ok is false when
+// there is no such field or the value cannot be read
+func optionValue(options interface{}, name string) (value string, ok bool) {
+	v := reflect.ValueOf(options)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	field := v.FieldByName(name)
+	if !field.IsValid() || !field.CanInterface() {
+		return
+	}
+	if field.MethodByName("IsSet").IsValid() && field.MethodByName("IfSet").IsValid() {
+		var isSet bool
+		isSet, field = parse.OptionalValue(field)
+		if !isSet {
+			return "", true
+		}
+	}
+	return fmt.Sprint(field.Interface()), true
+}
@@ -0,0 +1,247 @@
+package clitest
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wojnosystems/flick/cli"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	env_parser "github.com/wojnosystems/go-env/v2"
+	"github.com/wojnosystems/go-optional/v2"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var testDocument = dsl.Document{
+	Commands: dsl.NamedCommands{
+		"server": dsl.Command{
+			Commands: dsl.NamedCommands{
+				"start": dsl.Command{
+					Aliases: []string{"up"},
+					MinArgs: 1,
+					MaxArgs: 2,
+					Options: []dsl.OptionOrReference{
+						{Option: dsl.Option{Name: "Port", Type: "int", Required: true, Flag: dsl.FlagDef{Name: "port"}, Env: dsl.EnvDef{Name: "PORT"}}},
+						{Option: dsl.Option{Name: "Host", Type: "string", Default: optional.StringFrom("localhost"), Flag: dsl.FlagDef{Name: "host"}}},
+						{Option: dsl.Option{Name: "Verbose", Type: "bool", Flag: dsl.FlagDef{Name: "verbose"}}},
+					},
+				},
+			},
+		},
+		"version": dsl.Command{},
+	},
+}
+
+type ServerStartOptions struct {
+	Port    int           `flag:"port" env:"PORT"`
+	Host    string        `flag:"host"`
+	Verbose optional.Bool `flag:"verbose"`
+}
+
+type testApp struct{}
+
+func (testApp) ServerHookBefore(_ context.Context) error {
+	return nil
+}
+
+func (testApp) ServerHookAfter(_ context.Context, err error) error {
+	return err
+}
+
+func (testApp) ServerStart(_ context.Context, _ *ServerStartOptions) error {
+	return cli.ErrCommandUnimplemented
+}
+
+func (testApp) Version(_ context.Context) error {
+	return nil
+}
+
+// testCommander is written by hand the way a generated Commander for testDocument behaves
+type testCommander struct {
+	app testApp
+}
+
+func (c testCommander) Switch(ctx context.Context, args []string, env env_parser.EnvReader) (err error) {
+	if len(args) == 2 && args[0] == "version" && args[1] == helpFlag {
+		return nil
+	}
+	if len(args) == 1 && args[0] == "version" {
+		cli.NotifyDispatch(ctx, "Version", nil)
+		return c.app.Version(ctx)
+	}
+	if len(args) < 2 || args[0] != "server" || (args[1] != "start" && args[1] != "up") {
+		return errors.New("unknown command")
+	}
+	opts := ServerStartOptions{Host: "localhost"}
+	if port := env.Get("PORT"); port != "" {
+		opts.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return
+		}
+	}
+	portSet := opts.Port != 0
+	var positional []string
+	for _, arg := range args[2:] {
+		switch {
+		case arg == helpFlag:
+			return nil
+		case strings.HasPrefix(arg, "--port="):
+			opts.Port, err = strconv.Atoi(strings.TrimPrefix(arg, "--port="))
+			if err != nil {
+				return
+			}
+			portSet = true
+		case strings.HasPrefix(arg, "--host="):
+			opts.Host = strings.TrimPrefix(arg, "--host=")
+		case arg == "--verbose":
+			opts.Verbose = optional.BoolFrom(true)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if !portSet {
+		return errors.New("port is required")
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return errors.New("start takes 1 or 2 arguments")
+	}
+	cli.NotifyDispatch(ctx, "ServerStart", &opts)
+	return c.app.ServerStart(ctx, &opts)
+}
+
+// dispatcher runs testDocument with a cli.Dispatcher, the way a generated Commander does
+func dispatcher(app testApp) *cli.Dispatcher {
+	server := testDocument.Commands["server"]
+	service := &cmd_definitions.ServiceDesc{
+		Root: cmd_definitions.MethodDesc{Meta: dsl.Command{Commands: testDocument.Commands}},
+	}
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta:       server,
+		HookBefore: app.ServerHookBefore,
+		HookAfter:  app.ServerHookAfter,
+	}, "server")
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta: server.Commands["start"],
+		Handler: func(ctx context.Context) error {
+			invocation, _ := cmd_definitions.InvocationFrom(ctx)
+			return app.ServerStart(ctx, invocation.Options.(*ServerStartOptions))
+		},
+		ObjectMaker: func() interface{} {
+			return &ServerStartOptions{Host: "localhost"}
+		},
+	}, "server", "start")
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta:    testDocument.Commands["version"],
+		Handler: app.Version,
+	}, "version")
+	return &cli.Dispatcher{Service: service}
+}
+
+func TestTestRun(t *testing.T) {
+	TestRun(t, &testDocument, testApp{}, testCommander{})
+}
+
+func TestTestRun_Dispatcher(t *testing.T) {
+	TestRun(t, &testDocument, testApp{}, dispatcher(testApp{}))
+}
+
+func TestCases(t *testing.T) {
+	cases := Cases(&testDocument)
+	actual := make(map[string]Case, len(cases))
+	for _, c := range cases {
+		actual[c.Name] = c
+	}
+	expected := map[string]Case{
+		"server start --help": {
+			Name: "server start --help",
+			Args: []string{"server", "start", "--help"},
+		},
+		"server start": {
+			Name:     "server start",
+			Args:     []string{"server", "start", "--port=7", "arg1"},
+			Env:      Env{},
+			Method:   "ServerStart",
+			Expected: map[string]string{"Port": "7", "Host": "localhost"},
+		},
+		"server start as up": {
+			Name:     "server start as up",
+			Args:     []string{"server", "up", "--port=7", "arg1"},
+			Env:      Env{},
+			Method:   "ServerStart",
+			Expected: map[string]string{"Port": "7", "Host": "localhost"},
+		},
+		"server start with PORT": {
+			Name:     "server start with PORT",
+			Args:     []string{"server", "start", "arg1"},
+			Env:      Env{"PORT": "7"},
+			Method:   "ServerStart",
+			Expected: map[string]string{"Port": "7", "Host": "localhost"},
+		},
+		"server start without Port": {
+			Name:      "server start without Port",
+			Args:      []string{"server", "start", "arg1"},
+			Env:       Env{},
+			ExpectErr: true,
+		},
+		"server start with fewer than 1 arguments": {
+			Name:      "server start with fewer than 1 arguments",
+			Args:      []string{"server", "start", "--port=7"},
+			Env:       Env{},
+			ExpectErr: true,
+		},
+		"server start with more than 2 arguments": {
+			Name:      "server start with more than 2 arguments",
+			Args:      []string{"server", "start", "--port=7", "arg1", "arg2", "arg3"},
+			Env:       Env{},
+			ExpectErr: true,
+		},
+		"version --help": {
+			Name: "version --help",
+			Args: []string{"version", "--help"},
+		},
+		"version": {
+			Name:     "version",
+			Args:     []string{"version"},
+			Env:      Env{},
+			Method:   "Version",
+			Expected: map[string]string{},
+		},
+		"version with more than 0 arguments": {
+			Name:      "version with more than 0 arguments",
+			Args:      []string{"version", "arg1"},
+			Env:       Env{},
+			ExpectErr: true,
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestRun_ReportsDispatches(t *testing.T) {
+	cases := map[string]struct {
+		args     []string
+		env      Env
+		expected []Dispatch
+	}{
+		"command": {
+			args: []string{"server", "start", "--port=80", "--verbose", "a"},
+			expected: []Dispatch{
+				{Method: "ServerStart", Options: &ServerStartOptions{Port: 80, Host: "localhost", Verbose: optional.BoolFrom(true)}},
+			},
+		},
+		"without options": {
+			args:     []string{"version"},
+			expected: []Dispatch{{Method: "Version"}},
+		},
+		"help": {
+			args: []string{"server", "start", "--help"},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual, _ := Run(testCommander{}, c.args, c.env)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package clitest
+
+import (
+	envParser "github.com/wojnosystems/go-env/v2"
+	"sort"
+)
+
+// Env is an env_parser.EnvReader for tests, it holds environment variable values by name
+type Env map[string]string
+
+// Get the value of the variable envNamed, or blank when it is not set
+func (e Env) Get(envNamed string) string {
+	return e[envNamed]
+}
+
+// Keys lists the names of the variables that begin with prefix, in order
+func (e Env) Keys(prefix string) []string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return envParser.SelectKeysWithPrefix(keys, prefix)
+}
@@ -5,6 +5,10 @@ import (
 	env_parser "github.com/wojnosystems/go-env/v2"
 )
 
+// Commander runs the command that args select
 type Commander interface {
+	// Switch runs the command in args, which is the command line without the program name, os.Args[1:]. Return
+	// ErrUsage for malformed command lines and ErrValidation for unacceptable options, so that Run exits with the
+	// right code
 	Switch(ctx context.Context, args []string, receiver env_parser.EnvReader) (err error)
 }
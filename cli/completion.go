@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/pkg/generate/completion"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	env_parser "github.com/wojnosystems/go-env/v2"
+	"io"
+)
+
+type completingCommander struct {
+	next     Commander
+	document *dsl.Document
+	values   completion.ValueCompleter
+	stdout   io.Writer
+}
+
+// WithCompletion adds the hidden __complete command, which the generated completion scripts call to complete option
+// values, to cmd. Candidates are written to stdout one per line, every other command line is given to cmd. values
+// suggests the values of options with neither an enum nor a file path, generated Interfaces include its CompleteValue
+// method when document has such options. values may be nil:
+//
+//	os.Exit(cli.Run(cli.WithCompletion(&commander, &document, &commander, os.Stdout)))
+func WithCompletion(cmd Commander, document *dsl.Document, values completion.ValueCompleter, stdout io.Writer) Commander {
+	return &completingCommander{
+		next:     cmd,
+		document: document,
+		values:   values,
+		stdout:   stdout,
+	}
+}
+
+func (c *completingCommander) Switch(ctx context.Context, args []string, receiver env_parser.EnvReader) (err error) {
+	if len(args) == 0 || args[0] != completion.CommandName {
+		return c.next.Switch(ctx, args, receiver)
+	}
+	return completion.Complete(ctx, c.document, args[1:], c.values, c.stdout)
+}
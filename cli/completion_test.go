@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	envParser "github.com/wojnosystems/go-env/v2"
+	"testing"
+)
+
+type valuesFunc func(commandPath []string, option dsl.Option, partial string) []string
+
+func (f valuesFunc) CompleteValue(_ context.Context, commandPath []string, option dsl.Option, partial string) []string {
+	return f(commandPath, option, partial)
+}
+
+func TestWithCompletion(t *testing.T) {
+	document := dsl.Document{
+		Commands: dsl.NamedCommands{
+			"start": dsl.Command{
+				Options: []dsl.OptionOrReference{
+					{Option: dsl.Option{Name: "Name", Type: "string", Flag: dsl.FlagDef{Name: "name"}}},
+				},
+			},
+		},
+	}
+	values := valuesFunc(func(commandPath []string, option dsl.Option, partial string) []string {
+		return []string{"alice", "bob"}
+	})
+	cases := map[string]struct {
+		args           []string
+		expectedStdout string
+		expectedRun    bool
+	}{
+		"flag value": {
+			args:           []string{"__complete", "start", "--name", "b"},
+			expectedStdout: "bob\n",
+		},
+		"flag value after equals": {
+			args:           []string{"__complete", "start", "--name="},
+			expectedStdout: "alice\nbob\n",
+		},
+		"other commands": {
+			args:        []string{"start", "--name", "bob"},
+			expectedRun: true,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			ran := false
+			cmd := commanderFunc(func(ctx context.Context, args []string, receiver envParser.EnvReader) error {
+				ran = true
+				return nil
+			})
+			stdout := &bytes.Buffer{}
+			err := WithCompletion(cmd, &document, values, stdout).Switch(context.Background(), c.args, &envParser.OsEnv{})
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedStdout, stdout.String())
+			assert.Equal(t, c.expectedRun, ran)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	env_parser "github.com/wojnosystems/go-env/v2"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"io"
+)
+
+// WarnDeprecated writes a warning to stderr for every deprecated command, flag and environment variable in document
+// that args, os.Args[1:], and env use. args are split the way the parser splits them, document.TakesValue telling which
+// flags take a value. Deprecated flags and environment variables are also noted in sources, so that the trace shows
+// them. sources may be nil, call this after the options are unmarshalled so that sources are recorded
+func WarnDeprecated(stderr io.Writer, document *dsl.Document, args []string, env env_parser.EnvReader, sources parse.Sources) {
+	for _, deprecation := range document.Deprecations(splitArgs(document, args), env.Get) {
+		_, _ = fmt.Fprintln(stderr, "warning: "+deprecation.String())
+		switch deprecation.Kind {
+		case dsl.DeprecatedFlag:
+			sources.Deprecated(parse.FlagSource(deprecation.Name), deprecation.Message)
+		case dsl.DeprecatedEnv:
+			sources.Deprecated(parse.EnvSource(deprecation.Name), deprecation.Message)
+		}
+	}
+}
+
+// splitArgs splits args with parse.SplitGNU, the words after "--" are arguments and are left out. A flag missing its
+// value is left for the parser to report, the flags before it are still checked
+func splitArgs(document *dsl.Document, args []string) []flag_unmarshaler.Group {
+	groups, _, _ := parse.SplitGNU(args, document.TakesValue)
+	return groups
+}
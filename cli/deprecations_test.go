@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	envParser "github.com/wojnosystems/go-env/v2"
+	"testing"
+)
+
+func TestWarnDeprecated(t *testing.T) {
+	document := dsl.Document{
+		Options: []dsl.OptionOrReference{
+			{Option: dsl.Option{
+				Name:       "host",
+				Type:       "string",
+				Flag:       dsl.FlagDef{Name: "host", Aliases: []string{"H"}},
+				Deprecated: "use --address instead",
+			}},
+			{Option: dsl.Option{
+				Name:       "color",
+				Type:       "bool",
+				Flag:       dsl.FlagDef{Name: "color"},
+				Deprecated: "colors are detected",
+			}},
+		},
+		Commands: dsl.NamedCommands{
+			"remove": dsl.Command{
+				Aliases:    []string{"rm"},
+				Deprecated: "use delete instead",
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		args            []string
+		sources         parse.Sources
+		expectedStderr  string
+		expectedSources parse.Sources
+	}{
+		"value after the flag": {
+			args:    []string{"-H", "example.com", "rm"},
+			sources: parse.Sources{"host": "flag:-H"},
+			expectedStderr: `warning: flag "-H" is deprecated: use --address instead
+warning: command "rm" is deprecated: use delete instead
+`,
+			expectedSources: parse.Sources{
+				"host": "flag:-H (deprecated: use --address instead)",
+			},
+		},
+		"negated switch": {
+			args:    []string{"--no-color", "rm"},
+			sources: parse.Sources{},
+			expectedStderr: `warning: flag "--no-color" is deprecated: colors are detected
+warning: command "rm" is deprecated: use delete instead
+`,
+			expectedSources: parse.Sources{},
+		},
+		"after the end of the flags": {
+			args:            []string{"remove", "--", "--host"},
+			sources:         parse.Sources{},
+			expectedStderr:  "warning: command \"remove\" is deprecated: use delete instead\n",
+			expectedSources: parse.Sources{},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			WarnDeprecated(stderr, &document, c.args, &envParser.OsEnv{}, c.sources)
+			assert.Equal(t, c.expectedStderr, stderr.String())
+			assert.Equal(t, c.expectedSources, c.sources)
+		})
+	}
+}
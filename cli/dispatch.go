@@ -0,0 +1,22 @@
+package cli
+
+import "context"
+
+type dispatchObserverKey struct{}
+
+// DispatchObserver is told about every command method a Commander calls, such as "ServerStart", along with the options
+// parsed for it. options is nil for commands without options
+type DispatchObserver func(method string, options interface{})
+
+// WithDispatchObserver returns a copy of ctx that has Commanders report every command method they call to observer
+func WithDispatchObserver(ctx context.Context, observer DispatchObserver) context.Context {
+	return context.WithValue(ctx, dispatchObserverKey{}, observer)
+}
+
+// NotifyDispatch reports method and its options to the observer in ctx, if there is one. Commanders call this just
+// before calling the method of a command
+func NotifyDispatch(ctx context.Context, method string, options interface{}) {
+	if observer, ok := ctx.Value(dispatchObserverKey{}).(DispatchObserver); ok {
+		observer(method, options)
+	}
+}
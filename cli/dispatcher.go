@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/generate/goland"
+	env_parser "github.com/wojnosystems/go-env/v2"
+	"strings"
+)
+
+const helpFlag = "--help"
+
+// Dispatcher is the Commander of a service. It parses the command line with a parse.EnvFlagParser, which finds
+// commands by their name or alias, loads the options from the EnvReader given to Switch, then runs the command with
+// Service.Handler, so that its hooks and middlewares run, and panics are recovered as a cmd_definitions.ErrPanic. The
+// command method is reported with NotifyDispatch, by the name goland.MethodName gives it, before it runs:
+//
+//	os.Exit(cli.Run(&cli.Dispatcher{Service: &service}))
+type Dispatcher struct {
+	Service *cmd_definitions.ServiceDesc
+	// Options loads the options of the root and of each command from env. It may be nil, in which case options are read
+	// from env, then from the flags
+	Options func(env env_parser.EnvReader) parse.OptionUnmarshaler
+	// Prompter asks for the required options that no source set, see parse.Prompter.Required. It may be nil, in which
+	// case they are reported as a parse.ErrMissingRequired
+	Prompter *parse.Prompter
+	// Help runs instead of the command when --help is given, with the path of the command it was given to, such as to
+	// write the reference page of that command. It may be nil
+	Help func(ctx context.Context, path []string) error
+}
+
+func (d *Dispatcher) Switch(ctx context.Context, args []string, receiver env_parser.EnvReader) (err error) {
+	var found []string
+	exec, err := parse.NewEnvFlagParser(d.Service, d.options(receiver), args).Parse(func(path []string) {
+		if _, ok := d.Service.Methods.Get(path...); ok {
+			found = append(found[:0], path...)
+		}
+	})
+	if helpRequested(args) {
+		if d.Help == nil {
+			return nil
+		}
+		return d.Help(ctx, found)
+	}
+	if err != nil {
+		return
+	}
+	err = d.promptRequired(exec)
+	if err != nil {
+		return
+	}
+	path := exec.Path()
+	configObjects := exec.Options()
+	options := configObjects[len(configObjects)-1]
+	handler, ok := d.Service.Handler(path, options)
+	if !ok {
+		return missingCommand(path)
+	}
+	NotifyDispatch(ctx, goland.MethodName(path), options)
+	return handler(ctx)
+}
+
+func (d *Dispatcher) options(env env_parser.EnvReader) parse.OptionUnmarshaler {
+	if d.Options != nil {
+		return d.Options(env)
+	}
+	return &parse.BeforeAndAfter{
+		Precedence: parse.Precedence{
+			parse.Ungrouped(parse.EnvFrom(env)),
+			parse.GroupFlags(),
+		},
+	}
+}
+
+// promptRequired asks for the required options of the root, and of each command on the path, that no source set
+func (d *Dispatcher) promptRequired(exec parse.Exec) (err error) {
+	prompter := d.Prompter
+	if prompter == nil {
+		prompter = &parse.Prompter{}
+	}
+	for i, config := range exec.Options() {
+		if config == nil {
+			continue
+		}
+		method := d.Service.Root
+		if i != 0 {
+			method, _ = d.Service.Methods.Get(exec.Path()[:i]...)
+		}
+		options := make([]dsl.Option, 0, len(method.Meta.Options))
+		for _, option := range method.Meta.Options {
+			options = append(options, option.Option)
+		}
+		err = prompter.Required(options).Unmarshal(config)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// helpRequested is true when --help is given before "--"
+func helpRequested(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == helpFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// missingCommand is the error for a path that has no method of its own, which is a command that only groups its
+// sub-commands
+func missingCommand(path []string) error {
+	if len(path) == 0 {
+		return &ErrUsage{Reason: "a command is required"}
+	}
+	return &ErrUsage{Reason: `"` + strings.Join(path, " ") + `" requires one of its sub-commands`}
+}
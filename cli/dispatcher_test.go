@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/go-optional/v2"
+	"os"
+	"strings"
+	"testing"
+)
+
+type dispatchStartOptions struct {
+	Port int    `flag:"port" env:"PORT"`
+	Host string `flag:"host"`
+}
+
+type mapEnv map[string]string
+
+func (m mapEnv) Get(envNamed string) string {
+	return m[envNamed]
+}
+
+func (m mapEnv) Keys(_ string) []string {
+	return nil
+}
+
+// newDispatcher runs server start, also known as up, and records the hooks, middlewares, methods and help pages that
+// run in calls
+func newDispatcher(calls *[]string) *Dispatcher {
+	start := dsl.Command{
+		Aliases: []string{"up"},
+		MaxArgs: 1,
+		Options: []dsl.OptionOrReference{
+			{Option: dsl.Option{Name: "Port", Type: "int", Required: true, Flag: dsl.FlagDef{Name: "port"}}},
+			{Option: dsl.Option{Name: "Host", Type: "string", Default: optional.StringFrom("localhost"), Flag: dsl.FlagDef{Name: "host"}}},
+		},
+	}
+	server := dsl.Command{Commands: dsl.NamedCommands{"start": start}}
+	service := &cmd_definitions.ServiceDesc{
+		Root: cmd_definitions.MethodDesc{Meta: dsl.Command{Commands: dsl.NamedCommands{"server": server}}},
+	}
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta: server,
+		HookBefore: func(ctx context.Context) error {
+			*calls = append(*calls, "server before")
+			return nil
+		},
+		HookAfter: func(ctx context.Context, err error) error {
+			*calls = append(*calls, "server after")
+			return err
+		},
+	}, "server")
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta: start,
+		Handler: func(ctx context.Context) error {
+			invocation, _ := cmd_definitions.InvocationFrom(ctx)
+			options := invocation.Options.(*dispatchStartOptions)
+			*calls = append(*calls, "server start "+options.Host)
+			return nil
+		},
+		ObjectMaker: func() interface{} {
+			return &dispatchStartOptions{Host: "localhost"}
+		},
+	}, "server", "start")
+	service.Middlewares.Use(func(next cmd_definitions.MethodHandler) cmd_definitions.MethodHandler {
+		return func(ctx context.Context) error {
+			*calls = append(*calls, "middleware before")
+			err := next(ctx)
+			*calls = append(*calls, "middleware after")
+			return err
+		}
+	})
+	return &Dispatcher{
+		Service: service,
+		Help: func(_ context.Context, path []string) error {
+			*calls = append(*calls, "help "+strings.Join(path, " "))
+			return nil
+		},
+	}
+}
+
+func TestDispatcher_Switch(t *testing.T) {
+	cases := map[string]struct {
+		args               []string
+		env                mapEnv
+		expectedCalls      []string
+		expectedDispatched []string
+		expectedErr        error
+	}{
+		"command": {
+			args:               []string{"server", "start", "--port=80", "--host", "example.com"},
+			expectedCalls:      []string{"middleware before", "server before", "server start example.com", "server after", "middleware after"},
+			expectedDispatched: []string{"ServerStart"},
+		},
+		"alias": {
+			args:               []string{"server", "up", "--port=80"},
+			expectedCalls:      []string{"middleware before", "server before", "server start localhost", "server after", "middleware after"},
+			expectedDispatched: []string{"ServerStart"},
+		},
+		"environment of Switch": {
+			args:               []string{"server", "start"},
+			env:                mapEnv{"PORT": "80"},
+			expectedCalls:      []string{"middleware before", "server before", "server start localhost", "server after", "middleware after"},
+			expectedDispatched: []string{"ServerStart"},
+		},
+		"help": {
+			args:          []string{"server", "start", "--help"},
+			expectedCalls: []string{"help server start"},
+		},
+		"help of an unknown command": {
+			args:          []string{"server", "strat", "--help"},
+			expectedCalls: []string{"help server"},
+		},
+		"help after --": {
+			args:        []string{"server", "start", "--port=80", "--", "--help", "extra"},
+			expectedErr: &parse.ErrArgumentCount{},
+		},
+		"missing required option": {
+			args:        []string{"server", "start"},
+			expectedErr: &parse.ErrMissingRequired{},
+		},
+		"command without a method": {
+			args:        []string{"server"},
+			expectedErr: &ErrUsage{},
+		},
+		"unknown command": {
+			args:        []string{"server", "strat"},
+			expectedErr: &parse.ErrUnknownCommand{},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			var calls, dispatched []string
+			ctx := WithDispatchObserver(context.Background(), func(method string, _ interface{}) {
+				dispatched = append(dispatched, method)
+			})
+			env := c.env
+			if env == nil {
+				env = mapEnv{}
+			}
+			err := newDispatcher(&calls).Switch(ctx, c.args, env)
+			if c.expectedErr != nil {
+				assert.IsType(t, c.expectedErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, c.expectedCalls, calls)
+			assert.Equal(t, c.expectedDispatched, dispatched)
+		})
+	}
+}
+
+func TestRun_DispatcherPanic(t *testing.T) {
+	cases := map[string]struct {
+		debug          bool
+		expectedStack  bool
+		expectedStderr string
+	}{
+		"without debug": {
+			expectedStderr: "error: command \"server start\" panicked: boom\n",
+		},
+		"with debug": {
+			debug:         true,
+			expectedStack: true,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			var calls []string
+			dispatcher := newDispatcher(&calls)
+			start, _ := dispatcher.Service.Methods.Get("server", "start")
+			start.Handler = func(ctx context.Context) error {
+				panic("boom")
+			}
+			dispatcher.Service.Methods.Put(start, "server", "start")
+			server, _ := dispatcher.Service.Methods.Get("server")
+			var hookErr error
+			server.HookAfter = func(_ context.Context, err error) error {
+				hookErr = err
+				return err
+			}
+			dispatcher.Service.Methods.Put(server, "server")
+			dispatcher.Service.Debug = func() bool {
+				return c.debug
+			}
+			stderr := &bytes.Buffer{}
+			actualCode := run(context.Background(), dispatcher, []string{"server", "start", "--port=80"}, mapEnv{}, stderr, func() os.Signal {
+				return nil
+			})
+			assert.Equal(t, ExitFailure, actualCode)
+			var panicked *cmd_definitions.ErrPanic
+			assert.True(t, errors.As(hookErr, &panicked), "HookAfter should see the panic")
+			assert.Equal(t, []string{"middleware before", "server before", "middleware after"}, calls)
+			if c.expectedStack {
+				assert.True(t, strings.HasPrefix(stderr.String(), "error: command \"server start\" panicked: boom\ngoroutine "), stderr.String())
+			} else {
+				assert.Equal(t, c.expectedStderr, stderr.String())
+			}
+		})
+	}
+}
@@ -1,5 +1,78 @@
 package cli
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"github.com/wojnosystems/okey-dokey/bad"
+	"sort"
+	"strings"
+)
 
 var ErrCommandUnimplemented = errors.New("command was declared, but not implemented")
+
+// ErrUsage is returned when the command line is malformed: an unknown command, flag, or the wrong number of arguments
+type ErrUsage struct {
+	Reason string
+}
+
+func (e *ErrUsage) Error() string {
+	return e.Reason
+}
+
+// ErrValidation is returned when the options parsed fine, but their values are not acceptable
+type ErrValidation struct {
+	Problems bad.Collector
+}
+
+// Error lists every problem as "path: message", one per line
+func (e *ErrValidation) Error() string {
+	paths := e.Problems.Paths()
+	sort.Strings(paths)
+	var problems []string
+	for _, path := range paths {
+		for _, message := range e.Problems.MessagesAtPath(path) {
+			if path == "" {
+				problems = append(problems, message)
+			} else {
+				problems = append(problems, path+": "+message)
+			}
+		}
+	}
+	return "invalid options:\n" + strings.Join(problems, "\n")
+}
+
+// ExitCoder is an error that picks the code the process exits with
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// ErrExit is an error with the code the process exits with, for commands that need a specific code
+type ErrExit struct {
+	Code int
+	// Err is the reason, nothing is printed when it is nil
+	Err error
+}
+
+// NewErrExit makes an ErrExit with a formatted reason
+func NewErrExit(code int, format string, args ...interface{}) *ErrExit {
+	return &ErrExit{
+		Code: code,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+func (e *ErrExit) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit code %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ErrExit) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrExit) ExitCode() int {
+	return e.Code
+}
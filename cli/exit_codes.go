@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"errors"
+	"github.com/wojnosystems/flick/parse"
+)
+
+const (
+	ExitOK = 0
+	// ExitFailure is used for errors that are not more specific
+	ExitFailure = 1
+	// ExitUsage is used when the command line is malformed, as shells do for their builtins
+	ExitUsage = 2
+	// ExitValidation is EX_USAGE from sysexits.h, the options were well-formed, but their values are not acceptable
+	ExitValidation = 64
+	// ExitUnimplemented is EX_SOFTWARE from sysexits.h, the command exists, but the application has not implemented it
+	ExitUnimplemented = 70
+	// exitSignalBase is added to the number of the signal that stopped the command, as shells do
+	exitSignalBase = 128
+)
+
+// ExitCode picks the code the process exits with when a command returns err. Errors that implement ExitCoder choose
+// their own code, the errors of this package have the codes above, the errors parse returns for malformed command
+// lines and missing required options are ExitUsage and any other error is ExitFailure
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	var usage *ErrUsage
+	if errors.As(err, &usage) {
+		return ExitUsage
+	}
+	if isParseUsageError(err) {
+		return ExitUsage
+	}
+	var validation *ErrValidation
+	if errors.As(err, &validation) {
+		return ExitValidation
+	}
+	if errors.Is(err, ErrCommandUnimplemented) {
+		return ExitUnimplemented
+	}
+	return ExitFailure
+}
+
+// parseUsageErrors are the errors that parse returns for command lines that are malformed, or miss required options
+var parseUsageErrors = []interface{}{
+	new(*parse.ErrUnknownCommand),
+	new(*parse.ErrArgumentCount),
+	new(*parse.ErrUnknownFlag),
+	new(*parse.ErrFlagOutOfScope),
+	new(*parse.ErrFlagNeedsValue),
+	new(*parse.ErrInvalidValue),
+	new(*parse.ErrMissingRequired),
+}
+
+func isParseUsageError(err error) bool {
+	for _, target := range parseUsageErrors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
+	"io"
+)
+
+// configPrinter is implemented by options that embed parse.ConfigDump
+type configPrinter interface {
+	Marshaler(sources parse.Sources) (marshaler parse.FileMarshaler, err error)
+}
+
+// PrintConfig is the middleware behind --print-config. When the options of a command embed a parse.ConfigDump that
+// asks for the configuration, the options are written to stdout instead of running the command, and the process exits
+// with ExitOK. sources annotates yaml with where each value was set when --print-config-sources is given, it may be
+// nil:
+//
+//	service.Middlewares.Use(cli.PrintConfig(os.Stdout, sources))
+func PrintConfig(stdout io.Writer, sources parse.Sources) cmd_definitions.Middleware {
+	return func(next cmd_definitions.MethodHandler) cmd_definitions.MethodHandler {
+		return func(ctx context.Context) (err error) {
+			invocation, _ := cmd_definitions.InvocationFrom(ctx)
+			printer, ok := invocation.Options.(configPrinter)
+			if !ok {
+				return next(ctx)
+			}
+			marshaler, err := printer.Marshaler(sources)
+			if err != nil {
+				return &ErrUsage{Reason: err.Error()}
+			}
+			if marshaler == nil {
+				return next(ctx)
+			}
+			err = marshaler.MarshalFile(stdout, invocation.Options)
+			if err != nil {
+				return
+			}
+			return &ErrExit{Code: ExitOK}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
+	"github.com/wojnosystems/go-optional/v2"
+	"testing"
+)
+
+type printedOptions struct {
+	parse.ConfigDump
+	Host     optional.String `yaml:"host"`
+	Password optional.String `yaml:"password" secret:"true"`
+}
+
+func TestPrintConfig(t *testing.T) {
+	cases := map[string]struct {
+		options        interface{}
+		expectedCode   int
+		expectedStdout string
+		expectedCalled bool
+	}{
+		"not requested": {
+			options:        &printedOptions{Host: optional.StringFrom("example.com")},
+			expectedCode:   ExitOK,
+			expectedCalled: true,
+		},
+		"options without ConfigDump": {
+			options:        &struct{}{},
+			expectedCode:   ExitOK,
+			expectedCalled: true,
+		},
+		"yaml": {
+			options: &printedOptions{
+				ConfigDump: parse.ConfigDump{PrintConfig: optional.StringFrom("yaml")},
+				Host:       optional.StringFrom("example.com"),
+				Password:   optional.StringFrom("hunter2"),
+			},
+			expectedCode:   ExitOK,
+			expectedStdout: "---\nhost: \"example.com\"\npassword: \"<redacted>\"\n",
+		},
+		"json": {
+			options: &printedOptions{
+				ConfigDump: parse.ConfigDump{PrintConfig: optional.StringFrom("json")},
+				Host:       optional.StringFrom("example.com"),
+			},
+			expectedCode:   ExitOK,
+			expectedStdout: "{\n  \"host\": \"example.com\"\n}\n",
+		},
+		"unsupported format": {
+			options: &printedOptions{
+				ConfigDump: parse.ConfigDump{PrintConfig: optional.StringFrom("toml")},
+			},
+			expectedCode: ExitUsage,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			called := false
+			service := cmd_definitions.ServiceDesc{
+				Root: cmd_definitions.MethodDesc{
+					Handler: func(ctx context.Context) error {
+						called = true
+						return nil
+					},
+				},
+			}
+			service.Middlewares.Use(PrintConfig(stdout, nil))
+			handler, _ := service.Handler(nil, c.options)
+			err := handler(context.Background())
+			assert.Equal(t, c.expectedCode, ExitCode(err))
+			assert.Equal(t, c.expectedStdout, stdout.String())
+			assert.Equal(t, c.expectedCalled, called)
+		})
+	}
+}
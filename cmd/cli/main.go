@@ -1,6 +1,105 @@
 package main
 
+import (
+	"fmt"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/okey-dokey/bad"
+	"io"
+	"os"
+	"sort"
+)
+
+const usage = `usage: flick <command> [arguments]
+
+commands:
+  lint FILE...     report problems with optionapi specifications
+  migrate FILE...  upgrade optionapi specifications, in place, to the current version
+  schema           write the JSON Schema of the optionapi specification format
+`
+
 // Creates configuration for the language desired, just like Protobuf/OpenAPI
 func main() {
-	// TODO: Add code to generate code here
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command in args and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "lint":
+		return lint(args[1:], stdout, stderr)
+	case "migrate":
+		return migrate(args[1:], stdout, stderr)
+	case "schema":
+		if err := dsl.WriteDocumentSchema(stdout); err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	default:
+		_, _ = fmt.Fprintf(stderr, "unknown command: %s\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// lint checks each of the files and writes every problem found as "path: message at file:line:column".
+// Returns non-zero if any file has a problem
+func lint(fileNames []string, stdout, stderr io.Writer) (exitCode int) {
+	if len(fileNames) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return 2
+	}
+	for _, fileName := range fileNames {
+		problems, err := lintFile(fileName)
+		for _, problem := range problems {
+			_, _ = fmt.Fprintln(stdout, problem)
+		}
+		if err != nil && err != dsl.ErrValidation {
+			_, _ = fmt.Fprintln(stderr, err)
+		}
+		if err != nil {
+			exitCode = 1
+		}
+	}
+	return
+}
+
+func lintFile(fileName string) (problems []string, err error) {
+	validationErrors := bad.NewCollection()
+	_, err = dsl.LintFile(fileName, validationErrors)
+	paths := validationErrors.Paths()
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, message := range validationErrors.MessagesAtPath(path) {
+			if path == "" {
+				problems = append(problems, message)
+			} else {
+				problems = append(problems, path+": "+message)
+			}
+		}
+	}
+	return
+}
+
+// migrate upgrades each of the files and writes every change made as "path: change". Returns non-zero if any file
+// could not be upgraded
+func migrate(fileNames []string, stdout, stderr io.Writer) (exitCode int) {
+	if len(fileNames) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return 2
+	}
+	for _, fileName := range fileNames {
+		changes, err := dsl.MigrateFile(fileName)
+		for _, change := range changes {
+			_, _ = fmt.Fprintln(stdout, fileName+": "+change)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			exitCode = 1
+		}
+	}
+	return
 }
@@ -3,14 +3,16 @@ module github.com/wojnosystems/flick
 go 1.14
 
 require (
+	github.com/goccy/go-yaml v1.8.2
 	github.com/stretchr/testify v1.7.0
 	github.com/wojnosystems/go-env/v2 v2.0.10
 	github.com/wojnosystems/go-flag-unmarshaler v1.1.7
+	github.com/wojnosystems/go-into-struct v0.2.1
 	github.com/wojnosystems/go-nested-map v0.0.2
 	github.com/wojnosystems/go-optional-parse-registry/v2 v2.0.0
 	github.com/wojnosystems/go-optional/v2 v2.0.1
 	github.com/wojnosystems/go-parse-register v1.2.0
-	github.com/wojnosystems/go-string-set v0.0.6
 	github.com/wojnosystems/okey-dokey v1.0.2
 	github.com/wojnosystems/yamlreg v0.0.4
+	golang.org/x/sys v0.0.0-20201024232916-9f70ab9862d5
 )
@@ -0,0 +1,43 @@
+package parse
+
+import (
+	"errors"
+	"github.com/wojnosystems/go-optional/v2"
+)
+
+var ErrUnsupportedConfigFormat = errors.New("unsupported configuration format, expected one of: yaml, json")
+
+// ConfigDump is a convenience structure that will print the effective configuration so operators can see what
+// a process is actually running with. Embed this into your configuration struct to get --print-config support
+type ConfigDump struct {
+	PrintConfig        optional.String `env:"PRINT_CONFIG" flag:"print-config" usage:"yaml|json" help:"print the loaded configuration in this format and exit"`
+	PrintConfigSources optional.Bool   `env:"PRINT_CONFIG_SOURCES" flag:"print-config-sources" help:"true to annotate the printed configuration with where each value was set"`
+}
+
+func (f ConfigDump) Flags() []string {
+	return []string{"--print-config", "--print-config-sources"}
+}
+
+// Marshaler returns the FileMarshaler for the requested format. The marshaler is nil if printing was not requested.
+// sources annotates yaml output when PrintConfigSources is true, sources may be nil
+func (f ConfigDump) Marshaler(sources Sources) (marshaler FileMarshaler, err error) {
+	f.PrintConfig.IfSet(func(format string) {
+		switch format {
+		case "yaml":
+			withSources := false
+			f.PrintConfigSources.IfSet(func(value bool) {
+				withSources = value
+			})
+			if withSources {
+				marshaler = YamlMarshalerWithSources(sources)
+			} else {
+				marshaler = YamlMarshaler()
+			}
+		case "json":
+			marshaler = JsonMarshaler()
+		default:
+			err = ErrUnsupportedConfigFormat
+		}
+	})
+	return
+}
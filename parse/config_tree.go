@@ -0,0 +1,223 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wojnosystems/go-optional/v2"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const redactedValue = "<redacted>"
+
+var (
+	optionalTesterType = reflect.TypeOf((*optional.Tester)(nil)).Elem()
+	durationType       = reflect.TypeOf(time.Duration(0))
+	timeType           = reflect.TypeOf(time.Time{})
+	configDumpType     = reflect.TypeOf(ConfigDump{})
+)
+
+// configNode is a yaml-shaped view of a configuration structure. It mirrors the keys that Yaml() reads
+// so that the tree may be written back out and read in again
+type configNode struct {
+	// key is the yaml key of this node, blank for the root and for list items
+	key string
+	// path is the full path to this node, in the same format as bad.MemberEmitter paths: server.hosts[0].name
+	path string
+	// scalar is the json-encoded value of a leaf node
+	scalar   string
+	isScalar bool
+	isList   bool
+	children []*configNode
+}
+
+func (n configNode) isEmpty() bool {
+	return !n.isScalar && len(n.children) == 0
+}
+
+// newConfigTree converts config, a struct or reference to a struct, into a configNode tree.
+// Unset optional values are left out of the tree. Fields tagged with `secret:"true"` are replaced with a placeholder when redact is true
+func newConfigTree(config interface{}, redact bool) (root *configNode, err error) {
+	v := reflect.ValueOf(config)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return &configNode{}, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		err = fmt.Errorf("config must be a struct or a reference to a struct, got: %s", v.Type().String())
+		return
+	}
+	root = &configNode{}
+	err = addConfigNodeChildren(root, v, redact)
+	return
+}
+
+func addConfigNodeChildren(parent *configNode, v reflect.Value, redact bool) (err error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				// unexported
+				continue
+			}
+			if field.Type == configDumpType {
+				// asks for the configuration to be printed, it is not part of it
+				continue
+			}
+			key := yamlKey(field)
+			if key == "-" {
+				continue
+			}
+			child := &configNode{
+				key:  key,
+				path: joinConfigPath(parent.path, key),
+			}
+			var include bool
+			include, err = fillConfigNode(child, v.Field(i), redact, isSecret(field))
+			if err != nil {
+				return
+			}
+			if include {
+				parent.children = append(parent.children, child)
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			key := fmt.Sprint(k.Interface())
+			child := &configNode{
+				key:  key,
+				path: joinConfigPath(parent.path, key),
+			}
+			var include bool
+			include, err = fillConfigNode(child, v.MapIndex(k), redact, false)
+			if err != nil {
+				return
+			}
+			if include {
+				parent.children = append(parent.children, child)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			child := &configNode{
+				path: fmt.Sprintf("%s[%d]", parent.path, i),
+			}
+			_, err = fillConfigNode(child, v.Index(i), redact, false)
+			if err != nil {
+				return
+			}
+			parent.children = append(parent.children, child)
+		}
+	}
+	return
+}
+
+// fillConfigNode populates node from v. include is false if there is no value to write out for v
+func fillConfigNode(node *configNode, v reflect.Value, redact, secret bool) (include bool, err error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Type().Implements(optionalTesterType) {
+		var isSet bool
+		var value reflect.Value
+		isSet, value = OptionalValue(v)
+		if !isSet {
+			return
+		}
+		v = value
+	}
+	if redact && secret {
+		node.isScalar = true
+		node.scalar, err = encodeScalar(reflect.ValueOf(redactedValue))
+		return true, err
+	}
+	switch {
+	case v.Type() == durationType:
+		node.isScalar = true
+		node.scalar, err = encodeScalar(reflect.ValueOf(v.Interface().(time.Duration).String()))
+	case v.Type() == timeType:
+		node.isScalar = true
+		node.scalar, err = encodeScalar(reflect.ValueOf(v.Interface().(time.Time).Format(time.RFC3339)))
+	case v.Kind() == reflect.Struct || v.Kind() == reflect.Map:
+		err = addConfigNodeChildren(node, v, redact)
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		node.isList = true
+		err = addConfigNodeChildren(node, v, redact)
+		// lists that were set, even to nothing, are written out as []
+		return v.Kind() == reflect.Array || !v.IsNil(), err
+	default:
+		node.isScalar = true
+		node.scalar, err = encodeScalar(v)
+	}
+	include = !node.isEmpty()
+	return
+}
+
+// OptionalValue extracts the value stored in one of the go-optional types, which only expose it through IfSet
+func OptionalValue(v reflect.Value) (isSet bool, value reflect.Value) {
+	isSet = v.MethodByName("IsSet").Call(nil)[0].Bool()
+	if !isSet {
+		return
+	}
+	ifSet := v.MethodByName("IfSet")
+	callbackType := ifSet.Type().In(0)
+	ifSet.Call([]reflect.Value{
+		reflect.MakeFunc(callbackType, func(args []reflect.Value) []reflect.Value {
+			value = args[0]
+			return nil
+		}),
+	})
+	return
+}
+
+func encodeScalar(v reflect.Value) (out string, err error) {
+	encoded := strings.Builder{}
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(v.Interface())
+	out = strings.TrimSuffix(encoded.String(), "\n")
+	return
+}
+
+// flatten lists every scalar in the tree by its path
+func (n *configNode) flatten(out map[string]string) {
+	if n.isScalar {
+		out[n.path] = n.scalar
+		return
+	}
+	for _, child := range n.children {
+		child.flatten(out)
+	}
+}
+
+// yamlKey is the name of the key that the yaml decoder matches to field
+func yamlKey(field reflect.StructField) string {
+	tagParts := strings.Split(field.Tag.Get("yaml"), ",")
+	if tagParts[0] != "" {
+		return tagParts[0]
+	}
+	return field.Name
+}
+
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true"
+}
+
+func joinConfigPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
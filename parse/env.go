@@ -1,19 +1,104 @@
 package parse
 
 import (
+	"fmt"
 	envParser "github.com/wojnosystems/go-env/v2"
+	into_struct "github.com/wojnosystems/go-into-struct"
+	"strconv"
+	"strings"
 )
 
-type env struct {
-	parser *envParser.Env
+// Env reads each field from the environment variable named by its env tag, or by its name when it has none. The
+// fields of nested structs add their own name to that of the struct: the field Port of the field Server is read from
+// Server_Port. Items of lists are read from a variable each, HOSTS_0, HOSTS_1 and so on. Embedded structs are not part
+// of the name, as their fields are promoted
+func Env() EnvUnmarshaler {
+	return EnvFrom(&envParser.OsEnv{})
 }
 
-func Env() EnvUnmarshaler {
-	return &env{
-		parser: envParser.NewWithParseRegistry(defaultYamlParseRegistry),
+// EnvFrom is Env, reading the variables from reader rather than the environment of the process, such as the
+// EnvReader given to cli.Commander.Switch
+func EnvFrom(reader envParser.EnvReader) EnvUnmarshaler {
+	return &structEnv{
+		name:    fieldEnvName,
+		reader:  reader,
+		emitter: &envParser.SetReceiverNoOp{},
+	}
+}
+
+// structEnv reads the fields of a struct from the environment variables that name gives them
+type structEnv struct {
+	name    func(structFullPath into_struct.Path) string
+	reader  envParser.EnvReader
+	emitter envParser.SetReceiver
+}
+
+func (e *structEnv) Unmarshal(config interface{}) (err error) {
+	return into_struct.Unmarshall(config, e)
+}
+
+func (e *structEnv) SetValue(structFullPath into_struct.Path) (handled bool, err error) {
+	field := structFullPath.Top()
+	if field == nil {
+		return
+	}
+	envName := e.name(structFullPath)
+	value := e.reader.Get(envName)
+	if value == "" {
+		// like flags, a type that could have been read is left as it is, rather than read field by field
+		handled = defaultYamlParseRegistry.IsSupported(field.Value().Addr().Interface())
+		return
+	}
+	handled, err = defaultYamlParseRegistry.SetValue(field.Value().Addr().Interface(), value)
+	if err != nil {
+		err = &ErrInvalidValue{
+			Source: EnvSource(envName),
+			Err:    fmt.Errorf("environment variable '%s' failed to parse because %w", envName, err),
+		}
+		return
+	}
+	if handled {
+		e.emitter.ReceiveSet(structFullPath, envName, value)
 	}
+	return
 }
 
-func (e *env) Unmarshal(config interface{}) (err error) {
-	return e.parser.Unmarshall(config)
+func (e *structEnv) SliceLen(structFullPath into_struct.Path) (length int, err error) {
+	pathPrefix := e.name(structFullPath) + envNameSeparator
+	maxIndex := int64(-1)
+	for _, key := range e.reader.Keys(pathPrefix) {
+		possibleNumber := envIndexRegexp.FindString(key[len(pathPrefix):])
+		if possibleNumber == "" {
+			continue
+		}
+		var index int64
+		index, err = strconv.ParseInt(possibleNumber, 10, 0)
+		if err != nil {
+			return
+		}
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+	length = int(maxIndex + 1)
+	return
+}
+
+// fieldEnvName is the environment variable that Env reads for the value at structFullPath
+func fieldEnvName(structFullPath into_struct.Path) string {
+	words := make([]string, 0, len(structFullPath.Parts()))
+	for _, part := range structFullPath.Parts() {
+		field := part.StructField()
+		name := field.Tag.Get("env")
+		if name == "" && !field.Anonymous {
+			name = field.Name
+		}
+		if name != "" {
+			words = append(words, name)
+		}
+		if slicePart, ok := part.(into_struct.PathSliceParter); ok {
+			words = append(words, strconv.Itoa(slicePart.Index()))
+		}
+	}
+	return strings.Join(words, envNameSeparator)
 }
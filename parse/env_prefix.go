@@ -0,0 +1,71 @@
+package parse
+
+import (
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	envParser "github.com/wojnosystems/go-env/v2"
+	into_struct "github.com/wojnosystems/go-into-struct"
+	"regexp"
+	"strconv"
+)
+
+const envNameSeparator = "_"
+
+var envIndexRegexp = regexp.MustCompile(`^(\d+)`)
+
+// EnvWithPrefix is like Env, but derives the environment variable of each field that has no env tag from prefix and
+// the path to the field, the same way optionapi documents with an envPrefix do: the field ConnectTimeout of the field
+// Server is read from MYAPP_SERVER_CONNECT_TIMEOUT. Fields with an env tag are read from the variable they name.
+// Embedded structs are not part of the path, as their fields are promoted
+func EnvWithPrefix(prefix string) EnvUnmarshaler {
+	return EnvWithPrefixFrom(&envParser.OsEnv{}, prefix)
+}
+
+// EnvWithPrefixFrom is EnvWithPrefix, reading the variables from reader
+func EnvWithPrefixFrom(reader envParser.EnvReader, prefix string) EnvUnmarshaler {
+	return &structEnv{
+		name:    prefixedEnvName(prefix),
+		reader:  reader,
+		emitter: &envParser.SetReceiverNoOp{},
+	}
+}
+
+// EnvWithPrefixAndSources is just like EnvWithPrefix, but records each environment variable used in sources
+func EnvWithPrefixAndSources(prefix string, sources Sources) EnvUnmarshaler {
+	return EnvWithPrefixAndSourcesFrom(&envParser.OsEnv{}, prefix, sources)
+}
+
+// EnvWithPrefixAndSourcesFrom is EnvWithPrefixAndSources, reading the variables from reader
+func EnvWithPrefixAndSourcesFrom(reader envParser.EnvReader, prefix string, sources Sources) EnvUnmarshaler {
+	return &structEnv{
+		name:   prefixedEnvName(prefix),
+		reader: reader,
+		emitter: &sourceReceiver{
+			sources: sources,
+			prefix:  sourceEnvPrefix,
+		},
+	}
+}
+
+// prefixedEnvName names the environment variable read for the value at a path after envPrefix. A field with an env
+// tag names its own variable, which the fields within it add to, without the prefix
+func prefixedEnvName(envPrefix string) func(structFullPath into_struct.Path) string {
+	return func(structFullPath into_struct.Path) string {
+		prefix, tagged := envPrefix, false
+		words := make([]string, 0, len(structFullPath.Parts()))
+		for _, part := range structFullPath.Parts() {
+			field := part.StructField()
+			if name := field.Tag.Get("env"); name != "" {
+				prefix, tagged, words = name, true, words[:0]
+			} else if !field.Anonymous {
+				words = append(words, field.Name)
+			}
+			if slicePart, ok := part.(into_struct.PathSliceParter); ok {
+				words = append(words, strconv.Itoa(slicePart.Index()))
+			}
+		}
+		if tagged && len(words) != 0 {
+			prefix += envNameSeparator
+		}
+		return dsl.EnvName(prefix, words...)
+	}
+}
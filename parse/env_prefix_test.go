@@ -0,0 +1,85 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/go-optional/v2"
+	"os"
+	"testing"
+	"time"
+)
+
+type prefixedServerConfig struct {
+	ConnectTimeout optional.Duration
+	Hosts          []string
+}
+
+type prefixedConfig struct {
+	ConfigFile
+	Server  prefixedServerConfig
+	DryRun  optional.Bool
+	Address optional.String `env:"LISTEN_ADDRESS"`
+}
+
+func TestEnvWithPrefix(t *testing.T) {
+	cases := map[string]struct {
+		env             map[string]string
+		expected        prefixedConfig
+		expectedSources Sources
+	}{
+		"derived names": {
+			env: map[string]string{
+				"PREFIX_TEST_SERVER_CONNECT_TIMEOUT": "5s",
+				"PREFIX_TEST_SERVER_HOSTS_0":         "a.example.com",
+				"PREFIX_TEST_SERVER_HOSTS_1":         "b.example.com",
+				"PREFIX_TEST_DRY_RUN":                "true",
+			},
+			expected: prefixedConfig{
+				Server: prefixedServerConfig{
+					ConnectTimeout: optional.DurationFrom(5 * time.Second),
+					Hosts:          []string{"a.example.com", "b.example.com"},
+				},
+				DryRun: optional.BoolFrom(true),
+			},
+			expectedSources: Sources{
+				"Server.ConnectTimeout": "env:PREFIX_TEST_SERVER_CONNECT_TIMEOUT",
+				"Server.Hosts[0]":       "env:PREFIX_TEST_SERVER_HOSTS_0",
+				"Server.Hosts[1]":       "env:PREFIX_TEST_SERVER_HOSTS_1",
+				"DryRun":                "env:PREFIX_TEST_DRY_RUN",
+			},
+		},
+		"env tags override the derived names": {
+			env: map[string]string{
+				"LISTEN_ADDRESS":      ":8080",
+				"PREFIX_TEST_ADDRESS": ":9090",
+				"CONFIG_FILE_PATH":    "app.yaml",
+			},
+			expected: prefixedConfig{
+				ConfigFile: ConfigFile{ConfigFilePath: optional.StringFrom("app.yaml")},
+				Address:    optional.StringFrom(":8080"),
+			},
+			expectedSources: Sources{
+				"ConfigFile.ConfigFilePath": "env:CONFIG_FILE_PATH",
+				"Address":                   "env:LISTEN_ADDRESS",
+			},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			for name, value := range c.env {
+				require.NoError(t, os.Setenv(name, value))
+			}
+			defer func() {
+				for name := range c.env {
+					_ = os.Unsetenv(name)
+				}
+			}()
+			actual := prefixedConfig{}
+			sources := NewSources()
+			err := EnvWithPrefixAndSources("PREFIX_TEST_", sources).Unmarshal(&actual)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+			assert.Equal(t, c.expectedSources, sources)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package parse
+
+import (
+	"errors"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+)
+
+// ErrInvalidValue is returned when the value of a flag or environment variable cannot be read into its field, such as
+// --port abc for an int
+type ErrInvalidValue struct {
+	// Source is where the value came from, described the way Sources does: "flag:--port" or "env:PORT"
+	Source string
+	Err    error
+}
+
+func (e *ErrInvalidValue) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrInvalidValue) Unwrap() error {
+	return e.Err
+}
+
+// invalidFlagValue turns the ParseError that go-flag-unmarshaler returns for a value that does not parse into an
+// ErrInvalidValue, naming the flag the way it was given. Other errors are returned as they are
+func invalidFlagValue(err error, flags *gnuFlags) error {
+	var parseErr *flag_unmarshaler.ParseError
+	if errors.As(err, &parseErr) {
+		return &ErrInvalidValue{
+			Source: FlagSource(flags.flagName(parseErr.Path.FlagPath)),
+			Err:    err,
+		}
+	}
+	return err
+}
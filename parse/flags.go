@@ -9,6 +9,8 @@ type flags struct {
 	collectedFlags usedFlags
 }
 
+// Flags reads the flags in globalGroup, as split by SplitGNU or flag_unmarshaler.Split. --no-name sets the bool flag
+// --name to false, and the flag given last wins. Values that do not parse are an ErrInvalidValue
 func Flags(globalGroup *flag_unmarshaler.Group) EnvUnmarshaler {
 	return &flags{
 		globalGroup: globalGroup,
@@ -16,6 +18,7 @@ func Flags(globalGroup *flag_unmarshaler.Group) EnvUnmarshaler {
 }
 
 func (e *flags) Unmarshal(config interface{}) (err error) {
-	parser := flag_unmarshaler.NewWithTypeParsers(e.globalGroup, defaultYamlParseRegistry)
-	return parser.Unmarshal(config)
+	flags := newGnuFlags(e.globalGroup, config)
+	parser := flag_unmarshaler.NewWithTypeParsers(flags, defaultYamlParseRegistry)
+	return invalidFlagValue(parser.Unmarshal(config), flags)
 }
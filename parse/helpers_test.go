@@ -1,8 +1,29 @@
 package parse
 
-import "github.com/wojnosystems/go-optional/v2"
+import (
+	"github.com/wojnosystems/go-optional/v2"
+	"sort"
+	"strings"
+)
 
 type appConfig struct {
 	Hostname optional.String   `yaml:"hostname"`
 	Delay    optional.Duration `yaml:"delay"`
 }
+
+// mapEnv is an env_parser.EnvReader that holds the variables by name, instead of the environment of the process
+type mapEnv map[string]string
+
+func (m mapEnv) Get(envNamed string) string {
+	return m[envNamed]
+}
+
+func (m mapEnv) Keys(prefix string) (keys []string) {
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
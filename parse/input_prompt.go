@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"github.com/wojnosystems/go-optional/v2"
+	"os"
+)
+
+// InputPrompt is a convenience structure that lets users turn off prompting for missing required options, embed this
+// into your configuration struct to get --no-input support, and pass Prompter() to Unmarshall after every other source
+type InputPrompt struct {
+	NoInput optional.Bool `env:"NO_INPUT" flag:"no-input" help:"true to fail, rather than prompt, when required options are missing"`
+}
+
+func (f InputPrompt) Flags() []string {
+	return []string{"--no-input"}
+}
+
+// Prompter prompts on the terminal, unless --no-input was given or stdin is not a terminal
+func (f InputPrompt) Prompter() *Prompter {
+	noInput := false
+	f.NoInput.IfSet(func(value bool) {
+		noInput = value
+	})
+	return &Prompter{
+		In:          os.Stdin,
+		Out:         os.Stderr,
+		Interactive: !noInput && isTerminal(os.Stdin),
+		ReadSecret: func() (string, error) {
+			return readSecret(os.Stdin)
+		},
+	}
+}
@@ -20,3 +20,7 @@ type FlagUnmarshaler interface {
 type EnvUnmarshaler interface {
 	Unmarshaler
 }
+
+type FileMarshaler interface {
+	MarshalFile(w io.Writer, config interface{}) (err error)
+}
@@ -0,0 +1,67 @@
+package parse
+
+import (
+	"encoding/json"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+)
+
+type jsonOut struct {
+}
+
+// JsonMarshaler writes a configuration out as JSON using the same keys that Yaml() reads. Secret values are redacted
+func JsonMarshaler() FileMarshaler {
+	return &jsonOut{}
+}
+
+func (j *jsonOut) MarshalFile(w io.Writer, config interface{}) (err error) {
+	tree, err := newConfigTree(config, true)
+	if err != nil {
+		return
+	}
+	out := string_writer.New(w, "  ")
+	return j.writeNode(out, tree, "", "")
+}
+
+func (j *jsonOut) writeNode(out *string_writer.Type, node *configNode, prefix, suffix string) (err error) {
+	if node.isScalar {
+		return out.WriteLn(prefix + node.scalar + suffix)
+	}
+	open, closing := "{", "}"
+	if node.isList {
+		open, closing = "[", "]"
+	}
+	if len(node.children) == 0 {
+		return out.WriteLn(prefix + open + closing + suffix)
+	}
+	err = out.WriteLn(prefix + open)
+	if err != nil {
+		return
+	}
+	err = out.In(func(out *string_writer.Type) (err error) {
+		for i, child := range node.children {
+			childPrefix := ""
+			if !node.isList {
+				var key []byte
+				key, err = json.Marshal(child.key)
+				if err != nil {
+					return
+				}
+				childPrefix = string(key) + ": "
+			}
+			childSuffix := ","
+			if i == len(node.children)-1 {
+				childSuffix = ""
+			}
+			err = j.writeNode(out, child, childPrefix, childSuffix)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	return out.WriteLn(closing + suffix)
+}
@@ -0,0 +1,268 @@
+package parse
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+	"testing"
+	"time"
+)
+
+type dumpConfig struct {
+	Hostname optional.String   `yaml:"hostname"`
+	Delay    optional.Duration `yaml:"delay"`
+	Password optional.String   `yaml:"password" secret:"true"`
+	Retries  int               `yaml:"retries" flag:"retries"`
+	Servers  []dumpServer      `yaml:"servers"`
+}
+
+type dumpTriggers struct {
+	ConfigDump
+	Triggers map[string]string `yaml:"triggers"`
+	Tags     []string          `yaml:"tags"`
+	Servers  []dumpServer      `yaml:"servers"`
+}
+
+type dumpServer struct {
+	Name optional.String `yaml:"name"`
+	Port optional.Int    `yaml:"port"`
+}
+
+func TestMarshalFile(t *testing.T) {
+	config := dumpConfig{
+		Hostname: optional.StringFrom("test.example.com"),
+		Delay:    optional.DurationFrom(30 * time.Second),
+		Password: optional.StringFrom("hunter2"),
+		Retries:  3,
+		Servers: []dumpServer{
+			{
+				Name: optional.StringFrom("a"),
+				Port: optional.IntFrom(80),
+			},
+		},
+	}
+	cases := map[string]struct {
+		marshaler FileMarshaler
+		input     interface{}
+		expected  string
+	}{
+		"yaml empty": {
+			marshaler: YamlMarshaler(),
+			input:     &appConfig{},
+			expected:  "{}\n",
+		},
+		"json empty": {
+			marshaler: JsonMarshaler(),
+			input:     &appConfig{},
+			expected:  "{}\n",
+		},
+		"yaml": {
+			marshaler: YamlMarshaler(),
+			input:     &config,
+			expected: `---
+hostname: "test.example.com"
+delay: "30s"
+password: "<redacted>"
+retries: 3
+servers:
+  -
+    name: "a"
+    port: 80
+`,
+		},
+		"yaml with sources": {
+			marshaler: YamlMarshalerWithSources(Sources{
+				"hostname":        "env:HOSTNAME",
+				"retries":         "default",
+				"servers[0].port": "file:config.yaml",
+			}),
+			input: &dumpConfig{
+				Hostname: optional.StringFrom("test.example.com"),
+				Retries:  3,
+				Servers: []dumpServer{
+					{
+						Port: optional.IntFrom(80),
+					},
+				},
+			},
+			expected: `---
+hostname: "test.example.com" # env:HOSTNAME
+retries: 3 # default
+servers:
+  -
+    port: 80 # file:config.yaml
+`,
+		},
+		"yaml quotes keys that would not read back as strings": {
+			marshaler: YamlMarshaler(),
+			input: &dumpTriggers{
+				ConfigDump: ConfigDump{PrintConfig: optional.StringFrom("yaml")},
+				Triggers:   map[string]string{"on": "push", "8080": "http", "a: b": "c", "build": "always"},
+			},
+			expected: `---
+triggers:
+  "8080": "http"
+  "a: b": "c"
+  build: "always"
+  "on": "push"
+`,
+		},
+		"yaml empty lists": {
+			marshaler: YamlMarshaler(),
+			input: &dumpTriggers{
+				Tags:    []string{},
+				Servers: []dumpServer{{}},
+			},
+			expected: `---
+tags: []
+servers:
+  - {}
+`,
+		},
+		"json": {
+			marshaler: JsonMarshaler(),
+			input:     &config,
+			expected: `{
+  "hostname": "test.example.com",
+  "delay": "30s",
+  "password": "<redacted>",
+  "retries": 3,
+  "servers": [
+    {
+      "name": "a",
+      "port": 80
+    }
+  ]
+}
+`,
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := bytes.Buffer{}
+			err := c.marshaler.MarshalFile(&actual, c.input)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual.String())
+		})
+	}
+}
+
+func TestYamlMarshaler_RoundTrip(t *testing.T) {
+	expected := dumpConfig{
+		Hostname: optional.StringFrom("test.example.com"),
+		Delay:    optional.DurationFrom(30 * time.Second),
+		Retries:  3,
+		Servers: []dumpServer{
+			{
+				Name: optional.StringFrom("a"),
+			},
+			{
+				Name: optional.StringFrom("b"),
+				Port: optional.IntFrom(80),
+			},
+		},
+	}
+	buffer := bytes.Buffer{}
+	require.NoError(t, YamlMarshaler().MarshalFile(&buffer, &expected))
+
+	var actual dumpConfig
+	err := Unmarshall(&actual, newFileAsBytes(buffer.Bytes(), Yaml()))
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestSources(t *testing.T) {
+	config := dumpConfig{
+		Retries: 3,
+	}
+	sources := NewSources()
+	require.NoError(t, sources.Defaults(&config))
+	err := Unmarshall(&config,
+		sources.Traced("file:config.yaml", newFileAsBytes([]byte(`---
+hostname: test.example.com
+`), Yaml())),
+		FlagsWithSources(&flag_unmarshaler.Group{
+			Flags: []flag_unmarshaler.KeyValue{
+				{
+					Key:   "--retries",
+					Value: "5",
+				},
+			},
+		}, sources),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, Sources{
+		"hostname": "file:config.yaml",
+		"retries":  "flag:--retries",
+	}, sources)
+}
+
+func TestSources_EmbeddedFlags(t *testing.T) {
+	var config fileConfig
+	sources := NewSources()
+	err := FlagsWithSources(&flag_unmarshaler.Group{
+		Flags: []flag_unmarshaler.KeyValue{
+			{
+				Key:   "-c",
+				Value: "app.yaml",
+			},
+		},
+	}, sources).Unmarshal(&config)
+	require.NoError(t, err)
+	assert.Equal(t, optional.StringFrom("app.yaml"), config.ConfigFilePath)
+	assert.Equal(t, Sources{
+		"ConfigFile.ConfigFilePath": "flag:--config-file-path",
+	}, sources)
+}
+
+func TestSources_Deprecated(t *testing.T) {
+	sources := Sources{
+		"hostname": EnvSource("HOST"),
+		"retries":  FlagSource("--retries"),
+	}
+	sources.Deprecated(EnvSource("HOST"), "use HOSTNAME instead")
+	assert.Equal(t, Sources{
+		"hostname": "env:HOST (deprecated: use HOSTNAME instead)",
+		"retries":  "flag:--retries",
+	}, sources)
+}
+
+func TestConfigDump_Marshaler(t *testing.T) {
+	cases := map[string]struct {
+		input       ConfigDump
+		expected    FileMarshaler
+		expectedErr error
+	}{
+		"not requested": {},
+		"yaml": {
+			input:    ConfigDump{PrintConfig: optional.StringFrom("yaml")},
+			expected: YamlMarshaler(),
+		},
+		"yaml with sources": {
+			input: ConfigDump{
+				PrintConfig:        optional.StringFrom("yaml"),
+				PrintConfigSources: optional.BoolFrom(true),
+			},
+			expected: YamlMarshalerWithSources(Sources{}),
+		},
+		"json": {
+			input:    ConfigDump{PrintConfig: optional.StringFrom("json")},
+			expected: JsonMarshaler(),
+		},
+		"unsupported": {
+			input:       ConfigDump{PrintConfig: optional.StringFrom("toml")},
+			expectedErr: ErrUnsupportedConfigFormat,
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual, err := c.input.Marshaler(Sources{})
+			assert.Equal(t, c.expectedErr, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
@@ -11,3 +11,11 @@ type OptionSourceTrace struct {
 func (f OptionSourceTrace) Flags() []string {
 	return []string{"--flagTrace"}
 }
+
+// IsEnabled is true when tracing was turned on, it suits cmd_definitions.ServiceDesc.Debug
+func (f OptionSourceTrace) IsEnabled() (enabled bool) {
+	f.ConfigTrace.IfSet(func(value bool) {
+		enabled = value
+	})
+	return
+}
@@ -1,50 +1,307 @@
 package parse
 
 import (
-	"errors"
+	"fmt"
 	"github.com/wojnosystems/flick/pkg/cmd_definitions"
-	env_parser "github.com/wojnosystems/go-env/v2"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
 	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
 	"strings"
 )
 
+// EnvFlagParser finds the command that args run, and loads the options of the root, and of every command leading to
+// that one, from the environment and the flags given to each
 type EnvFlagParser struct {
-	service   cmd_definitions.ServiceDesc
-	envParser env_parser.Env
-	args      []string
+	service *cmd_definitions.ServiceDesc
+	options OptionUnmarshaler
+	args    []string
 }
 
-func (e *EnvFlagParser) Parse(callback func(path []string)) (exec Exec, err error) {
-	path := make([]string, 0, 10)
+// NewEnvFlagParser parses args, the words after the program name, as the commands of service. options loads the
+// options of the root and of each command, such as a BeforeAndAfter
+func NewEnvFlagParser(service *cmd_definitions.ServiceDesc, options OptionUnmarshaler, args []string) *EnvFlagParser {
+	return &EnvFlagParser{
+		service: service,
+		options: options,
+		args:    args,
+	}
+}
 
-	commands := flag_unmarshaler.Split(e.args)
-	var commandItem cmd_definitions.MethodDesc
-	for _, command := range commands {
-		path = append(path, command.CommandName)
-		callback(path)
-		var ok bool
-		commandItem, ok = e.service.Methods.Get(path...)
+// commandLevel is the root, or a command, on the way to the command that runs, along with the flags given to it
+type commandLevel struct {
+	method cmd_definitions.MethodDesc
+	group  flag_unmarshaler.Group
+}
+
+// Parse calls callback, which may be nil, with the path of each command as it is found, then loads the options of
+// each of them. args are split by SplitGNU, each command's options telling which of its flags take a value. Words
+// after a command without sub-commands are its arguments, and flags given among them are its own. Words after "--"
+// are always arguments. A word given to a command with sub-commands that is not one of them is an ErrUnknownCommand,
+// and too few or too many arguments for the command that runs an ErrArgumentCount. A flag given to a sub-command that
+// only one of its parents accepts is an ErrFlagOutOfScope, and one that none of them accept an ErrUnknownFlag.
+// Flags of options that a sub-command inherits from its parents set the options of both, see shareInheritedFlags
+func (e *EnvFlagParser) Parse(callback func(path []string)) (exec Exec, err error) {
+	groups, rest, err := SplitGNU(e.args, e.takesValue)
+	if err != nil {
+		return
+	}
+	levels := []commandLevel{{method: e.service.Root, group: groups[0]}}
+	for _, group := range groups[1:] {
+		current := &levels[len(levels)-1]
+		if !current.method.Meta.Commands.HasAny() {
+			exec.args = append(exec.args, group.CommandName)
+			current.group.Flags = append(current.group.Flags, group.Flags...)
+			continue
+		}
+		// commands are registered by the name they are declared with, aliases are looked up by that name
+		name := group.CommandName
+		if declaredName, _, ok := current.method.Meta.Commands.Find(name); ok {
+			name = declaredName
+		}
+		exec.path = append(exec.path, name)
+		if callback != nil {
+			callback(exec.path)
+		}
+		method, ok := e.service.Methods.Get(exec.path...)
 		if !ok {
-			err = errors.New("unsupported command: " + strings.Join(path, " "))
+			err = &ErrUnknownCommand{Path: exec.path}
 			return
 		}
-		if commandItem.ObjectMaker != nil {
-			config := commandItem.ObjectMaker()
-			exec.configObjects = append(exec.configObjects, config)
-		}
+		levels = append(levels, commandLevel{method: method, group: group})
 	}
-	err = e.envParser.Unmarshall(&config)
+	exec.args = append(exec.args, rest...)
+	declared := declaredFlags(levels)
+	err = validateFlagScopes(levels, declared, exec.path)
 	if err != nil {
 		return
 	}
-	err = flag_unmarshaler.New(&command).Unmarshal(&config)
+	err = validateArgumentCount(levels[len(levels)-1].method.Meta, exec.path, exec.args)
 	if err != nil {
 		return
 	}
+	shareInheritedFlags(levels, declared)
+
+	exec.configObjects = make([]interface{}, len(levels))
+	for i, level := range levels {
+		if level.method.ObjectMaker == nil {
+			continue
+		}
+		config := level.method.ObjectMaker()
+		if i == 0 {
+			err = e.options.UnmarshalRoot(config, level.group)
+		} else {
+			err = e.options.UnmarshalCmd(config, level.group)
+		}
+		if err != nil {
+			return
+		}
+		exec.configObjects[i] = config
+	}
+	return
+}
+
+// ErrUnknownCommand is returned when a word that is not one of the sub-commands is given to a command that has them
+type ErrUnknownCommand struct {
+	// Path is the command that was not found, its last name is the word given
+	Path []string
+}
+
+func (e *ErrUnknownCommand) Error() string {
+	return fmt.Sprintf(`unknown command "%s"`, strings.Join(e.Path, " "))
+}
+
+// ErrArgumentCount is returned when a command is given fewer arguments than its MinArgs, or more than its MaxArgs
+type ErrArgumentCount struct {
+	// Path is the command, it is empty for the root
+	Path    []string
+	MinArgs uint
+	MaxArgs uint
+	// Given is the number of arguments given
+	Given int
+}
+
+func (e *ErrArgumentCount) Error() string {
+	command := "the root command"
+	if len(e.Path) != 0 {
+		command = `"` + strings.Join(e.Path, " ") + `"`
+	}
+	takes := fmt.Sprintf("%d to %d arguments", e.MinArgs, e.MaxArgs)
+	if e.MinArgs == e.MaxArgs {
+		takes = fmt.Sprintf("%d arguments", e.MaxArgs)
+	}
+	return fmt.Sprintf("%s takes %s, but was given %d", command, takes, e.Given)
+}
+
+// validateArgumentCount checks that command, at path, was given between its MinArgs and MaxArgs args
+func validateArgumentCount(command dsl.Command, path, args []string) error {
+	if uint(len(args)) < command.MinArgs || uint(len(args)) > command.MaxArgs {
+		return &ErrArgumentCount{
+			Path:    path,
+			MinArgs: command.MinArgs,
+			MaxArgs: command.MaxArgs,
+			Given:   len(args),
+		}
+	}
+	return nil
+}
+
+// ErrUnknownFlag is returned for a flag that neither the command it was given to, nor any of its parents, declares
+type ErrUnknownFlag struct {
+	Flag string
+	// Path is the command that the flag was given to, it is empty for the root
+	Path []string
+}
+
+func (e *ErrUnknownFlag) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("unknown flag %s", e.Flag)
+	}
+	return fmt.Sprintf(`unknown flag %s for "%s"`, e.Flag, strings.Join(e.Path, " "))
+}
+
+// ErrFlagOutOfScope is returned for a flag given to a command that does not accept it, but one of its parents does,
+// such as a local option of the root given after a sub-command
+type ErrFlagOutOfScope struct {
+	Flag string
+	// Path is the command that the flag was given to
+	Path []string
+	// DeclaredBy is the path of the command that declares the flag, it is empty for the root
+	DeclaredBy []string
+}
+
+func (e *ErrFlagOutOfScope) Error() string {
+	declaredBy := "the root command"
+	if len(e.DeclaredBy) != 0 {
+		declaredBy = `"` + strings.Join(e.DeclaredBy, " ") + `"`
+	}
+	return fmt.Sprintf(`flag %s is not accepted by "%s", only by %s`, e.Flag, strings.Join(e.Path, " "), declaredBy)
+}
+
+// declaredFlags maps the flags of the options of each of levels to their field, levels without options have none
+func declaredFlags(levels []commandLevel) (declared []map[string]fieldFlags) {
+	declared = make([]map[string]fieldFlags, len(levels))
+	for i, level := range levels {
+		if level.method.ObjectMaker != nil {
+			declared[i] = flagsByName(level.method.ObjectMaker())
+		}
+	}
+	return
+}
+
+// validateFlagScopes checks that the flags given to each command are its own. Sub-commands inherit the persistent
+// options of their parents by embedding their struct, so a flag that only a parent declares is one of its local
+// options, an ErrFlagOutOfScope, and a flag that none of them declare is an ErrUnknownFlag. declared are the
+// declaredFlags of levels and path is the path of the last of them
+func validateFlagScopes(levels []commandLevel, declared []map[string]fieldFlags, path []string) error {
+	for i := range levels {
+		for _, flag := range levels[i].group.Flags {
+			if _, ok := lookupFlag(declared[i], flag.Key); ok {
+				continue
+			}
+			for parent := 0; parent < i; parent++ {
+				if _, ok := lookupFlag(declared[parent], flag.Key); ok {
+					return &ErrFlagOutOfScope{
+						Flag:       flag.Key,
+						Path:       path[:i],
+						DeclaredBy: path[:parent],
+					}
+				}
+			}
+			return &ErrUnknownFlag{
+				Flag: flag.Key,
+				Path: path[:i],
+			}
+		}
+	}
+	return nil
+}
+
+// shareInheritedFlags gives every level the flags of the options that it shares with the level they were given to,
+// which are those declared by the same embedded struct. Persistent options set the options of the command declaring
+// them and of its sub-commands alike, whether they are given before or after the sub-command. The flags stay in the
+// order they were given, so that the one given last wins
+func shareInheritedFlags(levels []commandLevel, declared []map[string]fieldFlags) {
+	shared := make([][]flag_unmarshaler.KeyValue, len(levels))
+	for to := range levels {
+		for from, level := range levels {
+			for _, flag := range level.group.Flags {
+				if from == to || sharesFlag(declared[from], declared[to], flag.Key) {
+					shared[to] = append(shared[to], flag)
+				}
+			}
+		}
+	}
+	for i := range levels {
+		levels[i].group.Flags = shared[i]
+	}
+}
+
+// sharesFlag is true when the field that key sets in a and b is declared by the same struct
+func sharesFlag(a, b map[string]fieldFlags, key string) bool {
+	fieldA, inA := lookupFlag(a, key)
+	fieldB, inB := lookupFlag(b, key)
+	return inA && inB && fieldA.declaredBy == fieldB.declaredBy
+}
+
+// lookupFlag finds the field that key, such as --verbose, --no-verbose or --include[0], sets among fields. ok is false
+// for flags that none of fields declare
+func lookupFlag(fields map[string]fieldFlags, key string) (field fieldFlags, ok bool) {
+	if base, _, isElement := listElement(key); isElement {
+		key = base
+	}
+	field, ok = fields[key]
+	if !ok && strings.HasPrefix(key, negatedFlagPrefix) {
+		// only switches can be negated, --no-host is not a flag of a string
+		field, ok = fields[longFlagPrefix+key[len(negatedFlagPrefix):]]
+		ok = ok && field.isSwitch
+	}
 	return
 }
 
+// takesValue is the TakesValue of the options of the command that the last of groups is given to
+func (e *EnvFlagParser) takesValue(groups []flag_unmarshaler.Group, flag string) bool {
+	method := e.service.Root
+	path := make([]string, 0, len(groups))
+	for _, group := range groups[1:] {
+		if !method.Meta.Commands.HasAny() {
+			break
+		}
+		name := group.CommandName
+		if declaredName, _, ok := method.Meta.Commands.Find(name); ok {
+			name = declaredName
+		}
+		path = append(path, name)
+		var ok bool
+		method, ok = e.service.Methods.Get(path...)
+		if !ok {
+			return false
+		}
+	}
+	if method.ObjectMaker == nil {
+		return false
+	}
+	return StructTakesValue(method.ObjectMaker())(groups, flag)
+}
+
+// Exec is the command that the parsed arguments run
 type Exec struct {
-	paths         []string
+	path          []string
+	args          []string
 	configObjects []interface{}
 }
+
+// Path is the names of the commands leading to, and including, the command that runs, e.g. server start. It is empty
+// when the root runs
+func (e Exec) Path() []string {
+	return e.path
+}
+
+// Args are the positional arguments given to the command that runs
+func (e Exec) Args() []string {
+	return e.args
+}
+
+// Options are the loaded options of the root, then of each command on Path, in order. Each is nil when the root or
+// command has no options
+func (e Exec) Options() []interface{} {
+	return e.configObjects
+}
@@ -1,16 +1,458 @@
 package parse
 
-import "testing"
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/go-optional/v2"
+	"os"
+	"testing"
+)
+
+type parserRootOptions struct {
+	Verbose optional.Bool `env:"PARSE_TEST_VERBOSE" flag:"verbose"`
+}
+
+type parserStartOptions struct {
+	HasBanana optional.Bool `env:"PARSE_TEST_BANANA" flag:"banana"`
+	Port      optional.Int  `flag:"port"`
+}
+
+func newParserService() *cmd_definitions.ServiceDesc {
+	serverCommands := dsl.NamedCommands{
+		"start": dsl.Command{Aliases: []string{"up"}, MaxArgs: 2},
+	}
+	service := &cmd_definitions.ServiceDesc{
+		Root: cmd_definitions.MethodDesc{
+			Meta: dsl.Command{
+				Commands: dsl.NamedCommands{
+					"server": dsl.Command{Commands: serverCommands},
+				},
+			},
+			ObjectMaker: func() interface{} {
+				return &parserRootOptions{}
+			},
+		},
+	}
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta: dsl.Command{Commands: serverCommands},
+	}, "server")
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		Meta: serverCommands["start"],
+		ObjectMaker: func() interface{} {
+			return &parserStartOptions{}
+		},
+	}, "server", "start")
+	return service
+}
 
 func TestEnvFlagParser_Parse(t *testing.T) {
 	cases := map[string]struct {
-		input EnvFlagParser
-	}{}
+		args            []string
+		env             map[string]string
+		expectedPath    []string
+		expectedArgs    []string
+		expectedOptions []interface{}
+		expectedErr     bool
+	}{
+		"sub-command option from env": {
+			args:         []string{"server", "start", "a"},
+			env:          map[string]string{"PARSE_TEST_BANANA": "true", "PARSE_TEST_VERBOSE": "true"},
+			expectedPath: []string{"server", "start"},
+			expectedArgs: []string{"a"},
+			expectedOptions: []interface{}{
+				&parserRootOptions{Verbose: optional.BoolFrom(true)},
+				nil,
+				&parserStartOptions{HasBanana: optional.BoolFrom(true)},
+			},
+		},
+		"flags override env": {
+			args:         []string{"server", "up", "--banana=false"},
+			env:          map[string]string{"PARSE_TEST_BANANA": "true"},
+			expectedPath: []string{"server", "start"},
+			expectedOptions: []interface{}{
+				&parserRootOptions{},
+				nil,
+				&parserStartOptions{HasBanana: optional.BoolFrom(false)},
+			},
+		},
+		"flags among arguments": {
+			args:         []string{"--verbose", "server", "start", "a", "--port=8", "b"},
+			expectedPath: []string{"server", "start"},
+			expectedArgs: []string{"a", "b"},
+			expectedOptions: []interface{}{
+				&parserRootOptions{Verbose: optional.BoolFrom(true)},
+				nil,
+				&parserStartOptions{Port: optional.IntFrom(8)},
+			},
+		},
+		"values after flags": {
+			args:         []string{"server", "start", "--port", "8", "a"},
+			expectedPath: []string{"server", "start"},
+			expectedArgs: []string{"a"},
+			expectedOptions: []interface{}{
+				&parserRootOptions{},
+				nil,
+				&parserStartOptions{Port: optional.IntFrom(8)},
+			},
+		},
+		"end of flags": {
+			args:         []string{"server", "start", "--banana", "--", "--port", "stop"},
+			expectedPath: []string{"server", "start"},
+			expectedArgs: []string{"--port", "stop"},
+			expectedOptions: []interface{}{
+				&parserRootOptions{},
+				nil,
+				&parserStartOptions{HasBanana: optional.BoolFrom(true)},
+			},
+		},
+		"root": {
+			expectedOptions: []interface{}{
+				&parserRootOptions{},
+			},
+		},
+		"unsupported command": {
+			args:        []string{"server", "stat"},
+			expectedErr: true,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			for name, value := range c.env {
+				require.NoError(t, os.Setenv(name, value))
+			}
+			defer func() {
+				for name := range c.env {
+					_ = os.Unsetenv(name)
+				}
+			}()
+			var found [][]string
+			parser := NewEnvFlagParser(newParserService(), &BeforeAndAfter{
+				BeforeFlags: []Unmarshaler{Env()},
+				Flags:       GroupFlags(),
+			}, c.args)
+			actual, err := parser.Parse(func(path []string) {
+				found = append(found, append([]string{}, path...))
+			})
+			if c.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedPath, actual.Path())
+			assert.Equal(t, c.expectedArgs, actual.Args())
+			assert.Equal(t, c.expectedOptions, actual.Options())
+			assert.Len(t, found, len(c.expectedPath))
+		})
+	}
+}
 
+func TestEnvFlagParser_ParseErrors(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		env         map[string]string
+		expectedErr error
+	}{
+		"unknown command": {
+			args:        []string{"server", "stat"},
+			expectedErr: &ErrUnknownCommand{Path: []string{"server", "stat"}},
+		},
+		"too many arguments": {
+			args: []string{"server", "start", "a", "b", "c"},
+			expectedErr: &ErrArgumentCount{
+				Path:    []string{"server", "start"},
+				MaxArgs: 2,
+				Given:   3,
+			},
+		},
+		"invalid flag value": {
+			args: []string{"server", "start", "--port", "abc"},
+			expectedErr: &ErrInvalidValue{
+				Source: "flag:--port",
+			},
+		},
+		"invalid environment variable": {
+			args: []string{"server", "start"},
+			env:  map[string]string{"PARSE_TEST_BANANA": "maybe"},
+			expectedErr: &ErrInvalidValue{
+				Source: "env:PARSE_TEST_BANANA",
+			},
+		},
+	}
 	for caseName, c := range cases {
 		t.Run(caseName, func(t *testing.T) {
-			t.Fail()
-			_ = c
+			for name, value := range c.env {
+				require.NoError(t, os.Setenv(name, value))
+			}
+			defer func() {
+				for name := range c.env {
+					_ = os.Unsetenv(name)
+				}
+			}()
+			parser := NewEnvFlagParser(newParserService(), &BeforeAndAfter{
+				BeforeFlags: []Unmarshaler{Env()},
+				Flags:       GroupFlags(),
+			}, c.args)
+			_, err := parser.Parse(nil)
+			if expected, ok := c.expectedErr.(*ErrInvalidValue); ok {
+				var actual *ErrInvalidValue
+				require.True(t, errors.As(err, &actual), "expected an ErrInvalidValue, got %v", err)
+				assert.Equal(t, expected.Source, actual.Source)
+				return
+			}
+			assert.Equal(t, c.expectedErr, err)
 		})
 	}
 }
+
+func TestErrArgumentCount_Error(t *testing.T) {
+	assert.Equal(t, `"server start" takes 1 to 2 arguments, but was given 3`, (&ErrArgumentCount{
+		Path:    []string{"server", "start"},
+		MinArgs: 1,
+		MaxArgs: 2,
+		Given:   3,
+	}).Error())
+	assert.Equal(t, "the root command takes 0 arguments, but was given 1", (&ErrArgumentCount{Given: 1}).Error())
+}
+
+// ParserPersistentOptions is exported, as the fields of unexported embedded structs cannot be set
+type ParserPersistentOptions struct {
+	Host optional.String `env:"PARSE_TEST_HOST" flag:"host" flag-short:"H"`
+}
+
+type scopedRootOptions struct {
+	ParserPersistentOptions
+	Secret optional.String `flag:"secret"`
+}
+
+type scopedServerOptions struct {
+	ParserPersistentOptions
+	Port optional.Int `flag:"port"`
+}
+
+// newScopedService has options the way flick generates them: the root's persistent options are a struct of their own,
+// which the root and its sub-commands embed, and its local options are only part of the root's
+func newScopedService() *cmd_definitions.ServiceDesc {
+	service := &cmd_definitions.ServiceDesc{
+		Root: cmd_definitions.MethodDesc{
+			Meta: dsl.Command{
+				Commands: dsl.NamedCommands{
+					"server": dsl.Command{},
+				},
+			},
+			ObjectMaker: func() interface{} {
+				return &scopedRootOptions{}
+			},
+		},
+	}
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		ObjectMaker: func() interface{} {
+			return &scopedServerOptions{}
+		},
+	}, "server")
+	return service
+}
+
+func TestEnvFlagParser_ParseScopes(t *testing.T) {
+	cases := map[string]struct {
+		args            []string
+		env             map[string]string
+		expectedOptions []interface{}
+		expectedErr     error
+	}{
+		"local option of the root": {
+			args: []string{"--secret=s", "server"},
+			expectedOptions: []interface{}{
+				&scopedRootOptions{Secret: optional.StringFrom("s")},
+				&scopedServerOptions{},
+			},
+		},
+		"local option of the root after a sub-command": {
+			args: []string{"server", "--secret", "s"},
+			expectedErr: &ErrFlagOutOfScope{
+				Flag:       "--secret",
+				Path:       []string{"server"},
+				DeclaredBy: []string{},
+			},
+		},
+		"negated option that is not a switch": {
+			args: []string{"server", "--no-host"},
+			expectedErr: &ErrUnknownFlag{
+				Flag: "--no-host",
+				Path: []string{"server"},
+			},
+		},
+		"option of a sub-command given to the root": {
+			args: []string{"--port=80", "server"},
+			expectedErr: &ErrUnknownFlag{
+				Flag: "--port",
+				Path: []string{},
+			},
+		},
+		"persistent option of the root after a sub-command": {
+			args: []string{"server", "-H", "example.com", "--port=80"},
+			expectedOptions: []interface{}{
+				&scopedRootOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("example.com")},
+				},
+				&scopedServerOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("example.com")},
+					Port:                    optional.IntFrom(80),
+				},
+			},
+		},
+		"persistent option of the root before a sub-command": {
+			args: []string{"--host=example.com", "server"},
+			expectedOptions: []interface{}{
+				&scopedRootOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("example.com")},
+				},
+				&scopedServerOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("example.com")},
+				},
+			},
+		},
+		"persistent option given last wins": {
+			args: []string{"--host=root.example.com", "server", "--host=server.example.com"},
+			expectedOptions: []interface{}{
+				&scopedRootOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("server.example.com")},
+				},
+				&scopedServerOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("server.example.com")},
+				},
+			},
+		},
+		"embedded options from env": {
+			args: []string{"server"},
+			env:  map[string]string{"PARSE_TEST_HOST": "env.example.com"},
+			expectedOptions: []interface{}{
+				&scopedRootOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("env.example.com")},
+				},
+				&scopedServerOptions{
+					ParserPersistentOptions: ParserPersistentOptions{Host: optional.StringFrom("env.example.com")},
+				},
+			},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			for name, value := range c.env {
+				require.NoError(t, os.Setenv(name, value))
+			}
+			defer func() {
+				for name := range c.env {
+					_ = os.Unsetenv(name)
+				}
+			}()
+			parser := NewEnvFlagParser(newScopedService(), &BeforeAndAfter{
+				BeforeFlags: []Unmarshaler{Env()},
+				Flags:       GroupFlags(),
+			}, c.args)
+			actual, err := parser.Parse(nil)
+			if c.expectedErr != nil {
+				assert.Equal(t, c.expectedErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedOptions, actual.Options())
+		})
+	}
+}
+
+// ParserAllCommandOptions are the options of the root the way flick generates them for "app", which sub-commands embed
+type ParserAllCommandOptions struct {
+	Host optional.String `env:"HOST" flag:"host"`
+}
+
+type parserServerStartOptions struct {
+	ParserAllCommandOptions
+	Banana optional.String `env:"BANANA" flag:"banana"`
+}
+
+func newInheritingService() *cmd_definitions.ServiceDesc {
+	server := dsl.Command{
+		Commands: dsl.NamedCommands{
+			"start": dsl.Command{},
+		},
+	}
+	service := &cmd_definitions.ServiceDesc{
+		Root: cmd_definitions.MethodDesc{
+			Meta: dsl.Command{
+				Commands: dsl.NamedCommands{
+					"server": server,
+				},
+			},
+			ObjectMaker: func() interface{} {
+				return &ParserAllCommandOptions{}
+			},
+		},
+	}
+	service.Methods.Put(cmd_definitions.MethodDesc{Meta: server}, "server")
+	service.Methods.Put(cmd_definitions.MethodDesc{
+		ObjectMaker: func() interface{} {
+			return &parserServerStartOptions{}
+		},
+	}, "server", "start")
+	return service
+}
+
+func TestEnvFlagParser_ParseInheritedOptions(t *testing.T) {
+	cases := map[string]struct {
+		args            []string
+		env             map[string]string
+		expectedOptions []interface{}
+	}{
+		"from env": {
+			args: []string{"server", "start"},
+			env:  map[string]string{"HOST": "env.example.com", "BANANA": "yellow"},
+			expectedOptions: []interface{}{
+				&ParserAllCommandOptions{Host: optional.StringFrom("env.example.com")},
+				nil,
+				&parserServerStartOptions{
+					ParserAllCommandOptions: ParserAllCommandOptions{Host: optional.StringFrom("env.example.com")},
+					Banana:                  optional.StringFrom("yellow"),
+				},
+			},
+		},
+		"flag after the sub-command": {
+			args: []string{"server", "start", "--host=flag.example.com"},
+			env:  map[string]string{"HOST": "env.example.com"},
+			expectedOptions: []interface{}{
+				&ParserAllCommandOptions{Host: optional.StringFrom("flag.example.com")},
+				nil,
+				&parserServerStartOptions{
+					ParserAllCommandOptions: ParserAllCommandOptions{Host: optional.StringFrom("flag.example.com")},
+				},
+			},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			for name, value := range c.env {
+				require.NoError(t, os.Setenv(name, value))
+			}
+			defer func() {
+				for name := range c.env {
+					_ = os.Unsetenv(name)
+				}
+			}()
+			parser := NewEnvFlagParser(newInheritingService(), &BeforeAndAfter{
+				BeforeFlags: []Unmarshaler{Env()},
+				Flags:       GroupFlags(),
+			}, c.args)
+			actual, err := parser.Parse(nil)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"server", "start"}, actual.Path())
+			assert.Equal(t, c.expectedOptions, actual.Options())
+		})
+	}
+}
+
+func TestErrUnknownFlag_Error(t *testing.T) {
+	assert.Equal(t, "unknown flag --port", (&ErrUnknownFlag{Flag: "--port"}).Error())
+	assert.Equal(t, `unknown flag --no-host for "server start"`, (&ErrUnknownFlag{Flag: "--no-host", Path: []string{"server", "start"}}).Error())
+}
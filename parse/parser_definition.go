@@ -1,6 +1,7 @@
 package parse
 
 import (
+	envParser "github.com/wojnosystems/go-env/v2"
 	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
 )
 
@@ -9,13 +10,25 @@ type OptionUnmarshaler interface {
 	UnmarshalCmd(into interface{}, cmdGroup flag_unmarshaler.Group) (err error)
 }
 
+// BeforeAndAfter loads the options of the root and of each command
 type BeforeAndAfter struct {
 	BeforeFlags []Unmarshaler
 	Flags       FlagUnmarshaler
 	AfterFlags  []Unmarshaler
+	// CmdEnv loads the environment variables of commands, before their Flags. It is EnvFrom(EnvReader) when nil
+	CmdEnv EnvUnmarshaler
+	// EnvReader is where CmdEnv reads the environment from when it is nil, such as the EnvReader given to
+	// cli.Commander.Switch. It is the environment of the process when nil
+	EnvReader envParser.EnvReader
+	// Precedence, when set, is used instead of BeforeFlags, Flags and AfterFlags, for the root and every command alike.
+	// Without it, the root loads BeforeFlags, then Flags, then AfterFlags, and commands load CmdEnv, then Flags
+	Precedence Precedence
 }
 
 func (b *BeforeAndAfter) UnmarshalRoot(into interface{}, rootGroup flag_unmarshaler.Group) (err error) {
+	if b.Precedence != nil {
+		return b.Precedence.Unmarshal(into, rootGroup)
+	}
 	if into != nil {
 		// load options
 		for _, unmarshaler := range b.BeforeFlags {
@@ -39,6 +52,22 @@ func (b *BeforeAndAfter) UnmarshalRoot(into interface{}, rootGroup flag_unmarsha
 }
 
 func (b *BeforeAndAfter) UnmarshalCmd(into interface{}, cmdGroup flag_unmarshaler.Group) (err error) {
+	if b.Precedence != nil {
+		return b.Precedence.Unmarshal(into, cmdGroup)
+	}
+	if into == nil {
+		return
+	}
+	cmdEnv := b.CmdEnv
+	if cmdEnv == nil && b.EnvReader != nil {
+		cmdEnv = EnvFrom(b.EnvReader)
+	} else if cmdEnv == nil {
+		cmdEnv = Env()
+	}
+	err = cmdEnv.Unmarshal(into)
+	if err != nil {
+		return
+	}
 	if b.Flags != nil {
 		err = b.Flags.Unmarshal(into, cmdGroup)
 	}
@@ -0,0 +1,156 @@
+package parse
+
+import (
+	envParser "github.com/wojnosystems/go-env/v2"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+)
+
+// Precedence lists where options are loaded from, lowest precedence first, so that later sources override earlier
+// ones. The values already in the options struct are the defaults, which every source overrides:
+//
+//	parse.Precedence{
+//		parse.ConfigFileSource(optional.StringFrom("/etc/myapp.yaml"), parse.Yaml(), false, sources),
+//		parse.Ungrouped(parse.EnvWithSources(sources)),
+//		parse.GroupFlagsWithSources(sources),
+//	}
+//
+// The same precedence is used for the root and every command, each with the flags given to it
+type Precedence []FlagUnmarshaler
+
+func (p Precedence) Unmarshal(into interface{}, group flag_unmarshaler.Group) (err error) {
+	if into == nil {
+		return
+	}
+	for _, source := range p {
+		err = source.Unmarshal(into, group)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Ungrouped is a source that does not depend on the flags of the command, such as the environment or a file
+func Ungrouped(unmarshaler Unmarshaler) FlagUnmarshaler {
+	return &ungrouped{
+		original: unmarshaler,
+	}
+}
+
+type ungrouped struct {
+	original Unmarshaler
+}
+
+func (u *ungrouped) Unmarshal(into interface{}, _ flag_unmarshaler.Group) error {
+	return u.original.Unmarshal(into)
+}
+
+// GroupFlags is the source of the flags given to the command
+func GroupFlags() FlagUnmarshaler {
+	return &groupFlags{}
+}
+
+// GroupFlagsWithSources is just like GroupFlags, but records each flag used in sources
+func GroupFlagsWithSources(sources Sources) FlagUnmarshaler {
+	return &groupFlags{
+		sources: sources,
+	}
+}
+
+type groupFlags struct {
+	sources Sources
+}
+
+func (f *groupFlags) Unmarshal(into interface{}, group flag_unmarshaler.Group) error {
+	if f.sources != nil {
+		return FlagsWithSources(&group, f.sources).Unmarshal(into)
+	}
+	return Flags(&group).Unmarshal(into)
+}
+
+// ConfigFileSource is the source of the configuration file. Its path is the ConfigFile path given by the environment or
+// the flags of the command, or defaultPath when neither gives one. Configurations that embed ConfigProfile read the
+// file with Profiles, for the profile given by the environment or the flags. Those are read before the file, no matter
+// where this source is in the precedence, so that one pass is enough. The root and every command read the same flat
+// file, each only reads the top level keys of its own fields and of the fields of the structs it embeds, so the keys of
+// other commands are left alone. When required is false, the file need not exist,
+// and no path at all is fine. sources may be nil, otherwise values set by the file are recorded as its FileSource, or
+// as its ProfileSource when they were set by the profile
+func ConfigFileSource(defaultPath optional.String, file FileUnmarshaler, required bool, sources Sources) FlagUnmarshaler {
+	return ConfigFileSourceFrom(&envParser.OsEnv{}, defaultPath, file, required, sources)
+}
+
+// ConfigFileSourceFrom is ConfigFileSource, reading the path and the profile from the variables of env rather than the
+// environment of the process
+func ConfigFileSourceFrom(env envParser.EnvReader, defaultPath optional.String, file FileUnmarshaler, required bool, sources Sources) FlagUnmarshaler {
+	return &configFileSource{
+		env:         env,
+		defaultPath: defaultPath,
+		file:        file,
+		required:    required,
+		sources:     sources,
+	}
+}
+
+type configFileSource struct {
+	env         envParser.EnvReader
+	defaultPath optional.String
+	file        FileUnmarshaler
+	required    bool
+	sources     Sources
+}
+
+func (c *configFileSource) Unmarshal(into interface{}, group flag_unmarshaler.Group) (err error) {
+	path, err := c.path(group)
+	if err != nil {
+		return
+	}
+	fileSource := ""
+	path.IfSet(func(p string) {
+		fileSource = FileSource(p)
+	})
+	traced := c.sources != nil && path.IsSet()
+	var file FileUnmarshaler = &sharedFile{original: c.file}
+	if selector, ok := into.(profileSelector); ok {
+		var profile optional.String
+		profile, err = c.profile(selector, group)
+		if err != nil {
+			return
+		}
+		if traced {
+			// records the values set by the profile as such, and the rest as fileSource
+			file = ProfilesWithSources(profile, file, c.sources, fileSource)
+			traced = false
+		} else {
+			file = Profiles(profile, file)
+		}
+	}
+	var unmarshaler Unmarshaler
+	if c.required {
+		unmarshaler = FileIsRequired(path, file)
+	} else {
+		unmarshaler = FileIsOptional(path, file)
+	}
+	if traced {
+		unmarshaler = c.sources.Traced(fileSource, unmarshaler)
+	}
+	return unmarshaler.Unmarshal(into)
+}
+
+// path bootstraps the ConfigFile from the environment and group, before anything else is loaded
+func (c *configFileSource) path(group flag_unmarshaler.Group) (path optional.String, err error) {
+	bootstrap := ConfigFile{
+		ConfigFilePath: c.defaultPath,
+	}
+	err = Unmarshall(&bootstrap, EnvFrom(c.env), Flags(&group))
+	return bootstrap.ConfigFilePath, err
+}
+
+// profile bootstraps the ConfigProfile of selector from the environment and group, like path. The profile already in
+// selector, if any, is the default
+func (c *configFileSource) profile(selector profileSelector, group flag_unmarshaler.Group) (profile optional.String, err error) {
+	bootstrap := *selector.configProfile()
+	err = Unmarshall(&bootstrap, EnvFrom(c.env), Flags(&group))
+	return bootstrap.Profile, err
+}
@@ -0,0 +1,274 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type layeredConfig struct {
+	Hostname optional.String   `yaml:"hostname" flag:"hostname" env:"PRECEDENCE_TEST_HOSTNAME"`
+	Delay    optional.Duration `yaml:"delay" flag:"delay" env:"PRECEDENCE_TEST_DELAY"`
+}
+
+// plainConfig has the plain types generated for options that are required or have a default
+type plainConfig struct {
+	Port int    `flag:"port"`
+	Host string `flag:"host"`
+}
+
+func TestBeforeAndAfter_Precedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "precedence")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	systemFile := filepath.Join(dir, "system.yaml")
+	userFile := filepath.Join(dir, "user.yaml")
+	require.NoError(t, ioutil.WriteFile(systemFile, []byte("hostname: system.example.com\ndelay: 1s\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(userFile, []byte("hostname: user.example.com\n"), 0600))
+
+	cases := map[string]struct {
+		env             map[string]string
+		flags           []flag_unmarshaler.KeyValue
+		requireUserFile bool
+		expected        layeredConfig
+		expectedSources Sources
+		expectedErr     error
+	}{
+		"system file overrides defaults": {
+			expected: layeredConfig{
+				Hostname: optional.StringFrom("system.example.com"),
+				Delay:    optional.DurationFrom(time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:system.yaml",
+				"delay":    "file:system.yaml",
+			},
+		},
+		"user file overrides system file": {
+			env: map[string]string{
+				"CONFIG_FILE_PATH": userFile,
+			},
+			expected: layeredConfig{
+				Hostname: optional.StringFrom("user.example.com"),
+				Delay:    optional.DurationFrom(time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:user.yaml",
+				"delay":    "file:system.yaml",
+			},
+		},
+		"user file path from a flag": {
+			env: map[string]string{
+				"CONFIG_FILE_PATH": filepath.Join(dir, "missing.yaml"),
+			},
+			flags: []flag_unmarshaler.KeyValue{
+				{Key: "-c", Value: userFile},
+			},
+			expected: layeredConfig{
+				Hostname: optional.StringFrom("user.example.com"),
+				Delay:    optional.DurationFrom(time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:user.yaml",
+				"delay":    "file:system.yaml",
+			},
+		},
+		"env overrides files and flags override env": {
+			env: map[string]string{
+				"CONFIG_FILE_PATH":         userFile,
+				"PRECEDENCE_TEST_HOSTNAME": "env.example.com",
+				"PRECEDENCE_TEST_DELAY":    "2s",
+			},
+			flags: []flag_unmarshaler.KeyValue{
+				{Key: "--delay", Value: "3s"},
+			},
+			expected: layeredConfig{
+				Hostname: optional.StringFrom("env.example.com"),
+				Delay:    optional.DurationFrom(3 * time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "env:PRECEDENCE_TEST_HOSTNAME",
+				"delay":    "flag:--delay",
+			},
+		},
+		"required file without a path": {
+			requireUserFile: true,
+			expected: layeredConfig{
+				Hostname: optional.StringFrom("system.example.com"),
+				Delay:    optional.DurationFrom(time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:system.yaml",
+				"delay":    "file:system.yaml",
+			},
+			expectedErr: ErrNoFile,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			for name, value := range c.env {
+				require.NoError(t, os.Setenv(name, value))
+			}
+			defer func() {
+				for name := range c.env {
+					_ = os.Unsetenv(name)
+				}
+			}()
+			// the root and commands load the same way
+			for _, level := range []string{"root", "command"} {
+				actual := layeredConfig{
+					Hostname: optional.StringFrom("localhost"),
+				}
+				sources := NewSources()
+				require.NoError(t, sources.Defaults(&actual))
+				options := &BeforeAndAfter{
+					Precedence: Precedence{
+						Ungrouped(sources.Traced(FileSource(systemFile), FileIsOptional(optional.StringFrom(systemFile), Yaml()))),
+						ConfigFileSource(optional.String{}, Yaml(), c.requireUserFile, sources),
+						Ungrouped(EnvWithSources(sources)),
+						GroupFlagsWithSources(sources),
+					},
+				}
+				group := flag_unmarshaler.Group{Flags: c.flags}
+				var err error
+				if level == "root" {
+					err = options.UnmarshalRoot(&actual, group)
+				} else {
+					group.CommandName = "server"
+					err = options.UnmarshalCmd(&actual, group)
+				}
+				assert.Equal(t, c.expectedErr, err, level)
+				assert.Equal(t, c.expected, actual, level)
+				assert.Equal(t, c.expectedSources, sources, level)
+			}
+		})
+	}
+}
+
+func TestConfigFileSource_Profile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "precedence")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("default:\n  hostname: localhost\n  delay: 1s\nprofiles:\n  prod:\n    hostname: example.com\n"), 0600))
+
+	var actual profiledConfig
+	sources := NewSources()
+	err = Precedence{
+		ConfigFileSource(optional.StringFrom(file), Yaml(), true, sources),
+		GroupFlagsWithSources(sources),
+	}.Unmarshal(&actual, flag_unmarshaler.Group{
+		Flags: []flag_unmarshaler.KeyValue{{Key: "--profile", Value: "prod"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, profiledConfig{
+		ConfigProfile: ConfigProfile{Profile: optional.StringFrom("prod")},
+		Hostname:      optional.StringFrom("example.com"),
+		Delay:         optional.DurationFrom(time.Second),
+	}, actual)
+	assert.Equal(t, Sources{
+		"hostname":              "file:config.yaml#profiles.prod",
+		"delay":                 "file:config.yaml",
+		"ConfigProfile.Profile": "flag:--profile",
+	}, sources)
+}
+
+func TestConfigFileSourceFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "precedence")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("default:\n  hostname: localhost\nprofiles:\n  prod:\n    hostname: example.com\n"), 0600))
+	env := mapEnv{
+		"CONFIG_FILE_PATH": file,
+		"PROFILE":          "prod",
+		"Delay":            "2s",
+	}
+
+	var actual profiledConfig
+	options := &BeforeAndAfter{
+		Precedence: Precedence{
+			ConfigFileSourceFrom(env, optional.String{}, Yaml(), true, nil),
+			Ungrouped(EnvFrom(env)),
+		},
+	}
+	err = options.UnmarshalCmd(&actual, flag_unmarshaler.Group{})
+	require.NoError(t, err)
+	assert.Equal(t, profiledConfig{
+		ConfigProfile: ConfigProfile{Profile: optional.StringFrom("prod")},
+		Hostname:      optional.StringFrom("example.com"),
+		Delay:         optional.DurationFrom(2 * time.Second),
+	}, actual)
+}
+
+func TestBeforeAndAfter_EnvReader(t *testing.T) {
+	cases := map[string]struct {
+		env      mapEnv
+		expected plainConfig
+	}{
+		"set": {
+			env:      mapEnv{"Port": "8080", "Host": "example.com"},
+			expected: plainConfig{Port: 8080, Host: "example.com"},
+		},
+		"defaults are kept": {
+			env:      mapEnv{},
+			expected: plainConfig{Port: 80, Host: "localhost"},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := plainConfig{Port: 80, Host: "localhost"}
+			options := &BeforeAndAfter{EnvReader: c.env}
+			err := options.UnmarshalCmd(&actual, flag_unmarshaler.Group{})
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+// SharedRootOptions is embedded by sharedStartOptions, like the generated options of a sub-command embed those it
+// inherits
+type SharedRootOptions struct {
+	Hostname optional.String `yaml:"hostname"`
+}
+
+type sharedStartOptions struct {
+	SharedRootOptions
+	Port  optional.Int `yaml:"port"`
+	Debug optional.Bool
+}
+
+func TestConfigFileSource_SharedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "precedence")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("hostname: example.com\nport: 8080\nDebug: true\nretries: 3\n"), 0600))
+	source := Precedence{ConfigFileSource(optional.StringFrom(file), Yaml(), true, nil)}
+
+	var root SharedRootOptions
+	require.NoError(t, source.Unmarshal(&root, flag_unmarshaler.Group{}))
+	assert.Equal(t, SharedRootOptions{Hostname: optional.StringFrom("example.com")}, root)
+
+	var start sharedStartOptions
+	require.NoError(t, source.Unmarshal(&start, flag_unmarshaler.Group{}))
+	assert.Equal(t, sharedStartOptions{
+		SharedRootOptions: SharedRootOptions{Hostname: optional.StringFrom("example.com")},
+		Port:              optional.IntFrom(8080),
+		Debug:             optional.BoolFrom(true),
+	}, start)
+}
@@ -0,0 +1,188 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/goccy/go-yaml"
+	"github.com/wojnosystems/go-optional/v2"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	sourceFilePrefix   = "file:"
+	profileDefaultKey  = "default"
+	profileSectionsKey = "profiles"
+)
+
+// ConfigProfile is a convenience structure that selects a profile of the configuration file. Embed this into your
+// configuration struct to get --profile support, and read the file with its File
+type ConfigProfile struct {
+	Profile optional.String `env:"PROFILE" flag:"profile" usage:"NAME" help:"name of the profile in the configuration file to use"`
+}
+
+func (f ConfigProfile) Flags() []string {
+	return []string{"--profile"}
+}
+
+// File is Profiles, for the profile selected when the file is read, rather than when File is called. ConfigFileSource
+// and UnmarshalWithFile select the profile of configurations that embed ConfigProfile themselves, as the file is read
+// before the environment and flags that select it
+func (f *ConfigProfile) File(unmarshaler FileUnmarshaler) FileUnmarshaler {
+	return &profiles{
+		profile:  &f.Profile,
+		original: unmarshaler,
+	}
+}
+
+// configProfile is promoted to the configurations that embed ConfigProfile, see profileSelector
+func (f *ConfigProfile) configProfile() *ConfigProfile {
+	return f
+}
+
+// profileSelector is implemented by configurations that embed ConfigProfile
+type profileSelector interface {
+	configProfile() *ConfigProfile
+}
+
+// ErrUnknownProfile is returned when the selected profile is not in the configuration file
+type ErrUnknownProfile struct {
+	Name string
+	// Available are the profiles that the file has
+	Available []string
+}
+
+func (e *ErrUnknownProfile) Error() string {
+	if len(e.Available) == 0 {
+		return fmt.Sprintf(`unknown profile "%s", the configuration file has no profiles`, e.Name)
+	}
+	return fmt.Sprintf(`unknown profile "%s", the configuration file has: %s`, e.Name, strings.Join(e.Available, ", "))
+}
+
+// FileSource is how Sources describes a value set by the file at path: "file:config.yaml"
+func FileSource(path string) string {
+	return sourceFilePrefix + filepath.Base(path)
+}
+
+// ProfileSource is how Sources describes a value set by profile of the file described by fileSource:
+// "file:config.yaml#profiles.prod"
+func ProfileSource(fileSource, profile string) string {
+	return fileSource + "#" + profileSectionsKey + "." + profile
+}
+
+// Profiles reads a yaml configuration file with a default section and named profiles:
+//
+//	default:
+//	  hostname: localhost
+//	profiles:
+//	  prod:
+//	    hostname: example.com
+//
+// The default section is unmarshalled first, then the profile is unmarshalled on top of it, when it is set. Files
+// without either key are entirely defaults. Each section is passed to unmarshaler as a yaml document of its own
+func Profiles(profile optional.String, unmarshaler FileUnmarshaler) FileUnmarshaler {
+	return &profiles{
+		profile:  &profile,
+		original: unmarshaler,
+	}
+}
+
+// ProfilesWithSources is just like Profiles, but records the values set by the default section as fileSource, and
+// those set by the profile as ProfileSource(fileSource, profile)
+func ProfilesWithSources(profile optional.String, unmarshaler FileUnmarshaler, sources Sources, fileSource string) FileUnmarshaler {
+	return &profiles{
+		profile:    &profile,
+		original:   unmarshaler,
+		sources:    sources,
+		fileSource: fileSource,
+	}
+}
+
+type profiles struct {
+	// profile is read when the file is, so that File sees the profile selected after it was called
+	profile    *optional.String
+	original   FileUnmarshaler
+	sources    Sources
+	fileSource string
+}
+
+func (p *profiles) UnmarshalFile(r io.Reader, config interface{}) (err error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	var sections map[string]interface{}
+	err = yaml.Unmarshal(content, &sections)
+	if err != nil {
+		return
+	}
+	_, hasDefault := sections[profileDefaultKey]
+	_, hasProfiles := sections[profileSectionsKey]
+	var defaults interface{} = sections
+	var named map[string]interface{}
+	if hasDefault || hasProfiles {
+		defaults = sections[profileDefaultKey]
+		if hasProfiles {
+			var ok bool
+			named, ok = sections[profileSectionsKey].(map[string]interface{})
+			if !ok && sections[profileSectionsKey] != nil {
+				return fmt.Errorf(`"%s" must map profile names to their configuration`, profileSectionsKey)
+			}
+		}
+	}
+
+	err = p.unmarshalSection(p.fileSource, defaults, config)
+	if err != nil {
+		return
+	}
+	p.profile.IfSet(func(name string) {
+		section, ok := named[name]
+		if !ok {
+			err = &ErrUnknownProfile{Name: name, Available: sortedKeys(named)}
+			return
+		}
+		err = p.unmarshalSection(ProfileSource(p.fileSource, name), section, config)
+	})
+	return
+}
+
+// unmarshalSection unmarshals section of the file into config, recording source for what it sets when tracing
+func (p *profiles) unmarshalSection(source string, section interface{}, config interface{}) (err error) {
+	if section == nil {
+		return
+	}
+	content, err := yaml.Marshal(section)
+	if err != nil {
+		return
+	}
+	var unmarshaler Unmarshaler = &fileContent{
+		content:  content,
+		original: p.original,
+	}
+	if p.sources != nil {
+		unmarshaler = p.sources.Traced(source, unmarshaler)
+	}
+	return unmarshaler.Unmarshal(config)
+}
+
+// fileContent unmarshals content as if it were read from a file
+type fileContent struct {
+	content  []byte
+	original FileUnmarshaler
+}
+
+func (f *fileContent) Unmarshal(config interface{}) error {
+	return f.original.UnmarshalFile(bytes.NewReader(f.content), config)
+}
+
+func sortedKeys(m map[string]interface{}) (out []string) {
+	out = make([]string, 0, len(m))
+	for key := range m {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return
+}
@@ -0,0 +1,115 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wojnosystems/go-optional/v2"
+	"testing"
+	"time"
+)
+
+func TestProfiles(t *testing.T) {
+	sectioned := []byte(`---
+default:
+  hostname: localhost
+  delay: 5s
+profiles:
+  dev:
+  prod:
+    hostname: example.com
+`)
+	cases := map[string]struct {
+		file            []byte
+		profile         optional.String
+		expected        appConfig
+		expectedSources Sources
+		expectedErr     string
+	}{
+		"defaults only": {
+			file: sectioned,
+			expected: appConfig{
+				Hostname: optional.StringFrom("localhost"),
+				Delay:    optional.DurationFrom(5 * time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:config.yaml",
+				"delay":    "file:config.yaml",
+			},
+		},
+		"profile overlays defaults": {
+			file:    sectioned,
+			profile: optional.StringFrom("prod"),
+			expected: appConfig{
+				Hostname: optional.StringFrom("example.com"),
+				Delay:    optional.DurationFrom(5 * time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:config.yaml#profiles.prod",
+				"delay":    "file:config.yaml",
+			},
+		},
+		"empty profile": {
+			file:    sectioned,
+			profile: optional.StringFrom("dev"),
+			expected: appConfig{
+				Hostname: optional.StringFrom("localhost"),
+				Delay:    optional.DurationFrom(5 * time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:config.yaml",
+				"delay":    "file:config.yaml",
+			},
+		},
+		"unknown profile": {
+			file:    sectioned,
+			profile: optional.StringFrom("staging"),
+			expected: appConfig{
+				Hostname: optional.StringFrom("localhost"),
+				Delay:    optional.DurationFrom(5 * time.Second),
+			},
+			expectedSources: Sources{
+				"hostname": "file:config.yaml",
+				"delay":    "file:config.yaml",
+			},
+			expectedErr: `unknown profile "staging", the configuration file has: dev, prod`,
+		},
+		"file without sections": {
+			file: []byte(`---
+hostname: localhost
+`),
+			expected: appConfig{
+				Hostname: optional.StringFrom("localhost"),
+			},
+			expectedSources: Sources{
+				"hostname": "file:config.yaml",
+			},
+		},
+		"profile of file without sections": {
+			file: []byte(`---
+hostname: localhost
+`),
+			profile: optional.StringFrom("prod"),
+			expected: appConfig{
+				Hostname: optional.StringFrom("localhost"),
+			},
+			expectedSources: Sources{
+				"hostname": "file:config.yaml",
+			},
+			expectedErr: `unknown profile "prod", the configuration file has no profiles`,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			var actual appConfig
+			sources := NewSources()
+			err := Unmarshall(&actual,
+				newFileAsBytes(c.file, ProfilesWithSources(c.profile, Yaml(), sources, FileSource("/home/me/.myapp/config.yaml"))))
+			if c.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, c.expectedErr)
+			}
+			assert.Equal(t, c.expected, actual)
+			assert.Equal(t, c.expectedSources, sources)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package parse
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrMissingRequired is returned when required options were not set by any source and could not be prompted for
+type ErrMissingRequired struct {
+	// Options are the missing options, as they can be set: by flag, or by environment variable when they have no flag
+	Options []string
+}
+
+func (e *ErrMissingRequired) Error() string {
+	return "missing required options: " + strings.Join(e.Options, ", ")
+}
+
+// Prompter asks for the values of required options that no other source set
+type Prompter struct {
+	In  io.Reader
+	Out io.Writer
+	// Interactive is true when someone is there to answer, when false missing options are reported without prompting
+	Interactive bool
+	// ReadSecret reads a line from In without showing what is typed. It may be nil, in which case secrets are read
+	// like any other value
+	ReadSecret func() (string, error)
+
+	reader *bufio.Reader
+}
+
+// Required is an Unmarshaler that prompts for every option in options that is Required, but has no value in the
+// configuration. Fields are found by the option Name, as the generated options structs name them. Run it after every
+// other source so that it only prompts for what is really missing. When the Prompter is not Interactive, it returns
+// an ErrMissingRequired instead
+func (p *Prompter) Required(options []dsl.Option) Unmarshaler {
+	return &promptRequired{
+		prompter: p,
+		options:  options,
+	}
+}
+
+type promptRequired struct {
+	prompter *Prompter
+	options  []dsl.Option
+}
+
+func (r *promptRequired) Unmarshal(config interface{}) (err error) {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config must be a pointer to a struct")
+	}
+	missing := &ErrMissingRequired{}
+	for _, option := range r.options {
+		field := v.Elem().FieldByName(option.Name)
+		if !option.Required || !field.IsValid() || isSetValue(field) {
+			continue
+		}
+		if !r.prompter.Interactive {
+			missing.Options = append(missing.Options, describeOption(option))
+			continue
+		}
+		err = r.prompter.prompt(option, field)
+		if err != nil {
+			return
+		}
+	}
+	if len(missing.Options) != 0 {
+		return missing
+	}
+	return
+}
+
+// prompt asks for option until the answer can be stored in field
+func (p *Prompter) prompt(option dsl.Option, field reflect.Value) (err error) {
+	if p.reader == nil {
+		p.reader = bufio.NewReader(p.In)
+	}
+	option.Description.IfSet(func(description string) {
+		_, _ = fmt.Fprintln(p.Out, description)
+	})
+	for i, choice := range option.Enum {
+		_, _ = fmt.Fprintf(p.Out, "  %d) %s\n", i+1, choice)
+	}
+	for {
+		_, _ = fmt.Fprint(p.Out, promptLabel(option))
+		var answer string
+		answer, err = p.readLine(option.Secret)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", option.Name, err)
+		}
+		var reason string
+		answer, reason = checkAnswer(option, answer)
+		if reason == "" {
+			var handled bool
+			handled, err = defaultYamlParseRegistry.SetValue(field.Addr().Interface(), answer)
+			if !handled {
+				return fmt.Errorf("unable to prompt for %s, its type is not supported", option.Name)
+			}
+			if err == nil {
+				return
+			}
+			reason = err.Error()
+		}
+		_, _ = fmt.Fprintln(p.Out, "invalid value: "+reason)
+	}
+}
+
+func (p *Prompter) readLine(secret bool) (line string, err error) {
+	if secret && p.ReadSecret != nil {
+		line, err = p.ReadSecret()
+		// the newline typed was not shown either
+		_, _ = fmt.Fprintln(p.Out)
+		return
+	}
+	line, err = p.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// promptLabel is what is shown before the answer: "Host <HOST>: ", or "Profile [1-2]: " for options with an Enum
+func promptLabel(option dsl.Option) string {
+	label := option.Name
+	option.Usage.IfSet(func(usage string) {
+		label += " <" + usage + ">"
+	})
+	if len(option.Enum) != 0 {
+		label += fmt.Sprintf(" [1-%d]", len(option.Enum))
+	}
+	return label + ": "
+}
+
+// checkAnswer resolves the number of an Enum choice to its value. reason explains why answer is not acceptable
+func checkAnswer(option dsl.Option, answer string) (value, reason string) {
+	value = strings.TrimSpace(answer)
+	if value == "" {
+		return "", "a value is required"
+	}
+	if len(option.Enum) == 0 {
+		return
+	}
+	if number, err := strconv.Atoi(value); err == nil && number >= 1 && number <= len(option.Enum) {
+		return option.Enum[number-1], ""
+	}
+	for _, choice := range option.Enum {
+		if choice == value {
+			return
+		}
+	}
+	return "", fmt.Sprintf(`"%s" is not one of: %s`, value, strings.Join(option.Enum, ", "))
+}
+
+// isSetValue is true when field has a value: optional types that are set, or any other type that is not zero
+func isSetValue(field reflect.Value) bool {
+	if isSet := field.MethodByName("IsSet"); isSet.IsValid() {
+		return isSet.Call(nil)[0].Bool()
+	}
+	return !field.IsZero()
+}
+
+// describeOption is how users set option: its flag, or its environment variable when it has no flag
+func describeOption(option dsl.Option) string {
+	if names := option.Flag.Names(); len(names) != 0 {
+		return names[0]
+	}
+	if option.Env.Name != "" {
+		return option.Env.Name
+	}
+	return option.Name
+}
+
+// isTerminal is true when f is a terminal, rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
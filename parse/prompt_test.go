@@ -0,0 +1,117 @@
+package parse
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/go-optional/v2"
+	"strings"
+	"testing"
+	"time"
+)
+
+type promptedConfig struct {
+	Hostname optional.String
+	Port     int
+	Profile  string
+	Password string
+	Delay    time.Duration
+}
+
+var promptedOptions = []dsl.Option{
+	{Name: "Hostname", Type: "string", Required: true, Usage: optional.StringFrom("HOST"), Flag: dsl.FlagDef{Name: "hostname"}},
+	{Name: "Port", Type: "int", Required: true, Description: optional.StringFrom("port to connect to"), Env: dsl.EnvDef{Name: "PORT"}},
+	{Name: "Profile", Type: "string", Required: true, Enum: []string{"dev", "prod"}},
+	{Name: "Password", Type: "string", Required: true, Secret: true},
+	{Name: "Delay", Type: "duration"},
+}
+
+func TestPrompter_Required(t *testing.T) {
+	cases := map[string]struct {
+		config         promptedConfig
+		interactive    bool
+		input          string
+		expected       promptedConfig
+		expectedOutput string
+		expectedErr    string
+	}{
+		"prompts for each missing option": {
+			interactive: true,
+			input:       "example.com\n8080\n2\n",
+			expected: promptedConfig{
+				Hostname: optional.StringFrom("example.com"),
+				Port:     8080,
+				Profile:  "prod",
+				Password: "hunter2",
+			},
+			expectedOutput: "Hostname <HOST>: port to connect to\nPort: " +
+				"  1) dev\n  2) prod\nProfile [1-2]: Password: \n",
+		},
+		"only prompts for what is missing": {
+			config: promptedConfig{
+				Hostname: optional.StringFrom("example.com"),
+				Port:     80,
+				Profile:  "dev",
+			},
+			interactive: true,
+			expected: promptedConfig{
+				Hostname: optional.StringFrom("example.com"),
+				Port:     80,
+				Profile:  "dev",
+				Password: "hunter2",
+			},
+			expectedOutput: "Password: \n",
+		},
+		"prompts again for invalid values": {
+			config: promptedConfig{
+				Hostname: optional.StringFrom("example.com"),
+				Password: "set",
+			},
+			interactive: true,
+			input:       "\neighty\n80\nstaging\ndev\n",
+			expected: promptedConfig{
+				Hostname: optional.StringFrom("example.com"),
+				Port:     80,
+				Profile:  "dev",
+				Password: "set",
+			},
+			expectedOutput: "port to connect to\nPort: invalid value: a value is required\n" +
+				"Port: invalid value: strconv.ParseInt: parsing \"eighty\": invalid syntax\nPort: " +
+				"  1) dev\n  2) prod\nProfile [1-2]: invalid value: \"staging\" is not one of: dev, prod\nProfile [1-2]: ",
+		},
+		"input ends": {
+			interactive:    true,
+			input:          "example.com\n",
+			expected:       promptedConfig{Hostname: optional.StringFrom("example.com")},
+			expectedOutput: "Hostname <HOST>: port to connect to\nPort: ",
+			expectedErr:    "unable to read Port: EOF",
+		},
+		"not interactive": {
+			config:      promptedConfig{Port: 80},
+			expected:    promptedConfig{Port: 80},
+			expectedErr: "missing required options: --hostname, Profile, Password",
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			prompter := &Prompter{
+				In:          strings.NewReader(c.input),
+				Out:         output,
+				Interactive: c.interactive,
+				ReadSecret: func() (string, error) {
+					return "hunter2", nil
+				},
+			}
+			actual := c.config
+			err := Unmarshall(&actual, prompter.Required(promptedOptions))
+			if c.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, c.expectedErr)
+			}
+			assert.Equal(t, c.expected, actual)
+			assert.Equal(t, c.expectedOutput, output.String())
+		})
+	}
+}
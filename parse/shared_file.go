@@ -0,0 +1,69 @@
+package parse
+
+import (
+	"bytes"
+	"github.com/goccy/go-yaml"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// sharedFile reads a configuration file that the root and every command read their options from, see ConfigFileSource.
+// original is only given the keys of the fields of config, so that the keys of other commands are left for them. The
+// structs that config embeds are given their keys separately, as if each of them read the file, so that inherited
+// options are read from the top level of the file too
+type sharedFile struct {
+	original FileUnmarshaler
+}
+
+func (s *sharedFile) UnmarshalFile(r io.Reader, config interface{}) (err error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return s.original.UnmarshalFile(bytes.NewReader(content), config)
+	}
+	var keys map[string]interface{}
+	err = yaml.Unmarshal(content, &keys)
+	if err != nil {
+		return
+	}
+	return s.unmarshalStruct(keys, v)
+}
+
+// unmarshalStruct gives original the keys of the fields of the struct that v points to, then does the same for each
+// struct it embeds
+func (s *sharedFile) unmarshalStruct(keys map[string]interface{}, v reflect.Value) (err error) {
+	own := make(map[string]interface{})
+	structType := v.Elem().Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			err = s.unmarshalStruct(keys, v.Elem().Field(i).Addr())
+			if err != nil {
+				return
+			}
+			continue
+		}
+		// the names the yaml decoder finds the field by
+		for _, key := range []string{strings.Split(field.Tag.Get("yaml"), ",")[0], field.Name} {
+			if value, ok := keys[key]; ok && key != "" {
+				own[key] = value
+			}
+		}
+	}
+	if len(own) == 0 {
+		return
+	}
+	content, err := yaml.Marshal(own)
+	if err != nil {
+		return
+	}
+	return s.original.UnmarshalFile(bytes.NewReader(content), v.Interface())
+}
@@ -0,0 +1,178 @@
+package parse
+
+import (
+	"fmt"
+	envParser "github.com/wojnosystems/go-env/v2"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	into_struct "github.com/wojnosystems/go-into-struct"
+)
+
+const (
+	sourceDefault    = "default"
+	sourceEnvPrefix  = "env:"
+	sourceFlagPrefix = "flag:"
+)
+
+// Sources records where each value in a configuration was set, keyed by the path to the value, e.g.: server.connectTimeout
+// Sources are described the same way that the trace output displays them: "default", "file:config.yaml", "env:CONNECT_TIMEOUT", "flag:--profile"
+type Sources map[string]string
+
+func NewSources() Sources {
+	return make(Sources)
+}
+
+// Defaults records every value already set in config as coming from the default. Call this before running any Unmarshaler
+func (s Sources) Defaults(config interface{}) (err error) {
+	tree, err := newConfigTree(config, false)
+	if err != nil {
+		return
+	}
+	values := make(map[string]string)
+	tree.flatten(values)
+	for path := range values {
+		s[path] = sourceDefault
+	}
+	return
+}
+
+// EnvSource is how Sources describes a value set by the environment variable envName: "env:CONNECT_TIMEOUT"
+func EnvSource(envName string) string {
+	return sourceEnvPrefix + envName
+}
+
+// FlagSource is how Sources describes a value set by flagName: "flag:--profile"
+func FlagSource(flagName string) string {
+	return sourceFlagPrefix + flagName
+}
+
+// Deprecated notes message, e.g. "use --host instead", on every value set by source, so that the trace shows which
+// values were set by deprecated flags or environment variables
+func (s Sources) Deprecated(source, message string) {
+	for path, recorded := range s {
+		if recorded == source {
+			s[path] = recorded + " (deprecated: " + message + ")"
+		}
+	}
+}
+
+// Traced wraps unmarshaler so that every value it changes is recorded as having come from source.
+// Use this for sources that cannot report what they set themselves, such as files: s.Traced("file:config.yaml", FileIsOptional(...))
+func (s Sources) Traced(source string, unmarshaler Unmarshaler) Unmarshaler {
+	return &tracedUnmarshaler{
+		sources:  s,
+		source:   source,
+		original: unmarshaler,
+	}
+}
+
+type tracedUnmarshaler struct {
+	sources  Sources
+	source   string
+	original Unmarshaler
+}
+
+func (t *tracedUnmarshaler) Unmarshal(config interface{}) (err error) {
+	before, err := flattenConfig(config)
+	if err != nil {
+		return
+	}
+	err = t.original.Unmarshal(config)
+	if err != nil {
+		return
+	}
+	after, err := flattenConfig(config)
+	if err != nil {
+		return
+	}
+	for path, value := range after {
+		if previous, ok := before[path]; !ok || previous != value {
+			t.sources[path] = t.source
+		}
+	}
+	return
+}
+
+func flattenConfig(config interface{}) (out map[string]string, err error) {
+	tree, err := newConfigTree(config, false)
+	if err != nil {
+		return
+	}
+	out = make(map[string]string)
+	tree.flatten(out)
+	return
+}
+
+// sourceReceiver records the values reported by the env and flag parsers
+type sourceReceiver struct {
+	sources Sources
+	prefix  string
+}
+
+func (r *sourceReceiver) ReceiveSet(structPath into_struct.Path, name string, _ string) {
+	r.sources[structPathToConfigPath(structPath)] = r.prefix + name
+}
+
+// structPathToConfigPath converts a path reported by go-into-struct into the path used by Sources
+func structPathToConfigPath(structPath into_struct.Path) (out string) {
+	for _, part := range structPath.Parts() {
+		key := yamlKey(part.StructField())
+		if slicePart, ok := part.(into_struct.PathSliceParter); ok {
+			key = fmt.Sprintf("%s[%d]", key, slicePart.Index())
+		}
+		out = joinConfigPath(out, key)
+	}
+	return
+}
+
+// EnvWithSources is just like Env, but records each environment variable used in sources
+func EnvWithSources(sources Sources) EnvUnmarshaler {
+	return EnvWithSourcesFrom(&envParser.OsEnv{}, sources)
+}
+
+// EnvWithSourcesFrom is EnvWithSources, reading the variables from reader
+func EnvWithSourcesFrom(reader envParser.EnvReader, sources Sources) EnvUnmarshaler {
+	return &structEnv{
+		name:   fieldEnvName,
+		reader: reader,
+		emitter: &sourceReceiver{
+			sources: sources,
+			prefix:  sourceEnvPrefix,
+		},
+	}
+}
+
+// FlagsWithSources is just like Flags, but records each flag used in sources
+func FlagsWithSources(globalGroup *flag_unmarshaler.Group, sources Sources) EnvUnmarshaler {
+	return &tracedFlags{
+		globalGroup: globalGroup,
+		receiver: &sourceReceiver{
+			sources: sources,
+			prefix:  sourceFlagPrefix,
+		},
+	}
+}
+
+type tracedFlags struct {
+	globalGroup *flag_unmarshaler.Group
+	receiver    *sourceReceiver
+}
+
+func (e *tracedFlags) Unmarshal(config interface{}) (err error) {
+	flags := newGnuFlags(e.globalGroup, config)
+	parser := flag_unmarshaler.NewWithEmitter(flags, &flagNameReceiver{
+		flags:    flags,
+		original: e.receiver,
+	})
+	return invalidFlagValue(parser.Unmarshal(config), flags)
+}
+
+// flagNameReceiver passes on the flags used by the name they were given by, rather than the path that
+// go-flag-unmarshaler looked them up by
+type flagNameReceiver struct {
+	flags    *gnuFlags
+	original *sourceReceiver
+}
+
+func (r *flagNameReceiver) ReceiveSet(structPath into_struct.Path, name string, value string) {
+	r.original.ReceiveSet(structPath, r.flags.flagName(name), value)
+}
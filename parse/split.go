@@ -0,0 +1,378 @@
+package parse
+
+import (
+	"fmt"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	flagValueSeparator = "="
+	flagsEnd           = "--"
+	longFlagPrefix     = "--"
+	shortFlagPrefix    = "-"
+	negatedFlagPrefix  = "--no-"
+	switchValue        = "true"
+)
+
+// TakesValue reports whether flag, such as --host or -h, is followed by its value, rather than being a switch such as
+// --verbose. groups are those split so far, the flag belongs to the last of them
+type TakesValue func(groups []flag_unmarshaler.Group, flag string) bool
+
+// SplitGNU splits args, the words after the program name, into groups of flags like flag_unmarshaler.Split, but
+// understands GNU flag syntax:
+//   - values may follow their flag: --host example.com, -h example.com, -hexample.com, as well as --host=example.com
+//   - short switches may be combined: -vvv is -v three times, -xvf archive.tar is -x, -v and -f archive.tar
+//   - "--" ends the flags, the words after it are returned in rest, as they are, rather than split
+//
+// takesValue tells switches from flags that take a value, when it is nil only values given with = are read. A flag
+// that takes a value, but is the last word, is an ErrFlagNeedsValue
+func SplitGNU(args []string, takesValue TakesValue) (groups []flag_unmarshaler.Group, rest []string, err error) {
+	if takesValue == nil {
+		takesValue = func([]flag_unmarshaler.Group, string) bool {
+			return false
+		}
+	}
+	groups = []flag_unmarshaler.Group{{}}
+	add := func(key, value string) {
+		last := &groups[len(groups)-1]
+		last.Flags = append(last.Flags, flag_unmarshaler.KeyValue{Key: key, Value: value})
+	}
+	// next takes the word after flag as its value
+	next := func(i *int, flag string) (value string, err error) {
+		if *i+1 == len(args) {
+			return "", &ErrFlagNeedsValue{Flag: flag}
+		}
+		*i++
+		return args[*i], nil
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == flagsEnd:
+			rest = append([]string{}, args[i+1:]...)
+			return
+		case strings.HasPrefix(arg, longFlagPrefix):
+			key, value, hasValue := cut(arg, flagValueSeparator)
+			if !hasValue {
+				value = switchValue
+				if takesValue(groups, key) {
+					value, err = next(&i, key)
+					if err != nil {
+						return
+					}
+				}
+			}
+			add(key, value)
+		case strings.HasPrefix(arg, shortFlagPrefix) && len(arg) != len(shortFlagPrefix):
+			shorts := []rune(arg[len(shortFlagPrefix):])
+			for j, short := range shorts {
+				key, remaining := shortFlagPrefix+string(short), string(shorts[j+1:])
+				if strings.HasPrefix(remaining, flagValueSeparator) {
+					add(key, remaining[len(flagValueSeparator):])
+					break
+				}
+				if !takesValue(groups, key) {
+					add(key, switchValue)
+					continue
+				}
+				if remaining == "" {
+					remaining, err = next(&i, key)
+					if err != nil {
+						return
+					}
+				}
+				add(key, remaining)
+				break
+			}
+		default:
+			groups = append(groups, flag_unmarshaler.Group{CommandName: arg})
+		}
+	}
+	return
+}
+
+// ErrFlagNeedsValue is returned for a flag that takes a value, such as --host, given as the last word of the command line
+type ErrFlagNeedsValue struct {
+	Flag string
+}
+
+func (e *ErrFlagNeedsValue) Error() string {
+	return fmt.Sprintf("flag %s needs a value", e.Flag)
+}
+
+// StructTakesValue is a TakesValue for the flags of the fields of configs, whichever group they are given to. Every
+// flag takes a value, except those of bool fields and counts. Flags that none of configs declare are switches
+func StructTakesValue(configs ...interface{}) TakesValue {
+	takes := make(map[string]bool)
+	for _, config := range configs {
+		for _, field := range structFlags(reflect.TypeOf(config)) {
+			for _, name := range field.names {
+				takes[name] = !field.isSwitch
+			}
+		}
+	}
+	return func(_ []flag_unmarshaler.Group, flag string) bool {
+		return takes[flag]
+	}
+}
+
+var optionalBoolType = reflect.TypeOf(optional.Bool{})
+
+// fieldFlags are the names of the flags that set a field, e.g. --verbose and -v
+type fieldFlags struct {
+	// names are the flag, the short flag and then the aliases of the field
+	names    []string
+	isSwitch bool
+	// isCount fields, tagged flag-count:"true", are set to the number of times their flag is given
+	isCount bool
+	// isList fields are slices, each time their flag is given adds a value
+	isList bool
+	// paths are the names that go-flag-unmarshaler looks the field up by. They are names, unless the field belongs to
+	// an embedded struct, which go-flag-unmarshaler adds to the path, e.g. --ConfigFile.config-file-path
+	paths []string
+	// declaredBy is the struct that declares the field, which is an embedded struct for promoted fields
+	declaredBy reflect.Type
+}
+
+// structFlags lists the flags of the fields of t. The fields of embedded structs are included, as their fields are
+// promoted, and ConfigFile and the like read their flags from the same group
+func structFlags(t reflect.Type) (out []fieldFlags) {
+	return structFlagsWithin(t, nil)
+}
+
+// structFlagsWithin lists the flags of the fields of t, the struct embedded at within, the paths that
+// go-flag-unmarshaler gives it. within is empty for the outermost struct
+func structFlagsWithin(t reflect.Type, within []string) (out []fieldFlags) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		long, short := field.Tag.Get("flag"), field.Tag.Get("flag-short")
+		if long == "" && short == "" {
+			long = field.Name
+		}
+		if field.Anonymous {
+			out = append(out, structFlagsWithin(field.Type, flagPaths(within, long, short))...)
+			continue
+		}
+		valueType := field.Type
+		flags := fieldFlags{
+			isCount:    field.Tag.Get("flag-count") == "true",
+			isList:     valueType.Kind() == reflect.Slice,
+			declaredBy: t,
+		}
+		if flags.isList {
+			valueType = valueType.Elem()
+		}
+		flags.isSwitch = flags.isCount || valueType.Kind() == reflect.Bool || valueType == optionalBoolType
+		flags.names = append(flagPaths(nil, long, short), flagAliases(field.Tag.Get("flag-aliases"))...)
+		flags.paths = flagPaths(within, long, short)
+		out = append(out, flags)
+	}
+	return
+}
+
+// flagPaths are the paths of the field named long and short, either may be blank, within the paths of its struct
+func flagPaths(within []string, long, short string) (out []string) {
+	for _, name := range []string{long, short} {
+		if name == "" {
+			continue
+		}
+		if len(within) == 0 {
+			prefix := longFlagPrefix
+			if name == short {
+				prefix = shortFlagPrefix
+			}
+			out = append(out, prefix+name)
+			continue
+		}
+		for _, path := range within {
+			out = append(out, path+"."+name)
+		}
+	}
+	return
+}
+
+// flagAliases are the further names of a field, tagged flag-aliases:"inc,i" for --inc and -i. They are given like
+// the flag and flag-short tags, one letter names are short flags
+func flagAliases(tag string) (out []string) {
+	for _, alias := range strings.Split(tag, ",") {
+		switch alias = strings.TrimSpace(alias); {
+		case alias == "":
+		case len([]rune(alias)) == 1:
+			out = append(out, shortFlagPrefix+alias)
+		default:
+			out = append(out, longFlagPrefix+alias)
+		}
+	}
+	return
+}
+
+// flagsByName maps each flag name, and path, of the fields of config to the flags of its field
+func flagsByName(config interface{}) map[string]fieldFlags {
+	byName := make(map[string]fieldFlags)
+	for _, field := range structFlags(reflect.TypeOf(config)) {
+		for _, name := range append(field.paths, field.names...) {
+			byName[name] = field
+		}
+	}
+	return byName
+}
+
+// cut splits s around the first sep, found is false when there is none
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// gnuFlags reads the flags of group, treating --no-name as --name=false for switches. The flag given last wins, whichever of the
+// names of its field it was given by, except for counts, which add one each time their flag is given, and lists,
+// which keep every value
+type gnuFlags struct {
+	group  *flag_unmarshaler.Group
+	fields map[string]fieldFlags
+}
+
+func newGnuFlags(group *flag_unmarshaler.Group, config interface{}) *gnuFlags {
+	return &gnuFlags{
+		group:  group,
+		fields: flagsByName(config),
+	}
+}
+
+func (g *gnuFlags) Get(flagNamed string) (value string, ok bool) {
+	if base, index, isElement := listElement(flagNamed); isElement {
+		if field, known := g.fields[base]; known && field.isList {
+			if value, ok = g.last([]string{flagNamed}, false); ok {
+				// indexed flags, such as --include[0]=a, are read as they are given
+				return
+			}
+			values := g.values(field.names)
+			if index < len(values) {
+				return values[index], true
+			}
+			return
+		}
+	}
+	field, known := g.fields[flagNamed]
+	if !known {
+		return g.last([]string{flagNamed}, false)
+	}
+	if field.isCount {
+		return g.count(field.names)
+	}
+	return g.last(field.names, field.isSwitch)
+}
+
+func (g *gnuFlags) Keys(prefix string) (out []string) {
+	out = g.group.Keys(prefix)
+	base := strings.TrimSuffix(prefix, listIndexStart)
+	if field, known := g.fields[base]; known && field.isList && base != prefix {
+		for i := range g.values(field.names) {
+			out = append(out, fmt.Sprintf("%s[%d]", base, i))
+		}
+	}
+	return
+}
+
+// flagName is the name of the flag that go-flag-unmarshaler looks up as path. They differ for the fields of embedded
+// structs, which are looked up as --ConfigFile.config-file-path and the like
+func (g *gnuFlags) flagName(path string) string {
+	base, index := path, ""
+	if element, _, isElement := listElement(path); isElement {
+		base, index = element, path[len(element):]
+	}
+	if field, known := g.fields[base]; known && len(field.names) != 0 {
+		return field.names[0] + index
+	}
+	return path
+}
+
+// last is the value of whichever of names was given last. When negatable, the names of switches, --no-name is false
+func (g *gnuFlags) last(names []string, negatable bool) (value string, ok bool) {
+	for _, flag := range g.group.Flags {
+		if isNegated, matches := matchFlag(flag.Key, names, negatable); matches {
+			value, ok = flag.Value, true
+			if isNegated {
+				value = "false"
+			}
+		}
+	}
+	return
+}
+
+// values are the values given to any of names, in order
+func (g *gnuFlags) values(names []string) (out []string) {
+	for _, flag := range g.group.Flags {
+		if _, matches := matchFlag(flag.Key, names, false); matches {
+			out = append(out, flag.Value)
+		}
+	}
+	return
+}
+
+// count adds one for each of names given as a switch. A value, such as --verbose=3, sets the count, and
+// --no-verbose resets it
+func (g *gnuFlags) count(names []string) (value string, ok bool) {
+	count := 0
+	for _, flag := range g.group.Flags {
+		isNegated, matches := matchFlag(flag.Key, names, true)
+		if !matches {
+			continue
+		}
+		ok = true
+		switch {
+		case isNegated:
+			count = 0
+		case flag.Value == switchValue:
+			count++
+		default:
+			n, err := strconv.Atoi(flag.Value)
+			if err != nil {
+				// left for the parser to report
+				return flag.Value, true
+			}
+			count = n
+		}
+	}
+	return strconv.Itoa(count), ok
+}
+
+// matchFlag is true when key is one of names or, when negatable, the negation of one of the long names, such as
+// --no-verbose
+func matchFlag(key string, names []string, negatable bool) (isNegated, matches bool) {
+	for _, name := range names {
+		if key == name {
+			return false, true
+		}
+		if negatable && strings.HasPrefix(name, longFlagPrefix) && key == negatedFlagPrefix+name[len(longFlagPrefix):] {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+const listIndexStart = "["
+
+// listElement splits the name of a list element, such as --include[2], into its flag and index
+func listElement(flagNamed string) (base string, index int, ok bool) {
+	start := strings.LastIndex(flagNamed, listIndexStart)
+	if start == -1 || !strings.HasSuffix(flagNamed, "]") {
+		return
+	}
+	index, err := strconv.Atoi(flagNamed[start+len(listIndexStart) : len(flagNamed)-1])
+	if err != nil {
+		return
+	}
+	return flagNamed[:start], index, true
+}
@@ -0,0 +1,175 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+	"testing"
+)
+
+type splitConfig struct {
+	Host    optional.String `flag:"host" flag-short:"h"`
+	Verbose optional.Bool   `flag:"verbose" flag-short:"v" flag-aliases:"loud"`
+	Extract bool            `flag-short:"x"`
+	File    string          `flag-short:"f"`
+	Debug   int             `flag:"debug" flag-short:"d" flag-count:"true"`
+	Include []string        `flag:"include" flag-short:"I" flag-aliases:"inc,i"`
+}
+
+func TestSplitGNU(t *testing.T) {
+	cases := map[string]struct {
+		args           []string
+		expectedGroups []flag_unmarshaler.Group
+		expectedRest   []string
+	}{
+		"values after long flags": {
+			args: []string{"--host", "example.com", "--verbose", "start"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{Flags: []flag_unmarshaler.KeyValue{{Key: "--host", Value: "example.com"}, {Key: "--verbose", Value: "true"}}},
+				{CommandName: "start"},
+			},
+		},
+		"values with equals": {
+			args: []string{"--host=example.com", "-h=other.com"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{Flags: []flag_unmarshaler.KeyValue{{Key: "--host", Value: "example.com"}, {Key: "-h", Value: "other.com"}}},
+			},
+		},
+		"values after short flags": {
+			args: []string{"-h", "example.com", "-hother.com"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{Flags: []flag_unmarshaler.KeyValue{{Key: "-h", Value: "example.com"}, {Key: "-h", Value: "other.com"}}},
+			},
+		},
+		"combined short switches": {
+			args: []string{"-vvv"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{Flags: []flag_unmarshaler.KeyValue{{Key: "-v", Value: "true"}, {Key: "-v", Value: "true"}, {Key: "-v", Value: "true"}}},
+			},
+		},
+		"combined short switches ending with a value": {
+			args: []string{"-xvf", "archive.tar", "extract"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{Flags: []flag_unmarshaler.KeyValue{{Key: "-x", Value: "true"}, {Key: "-v", Value: "true"}, {Key: "-f", Value: "archive.tar"}}},
+				{CommandName: "extract"},
+			},
+		},
+		"negated switch": {
+			args: []string{"--no-verbose", "start"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{Flags: []flag_unmarshaler.KeyValue{{Key: "--no-verbose", Value: "true"}}},
+				{CommandName: "start"},
+			},
+		},
+		"end of flags": {
+			args: []string{"start", "--verbose", "--", "--host", "-v", "file"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{},
+				{CommandName: "start", Flags: []flag_unmarshaler.KeyValue{{Key: "--verbose", Value: "true"}}},
+			},
+			expectedRest: []string{"--host", "-v", "file"},
+		},
+		"count and list": {
+			args: []string{"-dd", "-I", "a"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{Flags: []flag_unmarshaler.KeyValue{{Key: "-d", Value: "true"}, {Key: "-d", Value: "true"}, {Key: "-I", Value: "a"}}},
+			},
+		},
+		"dash is an argument": {
+			args: []string{"-"},
+			expectedGroups: []flag_unmarshaler.Group{
+				{},
+				{CommandName: "-"},
+			},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actualGroups, actualRest, err := SplitGNU(c.args, StructTakesValue(&splitConfig{}))
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedGroups, actualGroups)
+			assert.Equal(t, c.expectedRest, actualRest)
+		})
+	}
+}
+
+func TestSplitGNU_Errors(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expectedErr error
+	}{
+		"long flag without a value": {
+			args:        []string{"start", "--host"},
+			expectedErr: &ErrFlagNeedsValue{Flag: "--host"},
+		},
+		"short flag without a value": {
+			args:        []string{"-vh"},
+			expectedErr: &ErrFlagNeedsValue{Flag: "-h"},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			_, _, err := SplitGNU(c.args, StructTakesValue(&splitConfig{}))
+			assert.Equal(t, c.expectedErr, err)
+			assert.EqualError(t, err, "flag "+c.expectedErr.(*ErrFlagNeedsValue).Flag+" needs a value")
+		})
+	}
+}
+
+func TestFlags_GNU(t *testing.T) {
+	cases := map[string]struct {
+		args     []string
+		expected splitConfig
+	}{
+		"negated switch": {
+			args:     []string{"--verbose", "--no-verbose"},
+			expected: splitConfig{Verbose: optional.BoolFrom(false)},
+		},
+		"aliases": {
+			args:     []string{"--inc", "a", "-ib", "--include=c", "--loud"},
+			expected: splitConfig{Include: []string{"a", "b", "c"}, Verbose: optional.BoolFrom(true)},
+		},
+		"negated alias": {
+			args:     []string{"-v", "--no-loud"},
+			expected: splitConfig{Verbose: optional.BoolFrom(false)},
+		},
+		"only switches are negated": {
+			args:     []string{"--host=a.example.com", "--no-host"},
+			expected: splitConfig{Host: optional.StringFrom("a.example.com")},
+		},
+		"last flag wins": {
+			args:     []string{"--no-verbose", "-v", "--host", "a.example.com", "-hb.example.com"},
+			expected: splitConfig{Host: optional.StringFrom("b.example.com"), Verbose: optional.BoolFrom(true)},
+		},
+		"combined short flags": {
+			args:     []string{"-xvf", "archive.tar"},
+			expected: splitConfig{Verbose: optional.BoolFrom(true), Extract: true, File: "archive.tar"},
+		},
+		"count": {
+			args:     []string{"-ddd", "--debug"},
+			expected: splitConfig{Debug: 4},
+		},
+		"count with a value": {
+			args:     []string{"-d", "--debug=3", "-d"},
+			expected: splitConfig{Debug: 4},
+		},
+		"negated count": {
+			args:     []string{"-dd", "--no-debug", "-d"},
+			expected: splitConfig{Debug: 1},
+		},
+		"list": {
+			args:     []string{"--include", "a", "-I", "b", "-Ic"},
+			expected: splitConfig{Include: []string{"a", "b", "c"}},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			groups, _, err := SplitGNU(c.args, StructTakesValue(&splitConfig{}))
+			require.NoError(t, err)
+			actual := splitConfig{}
+			require.NoError(t, Flags(&groups[0]).Unmarshal(&actual))
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
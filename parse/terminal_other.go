@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package parse
+
+import (
+	"errors"
+	"os"
+)
+
+// readSecret is unsupported on this platform, typing a secret where it would be shown is worse than failing
+func readSecret(_ *os.File) (string, error) {
+	return "", errors.New("unable to read secrets without showing them on this platform")
+}
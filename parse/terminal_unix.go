@@ -0,0 +1,48 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package parse
+
+import (
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"strings"
+)
+
+// readSecret reads a line from f without the terminal echoing what is typed
+func readSecret(f *os.File) (line string, err error) {
+	fd := int(f.Fd())
+	state, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return
+	}
+	noEcho := *state
+	noEcho.Lflag &^= unix.ECHO
+	noEcho.Lflag |= unix.ICANON | unix.ISIG
+	err = unix.IoctlSetTermios(fd, ioctlSetTermios, &noEcho)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = unix.IoctlSetTermios(fd, ioctlSetTermios, state)
+	}()
+	// read a byte at a time, so that nothing after the line is consumed
+	read := strings.Builder{}
+	b := make([]byte, 1)
+	for {
+		var n int
+		n, err = f.Read(b)
+		if n == 1 && b[0] != '\n' {
+			read.WriteByte(b[0])
+		}
+		if err != nil || (n == 1 && b[0] == '\n') {
+			break
+		}
+	}
+	line = strings.TrimRight(read.String(), "\r")
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return
+}
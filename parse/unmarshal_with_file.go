@@ -0,0 +1,45 @@
+package parse
+
+import (
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+)
+
+// UnmarshalWithFile loads config from the configuration file, then the environment, then the global flags in args,
+// os.Args[1:], each overriding the last. The file is the ConfigFile path given by CONFIG_FILE_PATH or
+// --config-file-path, which are read first, or defaultPath, which may be blank for no default. The file need not
+// exist. When config embeds ConfigProfile, the profile given by PROFILE or --profile is read from the file, see
+// ConfigFileSource. args are split by SplitGNU, with the fields of config telling which global flags take a value. Returns the
+// groups of the commands that follow the global flags, for the command options to be loaded from. Words after "--"
+// are returned as groups without flags, in order
+func UnmarshalWithFile(defaultPath string, file FileUnmarshaler, args []string, config interface{}) (commandGroups []flag_unmarshaler.Group, err error) {
+	return unmarshalWithFile(defaultPath, file, false, args, config)
+}
+
+// UnmarshalWithRequiredFile is UnmarshalWithFile, but fails when there is no configuration file
+func UnmarshalWithRequiredFile(defaultPath string, file FileUnmarshaler, args []string, config interface{}) (commandGroups []flag_unmarshaler.Group, err error) {
+	return unmarshalWithFile(defaultPath, file, true, args, config)
+}
+
+func unmarshalWithFile(defaultPath string, file FileUnmarshaler, required bool, args []string, config interface{}) (commandGroups []flag_unmarshaler.Group, err error) {
+	path := optional.String{}
+	if defaultPath != "" {
+		path = optional.StringFrom(defaultPath)
+	}
+	groups, rest, err := SplitGNU(args, StructTakesValue(config))
+	if err != nil {
+		return
+	}
+	for _, word := range rest {
+		groups = append(groups, flag_unmarshaler.Group{CommandName: word})
+	}
+	err = Precedence{
+		ConfigFileSource(path, file, required, nil),
+		Ungrouped(Env()),
+		GroupFlags(),
+	}.Unmarshal(config, groups[0])
+	if err != nil {
+		return
+	}
+	return groups[1:], nil
+}
@@ -0,0 +1,187 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fileConfig struct {
+	ConfigFile
+	Hostname optional.String   `yaml:"hostname" flag:"hostname"`
+	Delay    optional.Duration `yaml:"delay" flag:"delay"`
+}
+
+func TestUnmarshalWithFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unmarshal_with_file")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	defaultFile := filepath.Join(dir, "default.yaml")
+	otherFile := filepath.Join(dir, "other.yaml")
+	require.NoError(t, ioutil.WriteFile(defaultFile, []byte("hostname: default.example.com\ndelay: 1s\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(otherFile, []byte("hostname: other.example.com\ndelay: 1s\n"), 0600))
+
+	cases := map[string]struct {
+		defaultPath    string
+		required       bool
+		args           []string
+		expected       fileConfig
+		expectedGroups []flag_unmarshaler.Group
+		expectedErr    error
+	}{
+		"default file": {
+			defaultPath: defaultFile,
+			args:        []string{"--delay=2s", "server", "--port=80", "start"},
+			expected: fileConfig{
+				Hostname: optional.StringFrom("default.example.com"),
+				Delay:    optional.DurationFrom(2 * time.Second),
+			},
+			expectedGroups: []flag_unmarshaler.Group{
+				{CommandName: "server", Flags: []flag_unmarshaler.KeyValue{{Key: "--port", Value: "80"}}},
+				{CommandName: "start"},
+			},
+		},
+		"file from flag": {
+			defaultPath: defaultFile,
+			args:        []string{"--config-file-path=" + otherFile, "--hostname=flag.example.com"},
+			expected: fileConfig{
+				ConfigFile: ConfigFile{ConfigFilePath: optional.StringFrom(otherFile)},
+				Hostname:   optional.StringFrom("flag.example.com"),
+				Delay:      optional.DurationFrom(time.Second),
+			},
+			expectedGroups: []flag_unmarshaler.Group{},
+		},
+		"missing optional file": {
+			defaultPath:    filepath.Join(dir, "missing.yaml"),
+			expectedGroups: []flag_unmarshaler.Group{},
+		},
+		"missing required file": {
+			required:    true,
+			expectedErr: ErrNoFile,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			var actual fileConfig
+			var groups []flag_unmarshaler.Group
+			var err error
+			if c.required {
+				groups, err = UnmarshalWithRequiredFile(c.defaultPath, Yaml(), c.args, &actual)
+			} else {
+				groups, err = UnmarshalWithFile(c.defaultPath, Yaml(), c.args, &actual)
+			}
+			assert.Equal(t, c.expectedErr, err)
+			assert.Equal(t, c.expected, actual)
+			assert.Equal(t, c.expectedGroups, groups)
+		})
+	}
+}
+
+type profiledConfig struct {
+	ConfigProfile
+	Hostname optional.String   `yaml:"hostname" flag:"hostname"`
+	Delay    optional.Duration `yaml:"delay" flag:"delay"`
+}
+
+func TestUnmarshalWithFile_Profiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unmarshal_with_file")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`---
+default:
+  hostname: localhost
+  delay: 1s
+profiles:
+  prod:
+    hostname: example.com
+`), 0600))
+
+	cases := map[string]struct {
+		args        []string
+		env         map[string]string
+		expected    profiledConfig
+		expectedErr string
+	}{
+		"default section": {
+			expected: profiledConfig{
+				Hostname: optional.StringFrom("localhost"),
+				Delay:    optional.DurationFrom(time.Second),
+			},
+		},
+		"profile from flag": {
+			args: []string{"--profile", "prod", "server"},
+			expected: profiledConfig{
+				ConfigProfile: ConfigProfile{Profile: optional.StringFrom("prod")},
+				Hostname:      optional.StringFrom("example.com"),
+				Delay:         optional.DurationFrom(time.Second),
+			},
+		},
+		"profile from env": {
+			env: map[string]string{"PROFILE": "prod"},
+			expected: profiledConfig{
+				ConfigProfile: ConfigProfile{Profile: optional.StringFrom("prod")},
+				Hostname:      optional.StringFrom("example.com"),
+				Delay:         optional.DurationFrom(time.Second),
+			},
+		},
+		"flags override the profile": {
+			args: []string{"--profile=prod", "--hostname=flag.example.com"},
+			expected: profiledConfig{
+				ConfigProfile: ConfigProfile{Profile: optional.StringFrom("prod")},
+				Hostname:      optional.StringFrom("flag.example.com"),
+				Delay:         optional.DurationFrom(time.Second),
+			},
+		},
+		"unknown profile": {
+			args:        []string{"--profile=staging"},
+			expectedErr: `unknown profile "staging", the configuration file has: prod`,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			for name, value := range c.env {
+				require.NoError(t, os.Setenv(name, value))
+			}
+			defer func() {
+				for name := range c.env {
+					_ = os.Unsetenv(name)
+				}
+			}()
+			var actual profiledConfig
+			_, err := UnmarshalWithFile(file, Yaml(), c.args, &actual)
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestConfigProfile_File(t *testing.T) {
+	var actual profiledConfig
+	file := actual.ConfigProfile.File(Yaml())
+	// selected after File was called, as it is when the environment and flags are read after it
+	actual.Profile = optional.StringFrom("prod")
+	err := Unmarshall(&actual, newFileAsBytes([]byte(`---
+default:
+  hostname: localhost
+profiles:
+  prod:
+    hostname: example.com
+`), file))
+	require.NoError(t, err)
+	assert.Equal(t, optional.StringFrom("example.com"), actual.Hostname)
+}
@@ -0,0 +1,96 @@
+package parse
+
+import (
+	"encoding/json"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// plainYamlKey matches the keys that may be written without quotes, as long as they are not one of yamlReservedWords
+var plainYamlKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./-]*$`)
+
+// yamlReservedWords are read as booleans or null rather than strings when they are not quoted, in any case
+var yamlReservedWords = map[string]bool{
+	"y": true, "yes": true, "n": true, "no": true, "on": true, "off": true, "true": true, "false": true, "null": true,
+}
+
+type ymlOut struct {
+	sources Sources
+}
+
+// YamlMarshaler writes a configuration back out in the same shape that Yaml() reads. Secret values are redacted
+func YamlMarshaler() FileMarshaler {
+	return YamlMarshalerWithSources(nil)
+}
+
+// YamlMarshalerWithSources is just like YamlMarshaler, but annotates each value with a comment saying where it was set
+func YamlMarshalerWithSources(sources Sources) FileMarshaler {
+	return &ymlOut{
+		sources: sources,
+	}
+}
+
+func (y *ymlOut) MarshalFile(w io.Writer, config interface{}) (err error) {
+	tree, err := newConfigTree(config, true)
+	if err != nil {
+		return
+	}
+	out := string_writer.New(w, "  ")
+	if tree.isEmpty() {
+		return out.WriteLn("{}")
+	}
+	err = out.WriteLn("---")
+	if err != nil {
+		return
+	}
+	return y.writeChildren(out, tree)
+}
+
+func (y *ymlOut) writeChildren(out *string_writer.Type, parent *configNode) (err error) {
+	for _, child := range parent.children {
+		prefix := "-"
+		if !parent.isList {
+			prefix = yamlMappingKey(child.key) + ":"
+		}
+		if child.isScalar {
+			err = out.WriteLn(prefix + " " + child.scalar + y.comment(child))
+		} else if len(child.children) == 0 {
+			empty := "{}"
+			if child.isList {
+				empty = "[]"
+			}
+			err = out.WriteLn(prefix + " " + empty + y.comment(child))
+		} else {
+			err = out.WriteLn(prefix)
+			if err != nil {
+				return
+			}
+			err = out.In(func(out *string_writer.Type) error {
+				return y.writeChildren(out, child)
+			})
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (y *ymlOut) comment(node *configNode) string {
+	if source, ok := y.sources[node.path]; ok {
+		return " # " + source
+	}
+	return ""
+}
+
+// yamlMappingKey is key as it is written in a mapping, quoted when it would not be read back as the same string, such
+// as on, yes or 8080
+func yamlMappingKey(key string) string {
+	if plainYamlKey.MatchString(key) && !yamlReservedWords[strings.ToLower(key)] {
+		return key
+	}
+	quoted, _ := json.Marshal(key)
+	return string(quoted)
+}
@@ -6,10 +6,16 @@ import (
 )
 
 type MethodHandler func(ctx context.Context) error
+type HookAfterHandler func(ctx context.Context, err error) error
 type ObjectFactory func() interface{}
 
 type MethodDesc struct {
-	Handler     MethodHandler
+	Handler MethodHandler
+	// HookBefore runs before any sub-command of this one, and may be nil
+	HookBefore MethodHandler
+	// HookAfter runs after any sub-command of this one with the error it returned, and returns the error to report. It
+	// may be nil
+	HookAfter   HookAfterHandler
 	Meta        dsl.Command
 	ObjectMaker ObjectFactory
 }
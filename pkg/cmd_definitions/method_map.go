@@ -0,0 +1,22 @@
+package cmd_definitions
+
+import (
+	"github.com/wojnosystems/go-nested-map/nested_string_map"
+)
+
+// MethodMap holds the methods of a service by command path
+type MethodMap struct {
+	t nested_string_map.T
+}
+
+func (m *MethodMap) Get(path ...string) (method MethodDesc, ok bool) {
+	v, ok := m.t.Get(path...)
+	if ok {
+		method, ok = v.(MethodDesc)
+	}
+	return
+}
+
+func (m *MethodMap) Put(method MethodDesc, path ...string) {
+	m.t.Put(method, path...)
+}
@@ -0,0 +1,88 @@
+package cmd_definitions
+
+import (
+	"context"
+	"sort"
+)
+
+// Middleware wraps the handler of a command to do something around every call, such as timing or logging it. It must
+// call next to run the command, unless it means to stop the command from running
+type Middleware func(next MethodHandler) MethodHandler
+
+type invocationKey struct{}
+
+// Invocation is the command a handler is running
+type Invocation struct {
+	// Path is the command names leading to, and including, the command, e.g. server start. It is empty for the root
+	Path []string
+	// Options is the options struct of the command, with every source applied, or nil when it has none
+	Options interface{}
+}
+
+// InvocationFrom gets the command that is running from the ctx given to a handler. ok is false outside of a handler
+func InvocationFrom(ctx context.Context) (invocation Invocation, ok bool) {
+	invocation, ok = ctx.Value(invocationKey{}).(Invocation)
+	return
+}
+
+// Middlewares are the middlewares registered for a service, along with the commands they apply to
+type Middlewares struct {
+	registered []registeredMiddleware
+}
+
+type registeredMiddleware struct {
+	path       []string
+	middleware Middleware
+}
+
+// Use registers middleware for every command
+func (m *Middlewares) Use(middleware ...Middleware) {
+	m.UseFor(nil, middleware...)
+}
+
+// UseFor registers middleware for the command at path and all of its sub-commands
+func (m *Middlewares) UseFor(path []string, middleware ...Middleware) {
+	path = append(make([]string, 0, len(path)), path...)
+	for _, mw := range middleware {
+		m.registered = append(m.registered, registeredMiddleware{path: path, middleware: mw})
+	}
+}
+
+// Wrap wraps handler, of the command at path, in the middlewares that apply to it. Middlewares registered for every
+// command run first, then those registered for parent commands before those for their sub-commands. Middlewares for
+// the same command run in the order they were registered. All of them run around handler, which runs the hooks as well
+// as the command, so they see the error returned by the HookAfter chain
+func (m *Middlewares) Wrap(path []string, options interface{}, handler MethodHandler) MethodHandler {
+	applicable := make([]registeredMiddleware, 0, len(m.registered))
+	for _, r := range m.registered {
+		if hasPrefix(path, r.path) {
+			applicable = append(applicable, r)
+		}
+	}
+	sort.SliceStable(applicable, func(i, j int) bool {
+		return len(applicable[i].path) < len(applicable[j].path)
+	})
+	for i := len(applicable) - 1; i >= 0; i-- {
+		handler = applicable[i].middleware(handler)
+	}
+	invocation := Invocation{
+		Path:    append(make([]string, 0, len(path)), path...),
+		Options: options,
+	}
+	wrapped := handler
+	return func(ctx context.Context) error {
+		return wrapped(context.WithValue(ctx, invocationKey{}, invocation))
+	}
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i, name := range prefix {
+		if path[i] != name {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package cmd_definitions
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// recording is a middleware that appends name to calls before and after it runs next
+func recording(name string, calls *[]string) Middleware {
+	return func(next MethodHandler) MethodHandler {
+		return func(ctx context.Context) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestServiceDesc_Handler(t *testing.T) {
+	cases := map[string]struct {
+		path     []string
+		expected []string
+	}{
+		"root": {
+			expected: []string{
+				"global before",
+				"second global before",
+				"handler ",
+				"second global after",
+				"global after",
+			},
+		},
+		"sub-command": {
+			path: []string{"server", "start"},
+			expected: []string{
+				"global before",
+				"second global before",
+				"server before",
+				"server start before",
+				"handler server start",
+				"server start after",
+				"server after",
+				"second global after",
+				"global after",
+			},
+		},
+		"sibling": {
+			path: []string{"server", "stop"},
+			expected: []string{
+				"global before",
+				"second global before",
+				"server before",
+				"handler server stop",
+				"server after",
+				"second global after",
+				"global after",
+			},
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			var calls []string
+			service := ServiceDesc{}
+			handler := func(ctx context.Context) error {
+				invocation, ok := InvocationFrom(ctx)
+				assert.True(t, ok)
+				calls = append(calls, "handler "+strings.Join(invocation.Path, " "))
+				return errors.New("failed")
+			}
+			service.Root.Handler = handler
+			service.Methods.Put(MethodDesc{Handler: handler}, "server", "start")
+			service.Methods.Put(MethodDesc{Handler: handler}, "server", "stop")
+			// registered out of order, to show that parents run before sub-commands
+			service.Middlewares.UseFor([]string{"server", "start"}, recording("server start", &calls))
+			service.Middlewares.Use(recording("global", &calls))
+			service.Middlewares.UseFor([]string{"server"}, recording("server", &calls))
+			service.Middlewares.Use(recording("second global", &calls))
+
+			wrapped, ok := service.Handler(c.path, nil)
+			assert.True(t, ok)
+			err := wrapped(context.Background())
+			assert.EqualError(t, err, "failed")
+			assert.Equal(t, c.expected, calls)
+		})
+	}
+}
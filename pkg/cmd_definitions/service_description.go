@@ -1,8 +1,114 @@
 package cmd_definitions
 
-import "github.com/wojnosystems/flick/pkg/cmd_to_method_map"
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
 
 type ServiceDesc struct {
 	Root    MethodDesc
-	Methods cmd_to_method_map.Map
+	Methods MethodMap
+	// Middlewares wrap the Handler of every method, see Handler
+	Middlewares Middlewares
+	// Debug is true when the stack of panics should be shown, such as when parse.OptionSourceTrace is enabled. It may be
+	// nil, in which case stacks are not shown
+	Debug func() bool
+}
+
+// Handler runs the method at path, wrapped by the middlewares registered for it. options is the resolved options
+// struct of the command, which the middlewares, and the handler, get from InvocationFrom. ok is false when there is no
+// method at path. The Root method is at the empty path.
+//
+// The handler runs the HookBefore of the root and of each command leading to the method, then the method, then the
+// HookAfter of each of those commands in reverse. Only the hooks of commands whose HookBefore succeeded are run after.
+// A hook, method or middleware that panics returns an *ErrPanic instead, which the HookAfter chain sees like any other error
+func (s *ServiceDesc) Handler(path []string, options interface{}) (handler MethodHandler, ok bool) {
+	method, ok := s.method(path)
+	if !ok || method.Handler == nil {
+		return nil, false
+	}
+	path = append(make([]string, 0, len(path)), path...)
+	wrapped := s.Middlewares.Wrap(path, options, func(ctx context.Context) (err error) {
+		entered := make([]MethodDesc, 0, len(path))
+		for i := 0; i < len(path) && err == nil; i++ {
+			parent, _ := s.method(path[:i])
+			if parent.HookBefore != nil {
+				err = s.recovered(path, func() error {
+					return parent.HookBefore(ctx)
+				})
+			}
+			if err == nil {
+				entered = append(entered, parent)
+			}
+		}
+		if err == nil {
+			err = s.recovered(path, func() error {
+				return method.Handler(ctx)
+			})
+		}
+		for i := len(entered) - 1; i >= 0; i-- {
+			if entered[i].HookAfter != nil {
+				hookAfter, hookErr := entered[i].HookAfter, err
+				err = s.recovered(path, func() error {
+					return hookAfter(ctx, hookErr)
+				})
+			}
+		}
+		return
+	})
+	return func(ctx context.Context) error {
+		// middlewares may panic too
+		return s.recovered(path, func() error {
+			return wrapped(ctx)
+		})
+	}, true
+}
+
+func (s *ServiceDesc) method(path []string) (method MethodDesc, ok bool) {
+	if len(path) == 0 {
+		return s.Root, true
+	}
+	return s.Methods.Get(path...)
+}
+
+// recovered calls f, returning an *ErrPanic when it panics
+func (s *ServiceDesc) recovered(path []string, f func() error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &ErrPanic{
+				Path:      path,
+				Value:     value,
+				Stack:     debug.Stack(),
+				ShowStack: s.Debug != nil && s.Debug(),
+			}
+		}
+	}()
+	return f()
+}
+
+// ErrPanic is returned when a command, or one of its hooks, panicked
+type ErrPanic struct {
+	// Path is the command that was running
+	Path []string
+	// Value is what was passed to panic
+	Value interface{}
+	// Stack is the stack of the goroutine when it panicked
+	Stack []byte
+	// ShowStack is true when Stack should be shown along with the error, see ServiceDesc.Debug
+	ShowStack bool
+}
+
+func (e *ErrPanic) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("panicked: %v", e.Value)
+	}
+	return fmt.Sprintf(`command "%s" panicked: %v`, strings.Join(e.Path, " "), e.Value)
+}
+
+// Unwrap is the value passed to panic, when it was an error
+func (e *ErrPanic) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
 }
@@ -0,0 +1,135 @@
+package cmd_definitions
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestServiceDesc_Handler_Hooks(t *testing.T) {
+	errFailed := errors.New("failed")
+	cases := map[string]struct {
+		beforeErr     error
+		methodErr     error
+		methodPanic   interface{}
+		debug         bool
+		expectedCalls []string
+		expectedErr   string
+		expectedStack bool
+	}{
+		"ok": {
+			expectedCalls: []string{"root before", "server before", "server start", "server after <nil>", "root after <nil>"},
+		},
+		"method fails": {
+			methodErr:     errFailed,
+			expectedCalls: []string{"root before", "server before", "server start", "server after failed", "root after failed"},
+			expectedErr:   "failed",
+		},
+		"hook before fails": {
+			beforeErr:     errFailed,
+			expectedCalls: []string{"root before", "server before", "root after failed"},
+			expectedErr:   "failed",
+		},
+		"method panics": {
+			methodPanic: "oops",
+			expectedCalls: []string{
+				"root before",
+				"server before",
+				"server start",
+				`server after command "server start" panicked: oops`,
+				`root after command "server start" panicked: oops`,
+			},
+			expectedErr: `command "server start" panicked: oops`,
+		},
+		"method panics while debugging": {
+			methodPanic: "oops",
+			debug:       true,
+			expectedCalls: []string{
+				"root before",
+				"server before",
+				"server start",
+				`server after command "server start" panicked: oops`,
+				`root after command "server start" panicked: oops`,
+			},
+			expectedErr:   `command "server start" panicked: oops`,
+			expectedStack: true,
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			var calls []string
+			hooks := func(name string, beforeErr error) MethodDesc {
+				return MethodDesc{
+					HookBefore: func(ctx context.Context) error {
+						calls = append(calls, name+" before")
+						return beforeErr
+					},
+					HookAfter: func(ctx context.Context, err error) error {
+						if err == nil {
+							calls = append(calls, name+" after <nil>")
+						} else {
+							calls = append(calls, name+" after "+err.Error())
+						}
+						return err
+					},
+				}
+			}
+			service := ServiceDesc{
+				Root: hooks("root", nil),
+				Debug: func() bool {
+					return c.debug
+				},
+			}
+			service.Methods.Put(hooks("server", c.beforeErr), "server")
+			service.Methods.Put(MethodDesc{
+				Handler: func(ctx context.Context) error {
+					calls = append(calls, "server start")
+					if c.methodPanic != nil {
+						panic(c.methodPanic)
+					}
+					return c.methodErr
+				},
+			}, "server", "start")
+
+			handler, ok := service.Handler([]string{"server", "start"}, nil)
+			assert.True(t, ok)
+			err := handler(context.Background())
+			assert.Equal(t, c.expectedCalls, calls)
+			if c.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, c.expectedErr)
+			var panicked *ErrPanic
+			if errors.As(err, &panicked) {
+				assert.Equal(t, []string{"server", "start"}, panicked.Path)
+				assert.NotEmpty(t, panicked.Stack)
+				assert.Equal(t, c.expectedStack, panicked.ShowStack)
+			}
+		})
+	}
+}
+
+func TestServiceDesc_Handler_MiddlewarePanics(t *testing.T) {
+	service := ServiceDesc{}
+	service.Root.Handler = func(ctx context.Context) error {
+		return nil
+	}
+	service.Middlewares.Use(func(next MethodHandler) MethodHandler {
+		return func(ctx context.Context) error {
+			panic(errors.New("unauthorized"))
+		}
+	})
+	handler, ok := service.Handler(nil, nil)
+	assert.True(t, ok)
+	err := handler(context.Background())
+	assert.EqualError(t, err, "panicked: unauthorized")
+	assert.EqualError(t, errors.Unwrap(err), "unauthorized")
+}
+
+func TestServiceDesc_Handler_Missing(t *testing.T) {
+	service := ServiceDesc{}
+	_, ok := service.Handler([]string{"server"}, nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,228 @@
+package completion
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"strings"
+)
+
+const singleIndent = `  `
+
+type bash struct {
+	programName string
+}
+
+// Bash generates a bash completion script for programName. Source it or place it in bash_completion.d
+func Bash(programName string) generate.Maker {
+	return &bash{
+		programName: programName,
+	}
+}
+
+func (b *bash) Generate(_ context.Context, document *dsl.Document, output io.Writer) (err error) {
+	levels := collectLevels(document)
+	out := string_writer.New(output, singleIndent)
+	err = out.WriteLnF("# bash completion for %s, generated by flick", b.programName)
+	if err != nil {
+		return
+	}
+	err = out.WriteLnF("%s() {", functionName(b.programName))
+	if err != nil {
+		return
+	}
+	err = out.In(func(out *string_writer.Type) (err error) {
+		for _, line := range []string{
+			`local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmdpath="" flag="" skip="" before=() word i`,
+			`for ((i = 1; i < COMP_CWORD; i++)); do`,
+			`  word="${COMP_WORDS[i]}"`,
+			// bash splits --flag=value into three words, the word after = is a value
+			`  if [[ "$word" == "=" ]]; then`,
+			`    skip=1`,
+			`    continue`,
+			`  fi`,
+			`  if [[ -n "$skip" ]]; then`,
+			`    skip=""`,
+			`    continue`,
+			`  fi`,
+			`  [[ "$word" == "--" ]] && break`,
+			`  case "$cmdpath $word" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = writeValueFlagCases(out, levels, `    %s) skip=1; continue ;;`)
+		if err != nil {
+			return
+		}
+		for _, line := range []string{
+			`  esac`,
+			`  case "$cmdpath/$word" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = writeCommandPathCases(out, levels, `    %s) cmdpath="$cmdpath/$word" ;;`)
+		if err != nil {
+			return
+		}
+		for _, line := range []string{
+			`  esac`,
+			`done`,
+			// bash splits --flag=value into three words. before is the words ahead of the flag
+			`if [[ "$cur" == "=" ]]; then`,
+			`  flag="$prev"`,
+			`  cur=""`,
+			`  before=("${COMP_WORDS[@]:1:COMP_CWORD-2}")`,
+			`elif [[ "$prev" == "=" ]]; then`,
+			`  flag="${COMP_WORDS[COMP_CWORD-2]}"`,
+			`  before=("${COMP_WORDS[@]:1:COMP_CWORD-3}")`,
+			`elif [[ "$prev" == -* ]]; then`,
+			`  flag="$prev"`,
+			`  before=("${COMP_WORDS[@]:1:COMP_CWORD-2}")`,
+			`fi`,
+			`if [[ -n "$flag" ]]; then`,
+			`  case "$cmdpath $flag" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = out.In(func(out *string_writer.Type) error {
+			return out.In(func(out *string_writer.Type) error {
+				return b.writeValueCases(out, levels)
+			})
+		})
+		if err != nil {
+			return
+		}
+		for _, line := range []string{
+			`  esac`,
+			`fi`,
+			`case "$cmdpath" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = out.In(func(out *string_writer.Type) error {
+			return b.writeWordCases(out, levels)
+		})
+		if err != nil {
+			return
+		}
+		err = out.WriteLn(`esac`)
+		if err != nil {
+			return
+		}
+		return out.WriteLn(`[[ "${COMPREPLY[0]}" == *= ]] && compopt -o nospace`)
+	})
+	if err != nil {
+		return
+	}
+	err = out.WriteLn("}")
+	if err != nil {
+		return
+	}
+	return out.WriteLnF("complete -F %s %s", functionName(b.programName), b.programName)
+}
+
+func (b *bash) writeValueCases(out *string_writer.Type, levels []commandLevel) (err error) {
+	for _, level := range levels {
+		for _, option := range level.options {
+			if !option.TakesValue() {
+				continue
+			}
+			reply := ""
+			switch {
+			case len(option.Enum) != 0:
+				reply = `COMPREPLY=($(compgen -W ` + shellQuote(strings.Join(option.Enum, " ")) + ` -- "$cur"))`
+			case option.FilePath:
+				reply = `COMPREPLY=($(compgen -f -- "$cur"))`
+			default:
+				reply = `COMPREPLY=($(compgen -W "$(` + b.programName + ` ` + CommandName + ` "${before[@]}" "$flag=$cur" 2>/dev/null)" -- "$cur"))`
+			}
+			// switches given before the word being completed fall through to completing words
+			err = out.WriteLnF(`%s) %s; return ;;`, casePatterns(level.path+" ", option.Flag.Names()), reply)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (b *bash) writeWordCases(out *string_writer.Type, levels []commandLevel) (err error) {
+	for _, level := range levels {
+		words := make([]string, 0, len(level.commands)+len(level.options))
+		words = append(words, level.commands...)
+		for _, option := range level.options {
+			words = append(words, flagWords(option)...)
+		}
+		err = out.WriteLnF(`%s) COMPREPLY=($(compgen -W %s -- "$cur")) ;;`,
+			casePatterns("", []string{level.path}), shellQuote(strings.Join(words, " ")))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// writeCommandPathCases writes a case pattern matching every command path other than the root, followed by a case
+// for every command with aliases, which sets the path to the one using the command name
+func writeCommandPathCases(out *string_writer.Type, levels []commandLevel, format string) (err error) {
+	paths := make([]string, 0, len(levels))
+	for _, level := range levels[1:] {
+		paths = append(paths, level.path)
+	}
+	if len(paths) == 0 {
+		return
+	}
+	err = out.WriteLnF(format, casePatterns("", paths))
+	if err != nil {
+		return
+	}
+	for _, level := range levels[1:] {
+		if len(level.aliases) != 0 {
+			err = out.WriteLnF(`    %s) cmdpath=%s ;;`, casePatterns("", level.aliases), shellQuote(level.path))
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// writeValueFlagCases writes a case pattern matching every flag that takes a value, prefixed by the path of the
+// command it is given to, so that the word after it is skipped rather than read as a command
+func writeValueFlagCases(out *string_writer.Type, levels []commandLevel, format string) (err error) {
+	var flags []string
+	for _, level := range levels {
+		for _, option := range level.options {
+			if option.TakesValue() {
+				flags = append(flags, casePatterns(level.path+" ", option.Flag.Names()))
+			}
+		}
+	}
+	if len(flags) == 0 {
+		return
+	}
+	return out.WriteLnF(format, strings.Join(flags, "|"))
+}
+
+// casePatterns joins each of the values, quoted and prefixed with prefix, into a shell case pattern
+func casePatterns(prefix string, values []string) string {
+	patterns := make([]string, len(values))
+	for i, value := range values {
+		patterns[i] = `"` + prefix + value + `"`
+	}
+	return strings.Join(patterns, "|")
+}
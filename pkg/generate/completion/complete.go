@@ -0,0 +1,116 @@
+package completion
+
+import (
+	"context"
+	"errors"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"strings"
+)
+
+// ValueCompleter suggests values for options that have neither an Enum nor are a FilePath
+type ValueCompleter interface {
+	CompleteValue(ctx context.Context, commandPath []string, option dsl.Option, partial string) []string
+}
+
+// Complete is the implementation of the hidden __complete command. args are the words typed after the program name,
+// the last of which is the word being completed. Each candidate is written on its own line. values may be nil
+func Complete(ctx context.Context, document *dsl.Document, args []string, values ValueCompleter, output io.Writer) (err error) {
+	out := string_writer.New(output, "")
+	for _, candidate := range Candidates(ctx, document, args, values) {
+		err = out.WriteLn(candidate)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Candidates lists the completions for the last of the words in args
+func Candidates(ctx context.Context, document *dsl.Document, args []string, values ValueCompleter) (out []string) {
+	partial := ""
+	if len(args) != 0 {
+		partial = args[len(args)-1]
+		args = args[0 : len(args)-1]
+	}
+
+	levels := make(map[string]commandLevel)
+	for _, level := range collectLevels(document) {
+		levels[level.path] = level
+		for _, alias := range level.aliases {
+			levels[alias] = level
+		}
+	}
+	// args are split the way the parser splits them. A flag missing its value is given the word being completed
+	path, valueOf := "", ""
+	groups, rest, err := parse.SplitGNU(args, document.TakesValue)
+	if rest != nil {
+		// the words after "--" are arguments
+		return
+	}
+	var needsValue *parse.ErrFlagNeedsValue
+	if errors.As(err, &needsValue) {
+		valueOf = needsValue.Flag
+	}
+	for _, group := range groups[1:] {
+		level, ok := levels[path+commandPathSeparator+group.CommandName]
+		if !ok {
+			// once a word is not a command, the rest are arguments of the last command
+			break
+		}
+		path = level.path
+	}
+	level := levels[path]
+	commandPath := level.commandPath()
+
+	// values are completed after --flag=, and after --flag as a word of its own
+	partialValue := partial
+	if valueOf == "" && strings.HasPrefix(partial, "-") && strings.Contains(partial, "=") {
+		parts := strings.SplitN(partial, "=", 2)
+		valueOf, partialValue = parts[0], parts[1]
+	}
+	if valueOf != "" {
+		option, ok := findOption(level, valueOf)
+		if !ok {
+			return
+		}
+		var suggestions []string
+		if len(option.Enum) != 0 {
+			suggestions = option.Enum
+		} else if hasDynamicValues(option) && values != nil {
+			suggestions = values.CompleteValue(ctx, commandPath, option, partialValue)
+		}
+		return withPrefix(suggestions, partialValue)
+	}
+
+	var words []string
+	if !strings.HasPrefix(partial, "-") {
+		words = append(words, level.commands...)
+	}
+	for _, option := range level.options {
+		words = append(words, flagWords(option)...)
+	}
+	return withPrefix(words, partial)
+}
+
+func findOption(level commandLevel, flagName string) (option dsl.Option, ok bool) {
+	for _, option = range level.options {
+		for _, name := range option.Flag.Names() {
+			if name == flagName {
+				return option, true
+			}
+		}
+	}
+	return dsl.Option{}, false
+}
+
+func withPrefix(words []string, prefix string) (out []string) {
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) {
+			out = append(out, word)
+		}
+	}
+	return
+}
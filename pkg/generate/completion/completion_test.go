@@ -0,0 +1,339 @@
+package completion
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"testing"
+)
+
+var testDocument = dsl.Document{
+	Options: []dsl.OptionOrReference{
+		{
+			Option: dsl.Option{
+				Name: "verbose",
+				Type: "bool",
+				Flag: dsl.FlagDef{
+					Name:    "verbose",
+					Aliases: []string{"v"},
+				},
+			},
+		},
+		{
+			Option: dsl.Option{
+				Name:   "trace",
+				Type:   "bool",
+				Hidden: true,
+				Flag:   dsl.FlagDef{Name: "trace"},
+			},
+		},
+	},
+	Commands: dsl.NamedCommands{
+		"debug": dsl.Command{
+			Hidden: true,
+		},
+		"server": dsl.Command{
+			Aliases: []string{"srv"},
+			Options: []dsl.OptionOrReference{
+				{
+					Option: dsl.Option{
+						Name:  "env",
+						Type:  "string",
+						Enum:  []string{"dev", "prod"},
+						Flag:  dsl.FlagDef{Name: "env"},
+						Scope: dsl.ScopeLocal,
+					},
+				},
+				{
+					Option: dsl.Option{
+						Name:     "config",
+						Type:     "string",
+						FilePath: true,
+						Flag:     dsl.FlagDef{Name: "config"},
+						Scope:    dsl.ScopeLocal,
+					},
+				},
+				{
+					Option: dsl.Option{
+						Name: "name",
+						Type: "string",
+						Flag: dsl.FlagDef{
+							Name:    "name",
+							Aliases: []string{"n"},
+						},
+						Scope: dsl.ScopeLocal,
+					},
+				},
+			},
+			Commands: dsl.NamedCommands{
+				"start": dsl.Command{},
+			},
+		},
+	},
+}
+
+type valueCompleterMock struct {
+	values []string
+}
+
+func (m *valueCompleterMock) CompleteValue(_ context.Context, _ []string, _ dsl.Option, _ string) []string {
+	return m.values
+}
+
+func TestCandidates(t *testing.T) {
+	cases := map[string]struct {
+		args     []string
+		values   ValueCompleter
+		expected []string
+	}{
+		"nothing typed": {
+			args:     []string{""},
+			expected: []string{"server", "--verbose", "-v"},
+		},
+		"command prefix": {
+			args:     []string{"se"},
+			expected: []string{"server"},
+		},
+		"sub-command after global flag": {
+			args:     []string{"-v", "server", ""},
+			expected: []string{"start", "--env=", "--config=", "--name=", "-n=", "--verbose", "-v"},
+		},
+		"flags only": {
+			args:     []string{"server", "--"},
+			expected: []string{"--env=", "--config=", "--name=", "--verbose"},
+		},
+		"alias of command": {
+			args:     []string{"srv", "--"},
+			expected: []string{"--env=", "--config=", "--name=", "--verbose"},
+		},
+		"hidden commands are not offered": {
+			args: []string{"d"},
+		},
+		"hidden command options": {
+			args:     []string{"debug", "-"},
+			expected: []string{"--verbose", "-v"},
+		},
+		"enum values": {
+			args:     []string{"server", "--env=p"},
+			expected: []string{"prod"},
+		},
+		"file paths are left to the shell": {
+			args: []string{"server", "--config=/tm"},
+		},
+		"dynamic values": {
+			args:     []string{"server", "--name=b"},
+			values:   &valueCompleterMock{values: []string{"alice", "bob"}},
+			expected: []string{"bob"},
+		},
+		"dynamic values without completer": {
+			args: []string{"server", "--name="},
+		},
+		"enum values after a space": {
+			args:     []string{"server", "--env", "p"},
+			expected: []string{"prod"},
+		},
+		"dynamic values after a space": {
+			args:     []string{"server", "--name", ""},
+			values:   &valueCompleterMock{values: []string{"alice", "bob"}},
+			expected: []string{"alice", "bob"},
+		},
+		"value that is a command name": {
+			args:     []string{"server", "--name", "start", "--"},
+			expected: []string{"--env=", "--config=", "--name=", "--verbose"},
+		},
+		"value given to a short flag": {
+			args:     []string{"server", "-nstart", "--"},
+			expected: []string{"--env=", "--config=", "--name=", "--verbose"},
+		},
+		"value of combined short flags": {
+			args:     []string{"server", "-vn", "start", ""},
+			expected: []string{"start", "--env=", "--config=", "--name=", "-n=", "--verbose", "-v"},
+		},
+		"value of combined short flags being completed": {
+			args:     []string{"server", "-vn", ""},
+			values:   &valueCompleterMock{values: []string{"alice", "bob"}},
+			expected: []string{"alice", "bob"},
+		},
+		"arguments after --": {
+			args: []string{"server", "--", "st"},
+		},
+		"after a switch": {
+			args:     []string{"-v", ""},
+			expected: []string{"server", "--verbose", "-v"},
+		},
+		"unknown flag": {
+			args: []string{"server", "--nope="},
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := Candidates(context.TODO(), &testDocument, c.args, c.values)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	actual := bytes.Buffer{}
+	err := Complete(context.TODO(), &testDocument, []string{"server", "--env="}, nil, &actual)
+	require.NoError(t, err)
+	assert.Equal(t, "dev\nprod\n", actual.String())
+}
+
+func TestMakers_Generate(t *testing.T) {
+	cases := map[string]struct {
+		maker    generate.Maker
+		expected string
+	}{
+		"bash": {
+			maker: Bash("my-app"),
+			expected: `# bash completion for my-app, generated by flick
+_my_app() {
+  local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmdpath="" flag="" skip="" before=() word i
+  for ((i = 1; i < COMP_CWORD; i++)); do
+    word="${COMP_WORDS[i]}"
+    if [[ "$word" == "=" ]]; then
+      skip=1
+      continue
+    fi
+    if [[ -n "$skip" ]]; then
+      skip=""
+      continue
+    fi
+    [[ "$word" == "--" ]] && break
+    case "$cmdpath $word" in
+      "/server --env"|"/server --config"|"/server --name"|"/server -n") skip=1; continue ;;
+    esac
+    case "$cmdpath/$word" in
+      "/debug"|"/server"|"/server/start") cmdpath="$cmdpath/$word" ;;
+      "/srv") cmdpath='/server' ;;
+    esac
+  done
+  if [[ "$cur" == "=" ]]; then
+    flag="$prev"
+    cur=""
+    before=("${COMP_WORDS[@]:1:COMP_CWORD-2}")
+  elif [[ "$prev" == "=" ]]; then
+    flag="${COMP_WORDS[COMP_CWORD-2]}"
+    before=("${COMP_WORDS[@]:1:COMP_CWORD-3}")
+  elif [[ "$prev" == -* ]]; then
+    flag="$prev"
+    before=("${COMP_WORDS[@]:1:COMP_CWORD-2}")
+  fi
+  if [[ -n "$flag" ]]; then
+    case "$cmdpath $flag" in
+      "/server --env") COMPREPLY=($(compgen -W 'dev prod' -- "$cur")); return ;;
+      "/server --config") COMPREPLY=($(compgen -f -- "$cur")); return ;;
+      "/server --name"|"/server -n") COMPREPLY=($(compgen -W "$(my-app __complete "${before[@]}" "$flag=$cur" 2>/dev/null)" -- "$cur")); return ;;
+    esac
+  fi
+  case "$cmdpath" in
+    "") COMPREPLY=($(compgen -W 'server --verbose -v' -- "$cur")) ;;
+    "/debug") COMPREPLY=($(compgen -W '--verbose -v' -- "$cur")) ;;
+    "/server") COMPREPLY=($(compgen -W 'start --env= --config= --name= -n= --verbose -v' -- "$cur")) ;;
+    "/server/start") COMPREPLY=($(compgen -W '--verbose -v' -- "$cur")) ;;
+  esac
+  [[ "${COMPREPLY[0]}" == *= ]] && compopt -o nospace
+}
+complete -F _my_app my-app
+`,
+		},
+		"zsh": {
+			maker: Zsh("my-app"),
+			expected: `#compdef my-app
+# zsh completion for my-app, generated by flick
+_my_app() {
+  local cur="${words[CURRENT]}" cmdpath="" flag="" skip="" word i
+  for ((i = 2; i < CURRENT; i++)); do
+    word="${words[i]}"
+    if [[ -n "$skip" ]]; then
+      skip=""
+      continue
+    fi
+    [[ "$word" == "--" ]] && break
+    case "$cmdpath $word" in
+      "/server --env"|"/server --config"|"/server --name"|"/server -n") skip=1; continue ;;
+    esac
+    case "$cmdpath/$word" in
+      "/debug"|"/server"|"/server/start") cmdpath="$cmdpath/$word" ;;
+      "/srv") cmdpath='/server' ;;
+    esac
+  done
+  if [[ "$cur" == -*=* ]]; then
+    flag="${cur%%=*}"
+    compset -P '*='
+  elif [[ "${words[CURRENT-1]}" == -* ]]; then
+    flag="${words[CURRENT-1]}"
+  fi
+  if [[ -n "$flag" ]]; then
+    case "$cmdpath $flag" in
+      "/server --env") compadd -- 'dev' 'prod'; return ;;
+      "/server --config") _files; return ;;
+      "/server --name"|"/server -n") compadd -- ${(f)"$(my-app __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"}; return ;;
+    esac
+  fi
+  case "$cmdpath" in
+    "") compadd -- server; compadd -- --verbose -v ;;
+    "/debug") compadd -- --verbose -v ;;
+    "/server") compadd -- start; compadd -- --verbose -v; compadd -S '' -- --env= --config= --name= -n= ;;
+    "/server/start") compadd -- --verbose -v ;;
+  esac
+}
+_my_app "$@"
+`,
+		},
+		"fish": {
+			maker: Fish("my-app"),
+			expected: `# fish completion for my-app, generated by flick
+function __my_app_command_path
+  set -l cmdpath ""
+  set -l skip ""
+  for word in (commandline -opc)[2..-1]
+    if test -n "$skip"
+      set skip ""
+      continue
+    end
+    if test "$word" = "--"
+      break
+    end
+    switch "$cmdpath $word"
+      case '/server --env' '/server --config' '/server --name' '/server -n'
+        set skip 1
+        continue
+    end
+    switch "$cmdpath/$word"
+      case '/debug' '/server' '/server/start'
+        set cmdpath "$cmdpath/$word"
+      case '/srv'
+        set cmdpath '/server'
+    end
+  end
+  echo $cmdpath
+end
+complete -c my-app -f
+complete -c my-app -n 'test (__my_app_command_path) = ""' -a 'server'
+complete -c my-app -n 'test (__my_app_command_path) = ""' -l verbose -s v
+complete -c my-app -n 'test (__my_app_command_path) = "/debug"' -l verbose -s v
+complete -c my-app -n 'test (__my_app_command_path) = "/server"' -a 'start'
+complete -c my-app -n 'test (__my_app_command_path) = "/server"' -l env -x -a 'dev prod'
+complete -c my-app -n 'test (__my_app_command_path) = "/server"' -l config -r -F
+complete -c my-app -n 'test (__my_app_command_path) = "/server"' -l name -s n -x -a "(my-app __complete (commandline -opc)[2..-1] (commandline -ct))"
+complete -c my-app -n 'test (__my_app_command_path) = "/server"' -l verbose -s v
+complete -c my-app -n 'test (__my_app_command_path) = "/server/start"' -l verbose -s v
+`,
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := bytes.Buffer{}
+			err := c.maker.Generate(context.TODO(), &testDocument, &actual)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual.String())
+		})
+	}
+}
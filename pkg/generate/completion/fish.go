@@ -0,0 +1,179 @@
+package completion
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"strings"
+)
+
+type fish struct {
+	programName string
+}
+
+// Fish generates a fish completion script for programName. Place it in ~/.config/fish/completions/programName.fish
+func Fish(programName string) generate.Maker {
+	return &fish{
+		programName: programName,
+	}
+}
+
+func (f *fish) Generate(_ context.Context, document *dsl.Document, output io.Writer) (err error) {
+	levels := collectLevels(document)
+	pathFunction := "_" + functionName(f.programName) + "_command_path"
+	out := string_writer.New(output, singleIndent)
+	err = out.WriteLnF("# fish completion for %s, generated by flick", f.programName)
+	if err != nil {
+		return
+	}
+	err = out.WriteLnF("function %s", pathFunction)
+	if err != nil {
+		return
+	}
+	err = out.In(func(out *string_writer.Type) (err error) {
+		for _, line := range []string{
+			`set -l cmdpath ""`,
+			`set -l skip ""`,
+			`for word in (commandline -opc)[2..-1]`,
+			`  if test -n "$skip"`,
+			`    set skip ""`,
+			`    continue`,
+			`  end`,
+			`  if test "$word" = "--"`,
+			`    break`,
+			`  end`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		// the word after a flag that takes a value is that value, rather than a command
+		var valueFlags []string
+		for _, level := range levels {
+			for _, option := range level.options {
+				if !option.TakesValue() {
+					continue
+				}
+				for _, name := range option.Flag.Names() {
+					valueFlags = append(valueFlags, shellQuote(level.path+" "+name))
+				}
+			}
+		}
+		if len(valueFlags) != 0 {
+			for _, line := range []string{
+				`  switch "$cmdpath $word"`,
+				`    case ` + strings.Join(valueFlags, " "),
+				`      set skip 1`,
+				`      continue`,
+				`  end`,
+			} {
+				err = out.WriteLn(line)
+				if err != nil {
+					return
+				}
+			}
+		}
+		err = out.WriteLn(`  switch "$cmdpath/$word"`)
+		if err != nil {
+			return
+		}
+		paths := make([]string, 0, len(levels))
+		for _, level := range levels[1:] {
+			paths = append(paths, shellQuote(level.path))
+		}
+		if len(paths) != 0 {
+			err = out.WriteLn(`    case ` + strings.Join(paths, " "))
+			if err != nil {
+				return
+			}
+			err = out.WriteLn(`      set cmdpath "$cmdpath/$word"`)
+			if err != nil {
+				return
+			}
+		}
+		for _, level := range levels[1:] {
+			if len(level.aliases) == 0 {
+				continue
+			}
+			aliases := make([]string, len(level.aliases))
+			for i, alias := range level.aliases {
+				aliases[i] = shellQuote(alias)
+			}
+			err = out.WriteLn(`    case ` + strings.Join(aliases, " "))
+			if err != nil {
+				return
+			}
+			err = out.WriteLn(`      set cmdpath ` + shellQuote(level.path))
+			if err != nil {
+				return
+			}
+		}
+		for _, line := range []string{
+			`  end`,
+			`end`,
+			`echo $cmdpath`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	err = out.WriteLn("end")
+	if err != nil {
+		return
+	}
+	err = out.WriteLnF("complete -c %s -f", f.programName)
+	if err != nil {
+		return
+	}
+	for _, level := range levels {
+		condition := shellQuote(`test (` + pathFunction + `) = "` + level.path + `"`)
+		for _, command := range level.commands {
+			err = out.WriteLnF("complete -c %s -n %s -a %s", f.programName, condition, shellQuote(command))
+			if err != nil {
+				return
+			}
+		}
+		for _, option := range level.options {
+			err = out.WriteLnF("complete -c %s -n %s%s", f.programName, condition, f.optionArguments(option))
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (f *fish) optionArguments(option dsl.Option) string {
+	args := strings.Builder{}
+	for _, name := range option.Flag.Names() {
+		if strings.HasPrefix(name, "--") {
+			args.WriteString(" -l " + strings.TrimPrefix(name, "--"))
+		} else {
+			args.WriteString(" -s " + strings.TrimPrefix(name, "-"))
+		}
+	}
+	option.Description.IfSet(func(description string) {
+		args.WriteString(" -d " + shellQuote(strings.SplitN(description, "\n", 2)[0]))
+	})
+	if !option.TakesValue() {
+		return args.String()
+	}
+	switch {
+	case len(option.Enum) != 0:
+		args.WriteString(" -x -a " + shellQuote(strings.Join(option.Enum, " ")))
+	case option.FilePath:
+		args.WriteString(" -r -F")
+	default:
+		args.WriteString(` -x -a "(` + f.programName + ` ` + CommandName + ` (commandline -opc)[2..-1] (commandline -ct))"`)
+	}
+	return args.String()
+}
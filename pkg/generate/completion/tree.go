@@ -0,0 +1,125 @@
+package completion
+
+import (
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// CommandName is the hidden command the completion scripts call to complete option values dynamically
+	CommandName = "__complete"
+
+	commandPathSeparator = "/"
+)
+
+// commandLevel is everything that can be completed after the commands in path were typed
+type commandLevel struct {
+	// path is the command names joined and prefixed by commandPathSeparator, the root is blank
+	path string
+	// aliases are the other paths that lead to this level, each ends with an alias of the command instead of its name
+	aliases  []string
+	commands []string
+	options  []dsl.Option
+}
+
+// commandPath is the list of command names leading to this level, empty for the root
+func (l commandLevel) commandPath() []string {
+	if l.path == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimPrefix(l.path, commandPathSeparator), commandPathSeparator)
+}
+
+// collectLevels lists the root followed by every command, depth-first with siblings in name order
+func collectLevels(document *dsl.Document) (out []commandLevel) {
+	out = append(out, newCommandLevel(document, []string{}, document.Commands))
+	collectLevelsRecursive(document, document.Commands, []string{}, &out)
+	return
+}
+
+func collectLevelsRecursive(document *dsl.Document, commands dsl.NamedCommands, parentPath []string, out *[]commandLevel) {
+	for _, name := range sortedCommandNames(commands) {
+		command := commands[name]
+		path := append(append(make([]string, 0, len(parentPath)+1), parentPath...), name)
+		level := newCommandLevel(document, path, command.Commands)
+		parentLevelPath := strings.TrimSuffix(level.path, name)
+		for _, alias := range command.Aliases {
+			level.aliases = append(level.aliases, parentLevelPath+alias)
+		}
+		*out = append(*out, level)
+		collectLevelsRecursive(document, command.Commands, path, out)
+	}
+}
+
+// newCommandLevel offers the commands that are not hidden and the flags of every option accepted at path, including
+// those inherited from its ancestors, except for hidden options. Hidden commands still have a level of their own, so
+// that their options complete once they are typed
+func newCommandLevel(document *dsl.Document, path []string, commands dsl.NamedCommands) commandLevel {
+	level := commandLevel{}
+	for _, name := range sortedCommandNames(commands) {
+		if !commands[name].Hidden {
+			level.commands = append(level.commands, name)
+		}
+	}
+	if len(path) != 0 {
+		level.path = commandPathSeparator + strings.Join(path, commandPathSeparator)
+	}
+	accepted, _ := document.AcceptedOptions(path)
+	for _, option := range accepted {
+		if len(option.Flag.Names()) != 0 && !option.Hidden {
+			level.options = append(level.options, option)
+		}
+	}
+	return level
+}
+
+func sortedCommandNames(commands dsl.NamedCommands) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagWords are the words offered when completing a flag name. Flags taking a value end with "="
+func flagWords(option dsl.Option) (out []string) {
+	for _, name := range option.Flag.Names() {
+		if option.TakesValue() {
+			name += "="
+		}
+		out = append(out, name)
+	}
+	return
+}
+
+func hasDynamicValues(option dsl.Option) bool {
+	return option.TakesValue() && len(option.Enum) == 0 && !option.FilePath
+}
+
+// NeedsValueCompleter is true when the completion scripts of document call the hidden __complete command, which they
+// do for options with neither an Enum nor a FilePath
+func NeedsValueCompleter(document *dsl.Document) bool {
+	for _, level := range collectLevels(document) {
+		for _, option := range level.options {
+			if hasDynamicValues(option) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var nonIdentifierCharacters = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// functionName converts the program name into something usable as a shell function name
+func functionName(programName string) string {
+	return "_" + nonIdentifierCharacters.ReplaceAllString(programName, "_")
+}
+
+// shellQuote quotes v in single quotes for use in any of the supported shells
+func shellQuote(v string) string {
+	return "'" + strings.Replace(v, "'", `'\''`, -1) + "'"
+}
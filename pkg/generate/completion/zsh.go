@@ -0,0 +1,184 @@
+package completion
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"strings"
+)
+
+type zsh struct {
+	programName string
+}
+
+// Zsh generates a zsh completion script for programName. Place it in your fpath as _programName
+func Zsh(programName string) generate.Maker {
+	return &zsh{
+		programName: programName,
+	}
+}
+
+func (z *zsh) Generate(_ context.Context, document *dsl.Document, output io.Writer) (err error) {
+	levels := collectLevels(document)
+	out := string_writer.New(output, singleIndent)
+	err = out.WriteLnF("#compdef %s", z.programName)
+	if err != nil {
+		return
+	}
+	err = out.WriteLnF("# zsh completion for %s, generated by flick", z.programName)
+	if err != nil {
+		return
+	}
+	err = out.WriteLnF("%s() {", functionName(z.programName))
+	if err != nil {
+		return
+	}
+	err = out.In(func(out *string_writer.Type) (err error) {
+		// "path" is special in zsh, it is tied to PATH
+		for _, line := range []string{
+			`local cur="${words[CURRENT]}" cmdpath="" flag="" skip="" word i`,
+			`for ((i = 2; i < CURRENT; i++)); do`,
+			`  word="${words[i]}"`,
+			`  if [[ -n "$skip" ]]; then`,
+			`    skip=""`,
+			`    continue`,
+			`  fi`,
+			`  [[ "$word" == "--" ]] && break`,
+			`  case "$cmdpath $word" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = writeValueFlagCases(out, levels, `    %s) skip=1; continue ;;`)
+		if err != nil {
+			return
+		}
+		for _, line := range []string{
+			`  esac`,
+			`  case "$cmdpath/$word" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = writeCommandPathCases(out, levels, `    %s) cmdpath="$cmdpath/$word" ;;`)
+		if err != nil {
+			return
+		}
+		for _, line := range []string{
+			`  esac`,
+			`done`,
+			`if [[ "$cur" == -*=* ]]; then`,
+			`  flag="${cur%%=*}"`,
+			`  compset -P '*='`,
+			`elif [[ "${words[CURRENT-1]}" == -* ]]; then`,
+			`  flag="${words[CURRENT-1]}"`,
+			`fi`,
+			`if [[ -n "$flag" ]]; then`,
+			`  case "$cmdpath $flag" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = out.In(func(out *string_writer.Type) error {
+			return out.In(func(out *string_writer.Type) error {
+				return z.writeValueCases(out, levels)
+			})
+		})
+		if err != nil {
+			return
+		}
+		for _, line := range []string{
+			`  esac`,
+			`fi`,
+			`case "$cmdpath" in`,
+		} {
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = out.In(func(out *string_writer.Type) error {
+			return z.writeWordCases(out, levels)
+		})
+		if err != nil {
+			return
+		}
+		return out.WriteLn(`esac`)
+	})
+	if err != nil {
+		return
+	}
+	err = out.WriteLn("}")
+	if err != nil {
+		return
+	}
+	return out.WriteLnF(`%s "$@"`, functionName(z.programName))
+}
+
+func (z *zsh) writeValueCases(out *string_writer.Type, levels []commandLevel) (err error) {
+	for _, level := range levels {
+		for _, option := range level.options {
+			if !option.TakesValue() {
+				continue
+			}
+			reply := ""
+			switch {
+			case len(option.Enum) != 0:
+				quoted := make([]string, len(option.Enum))
+				for i, value := range option.Enum {
+					quoted[i] = shellQuote(value)
+				}
+				reply = `compadd -- ` + strings.Join(quoted, " ")
+			case option.FilePath:
+				reply = `_files`
+			default:
+				reply = `compadd -- ${(f)"$(` + z.programName + ` ` + CommandName + ` "${(@)words[2,CURRENT]}" 2>/dev/null)"}`
+			}
+			// switches given before the word being completed fall through to completing words
+			err = out.WriteLnF(`%s) %s; return ;;`, casePatterns(level.path+" ", option.Flag.Names()), reply)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (z *zsh) writeWordCases(out *string_writer.Type, levels []commandLevel) (err error) {
+	for _, level := range levels {
+		replies := make([]string, 0, 3)
+		if len(level.commands) != 0 {
+			replies = append(replies, `compadd -- `+strings.Join(level.commands, " "))
+		}
+		var switches, valueFlags []string
+		for _, option := range level.options {
+			if option.TakesValue() {
+				valueFlags = append(valueFlags, flagWords(option)...)
+			} else {
+				switches = append(switches, flagWords(option)...)
+			}
+		}
+		if len(switches) != 0 {
+			replies = append(replies, `compadd -- `+strings.Join(switches, " "))
+		}
+		if len(valueFlags) != 0 {
+			replies = append(replies, `compadd -S '' -- `+strings.Join(valueFlags, " "))
+		}
+		if len(replies) == 0 {
+			continue
+		}
+		err = out.WriteLnF(`%s) %s ;;`, casePatterns("", []string{level.path}), strings.Join(replies, "; "))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
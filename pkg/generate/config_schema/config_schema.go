@@ -0,0 +1,244 @@
+package config_schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	draft = "http://json-schema.org/draft-07/schema#"
+
+	// profileDefaultKey and profileSectionsKey are the keys of a file laid out in profiles, see parse.Profiles
+	profileDefaultKey  = "default"
+	profileSectionsKey = "profiles"
+
+	// durationPattern matches the values accepted by time.ParseDuration
+	durationPattern = `^[-+]?(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+$|^0$`
+)
+
+// ConfigSchema generates a JSON Schema describing the configuration file, in the shape parse.ConfigFileSource reads it.
+// The file is flat, the root and every command read their options from its top level keys, so every option with a file
+// key is a property of the same object, each key once. The required options of the root are required. The file may
+// also be laid out in profiles, see parse.Profiles, with the same properties in "default" and in each of "profiles"
+type ConfigSchema struct {
+}
+
+const (
+	optionsDefinition = "options"
+	optionsReference  = "#/definitions/" + optionsDefinition
+)
+
+type schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Minimum     *int64             `json:"minimum,omitempty"`
+	Maximum     *int64             `json:"maximum,omitempty"`
+	Default     interface{}        `json:"default,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is false, or the schema of the properties that are not in Properties
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	AnyOf                []*schema          `json:"anyOf,omitempty"`
+	Definitions          map[string]*schema `json:"definitions,omitempty"`
+}
+
+// integerRange is the smallest and largest value of a fixed size integer type
+type integerRange struct {
+	minimum, maximum int64
+}
+
+// integerRanges bounds the integer types that are not 64 bits, the larger ones cannot be bounded exactly as JSON
+// numbers, so only the unsigned ones have a minimum
+var integerRanges = map[string]integerRange{
+	"int8":   {math.MinInt8, math.MaxInt8},
+	"int16":  {math.MinInt16, math.MaxInt16},
+	"int32":  {math.MinInt32, math.MaxInt32},
+	"rune":   {math.MinInt32, math.MaxInt32},
+	"uint8":  {0, math.MaxUint8},
+	"byte":   {0, math.MaxUint8},
+	"uint16": {0, math.MaxUint16},
+	"uint32": {0, math.MaxUint32},
+}
+
+func newObjectSchema() *schema {
+	noAdditional := false
+	return &schema{
+		Type:                 "object",
+		Properties:           make(map[string]*schema),
+		AdditionalProperties: &noAdditional,
+	}
+}
+
+func (c *ConfigSchema) Generate(_ context.Context, document *dsl.Document, output io.Writer) (err error) {
+	options := newObjectSchema()
+	err = addOptions(options, document.Options, []string{})
+	if err != nil {
+		return
+	}
+	// only the root's options are required, those of a command are only needed when it runs, and profiles may leave
+	// them to the default section
+	required := options.Required
+	err = addCommands(options, document.Commands, []string{})
+	if err != nil {
+		return
+	}
+	options.Required = nil
+
+	flat := &schema{Ref: optionsReference}
+	if len(required) != 0 {
+		flat = &schema{AllOf: []*schema{flat, {Required: required}}}
+	}
+	profiles := newObjectSchema()
+	profiles.Properties[profileDefaultKey] = &schema{Ref: optionsReference}
+	profiles.Properties[profileSectionsKey] = &schema{
+		Type:                 "object",
+		AdditionalProperties: &schema{Ref: optionsReference},
+	}
+	root := &schema{
+		Schema:      draft,
+		AnyOf:       []*schema{flat, profiles},
+		Definitions: map[string]*schema{optionsDefinition: options},
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(root)
+}
+
+// addCommands adds the options of commands, and of their sub-commands, to options, in name order. A key is only added
+// for the first option that has it
+func addCommands(options *schema, commands dsl.NamedCommands, prefix []string) (err error) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command := commands[name]
+		path := append(append(make([]string, 0, len(prefix)+1), prefix...), name)
+		err = addOptions(options, command.Options, path)
+		if err != nil {
+			return
+		}
+		err = addCommands(options, command.Commands, path)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func addOptions(parent *schema, options []dsl.OptionOrReference, prefix []string) (err error) {
+	for _, option := range options {
+		if _, added := parent.Properties[option.File.Name]; option.File.Name == "" || added {
+			continue
+		}
+		var property *schema
+		property, err = optionSchema(option.Option)
+		if err != nil {
+			return &dsl.ErrInvalidValue{
+				Reason:   fmt.Sprintf(`option "%s": %s`, strings.Join(append(prefix, option.Name), "/"), err.Error()),
+				Position: option.Position,
+			}
+		}
+		parent.Properties[option.File.Name] = property
+		if option.Required {
+			parent.Required = append(parent.Required, option.File.Name)
+		}
+	}
+	return
+}
+
+func optionSchema(option dsl.Option) (out *schema, err error) {
+	out = &schema{}
+	option.Description.IfSet(func(description string) {
+		out.Description = description
+	})
+	var convert func(string) (interface{}, error)
+	switch option.Type {
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		out.Type = "integer"
+		convert = func(v string) (interface{}, error) {
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64", "byte", dsl.TypeCount:
+		out.Type = "integer"
+		var zero int64
+		out.Minimum = &zero
+		convert = func(v string) (interface{}, error) {
+			return strconv.ParseUint(v, 10, 64)
+		}
+	case "float32", "float64":
+		out.Type = "number"
+		convert = func(v string) (interface{}, error) {
+			return strconv.ParseFloat(v, 64)
+		}
+	case "bool":
+		out.Type = "boolean"
+		convert = func(v string) (interface{}, error) {
+			return strconv.ParseBool(v)
+		}
+	case "string":
+		out.Type = "string"
+	case "duration":
+		out.Type = "string"
+		out.Pattern = durationPattern
+	case "time":
+		out.Type = "string"
+		out.Format = "date-time"
+	default:
+		err = fmt.Errorf(`unsupported option type: "%s"`, option.Type)
+		return
+	}
+	if bounds, ok := integerRanges[option.Type]; ok {
+		out.Minimum, out.Maximum = &bounds.minimum, &bounds.maximum
+	}
+	if convert == nil {
+		convert = func(v string) (interface{}, error) {
+			return v, nil
+		}
+	}
+	option.Default.IfSet(func(value string) {
+		out.Default, err = convert(value)
+	})
+	if err != nil {
+		err = fmt.Errorf("default value is not a valid %s: %s", option.Type, err.Error())
+		return
+	}
+	for _, value := range option.Enum {
+		var converted interface{}
+		converted, err = convert(value)
+		if err != nil {
+			err = fmt.Errorf("enum value is not a valid %s: %s", option.Type, err.Error())
+			return
+		}
+		out.Enum = append(out.Enum, converted)
+	}
+	if option.List {
+		// a default is a list of just that value
+		out = &schema{
+			Description: out.Description,
+			Type:        "array",
+			Items:       out,
+		}
+		if out.Items.Default != nil {
+			out.Default = []interface{}{out.Items.Default}
+		}
+		out.Items.Description, out.Items.Default = "", nil
+	}
+	return
+}
@@ -0,0 +1,253 @@
+package config_schema
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/go-optional/v2"
+	"testing"
+)
+
+func TestConfigSchema_Generate(t *testing.T) {
+	cases := map[string]struct {
+		input       dsl.Document
+		expected    string
+		expectedErr string
+	}{
+		"empty": {
+			input:    dsl.Document{},
+			expected: configFile(flat, `{"type": "object", "additionalProperties": false}`),
+		},
+		"typed options": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name:        "profile",
+							Type:        "string",
+							Description: optional.StringFrom("the profile to use"),
+							File:        dsl.FileDef{Name: "profile"},
+							Enum:        []string{"dev", "prod"},
+							Required:    true,
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name:    "retries",
+							Type:    "uint",
+							File:    dsl.FileDef{Name: "retries"},
+							Default: optional.StringFrom("3"),
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name: "flagOnly",
+							Type: "bool",
+							Flag: dsl.FlagDef{Name: "flagOnly"},
+						},
+					},
+				},
+				Commands: dsl.NamedCommands{
+					"server": dsl.Command{
+						Description: optional.StringFrom("manage the server"),
+						Options: []dsl.OptionOrReference{
+							{
+								Option: dsl.Option{
+									Name:    "connectTimeout",
+									Type:    "duration",
+									File:    dsl.FileDef{Name: "connectTimeout"},
+									Default: optional.StringFrom("30s"),
+								},
+							},
+							{
+								Option: dsl.Option{
+									Name:     "host",
+									Type:     "string",
+									File:     dsl.FileDef{Name: "host"},
+									Required: true,
+								},
+							},
+						},
+					},
+					"client": dsl.Command{
+						Options: []dsl.OptionOrReference{
+							{
+								Option: dsl.Option{
+									Name: "host",
+									Type: "string",
+									File: dsl.FileDef{Name: "host"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: configFile(`{"allOf": [`+flat+`, {"required": ["profile"]}]}`, `{
+  "type": "object",
+  "properties": {
+    "connectTimeout": {
+      "type": "string",
+      "pattern": "^[-+]?(([0-9]+(\\.[0-9]*)?|\\.[0-9]+)(ns|us|µs|ms|s|m|h))+$|^0$",
+      "default": "30s"
+    },
+    "host": {
+      "type": "string"
+    },
+    "profile": {
+      "description": "the profile to use",
+      "type": "string",
+      "enum": ["dev", "prod"]
+    },
+    "retries": {
+      "type": "integer",
+      "minimum": 0,
+      "default": 3
+    }
+  },
+  "additionalProperties": false
+}`),
+		},
+		"bounded integers": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name: "level",
+							Type: "int8",
+							File: dsl.FileDef{Name: "level"},
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name: "port",
+							Type: "uint16",
+							File: dsl.FileDef{Name: "port"},
+						},
+					},
+				},
+			},
+			expected: configFile(flat, `{
+  "type": "object",
+  "properties": {
+    "level": {
+      "type": "integer",
+      "minimum": -128,
+      "maximum": 127
+    },
+    "port": {
+      "type": "integer",
+      "minimum": 0,
+      "maximum": 65535
+    }
+  },
+  "additionalProperties": false
+}`),
+		},
+		"count and list options": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name: "verbose",
+							Type: "count",
+							File: dsl.FileDef{Name: "verbose"},
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name:        "include",
+							Type:        "string",
+							Description: optional.StringFrom("paths to search"),
+							File:        dsl.FileDef{Name: "include"},
+							List:        true,
+							Default:     optional.StringFrom("/usr/include"),
+						},
+					},
+				},
+			},
+			expected: configFile(flat, `{
+  "type": "object",
+  "properties": {
+    "include": {
+      "description": "paths to search",
+      "type": "array",
+      "default": [
+        "/usr/include"
+      ],
+      "items": {
+        "type": "string"
+      }
+    },
+    "verbose": {
+      "type": "integer",
+      "minimum": 0
+    }
+  },
+  "additionalProperties": false
+}`),
+		},
+		"invalid default": {
+			input: dsl.Document{
+				Commands: dsl.NamedCommands{
+					"server": dsl.Command{
+						Options: []dsl.OptionOrReference{
+							{
+								Option: dsl.Option{
+									Name:    "retries",
+									Type:    "int",
+									File:    dsl.FileDef{Name: "retries"},
+									Default: optional.StringFrom("many"),
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErr: `invalid value: option "server/retries": default value is not a valid int: strconv.ParseInt: parsing "many": invalid syntax`,
+		},
+	}
+
+	g := ConfigSchema{}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := bytes.Buffer{}
+			err := g.Generate(context.TODO(), &c.input, &actual)
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, c.expected, actual.String())
+		})
+	}
+}
+
+// flat is the shape of a configuration file that sets the options at its top level
+const flat = `{"$ref": "#/definitions/options"}`
+
+// configFile is the schema of a configuration file that is either flat, or that sets the options of each profile, with
+// the options the root and every command read from it
+func configFile(flat, options string) string {
+	return `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "anyOf": [
+    ` + flat + `,
+    {
+      "type": "object",
+      "properties": {
+        "default": {"$ref": "#/definitions/options"},
+        "profiles": {
+          "type": "object",
+          "additionalProperties": {"$ref": "#/definitions/options"}
+        }
+      },
+      "additionalProperties": false
+    }
+  ],
+  "definitions": {
+    "options": ` + options + `
+  }
+}`
+}
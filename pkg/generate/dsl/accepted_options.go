@@ -0,0 +1,57 @@
+package dsl
+
+import flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+
+// AcceptedOptions lists the options that the command at path accepts on the command line: its own options followed by
+// the persistent options of each ancestor, nearest first, ending with the document's. The root is the empty path and
+// commands in path may be given by name or alias. ok is false when there is no command at path. References must already be replaced, as they are by Parse
+func (d *Document) AcceptedOptions(path []string) (out []Option, ok bool) {
+	levels := [][]OptionOrReference{d.Options}
+	commands := d.Commands
+	for _, name := range path {
+		var command Command
+		_, command, ok = commands.Find(name)
+		if !ok {
+			return
+		}
+		levels = append(levels, command.Options)
+		commands = command.Commands
+	}
+	for _, option := range levels[len(levels)-1] {
+		out = append(out, option.Option)
+	}
+	for i := len(levels) - 2; i >= 0; i-- {
+		for _, option := range levels[i] {
+			if option.IsPersistent() {
+				out = append(out, option.Option)
+			}
+		}
+	}
+	return out, true
+}
+
+// TakesValue is true when flag, given to the command that the last of groups is given to, is the flag of an option
+// that takes a value. It is a parse.TakesValue for splitting a command line of this document with parse.SplitGNU
+func (d *Document) TakesValue(groups []flag_unmarshaler.Group, flag string) bool {
+	path := make([]string, 0, len(groups))
+	commands := d.Commands
+	for _, group := range groups[1:] {
+		// once a group is not a command, the rest are arguments of the last command
+		name, command, ok := commands.Find(group.CommandName)
+		if !ok {
+			break
+		}
+		path = append(path, name)
+		commands = command.Commands
+	}
+	accepted, _ := d.AcceptedOptions(path)
+	for _, option := range accepted {
+		for _, name := range option.Flag.Names() {
+			// negated flags, such as --no-verbose, never take a value
+			if name == flag {
+				return option.TakesValue()
+			}
+		}
+	}
+	return false
+}
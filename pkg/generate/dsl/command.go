@@ -1,11 +1,16 @@
 package dsl
 
 import (
+	"fmt"
 	"github.com/wojnosystems/go-optional/v2"
 	"github.com/wojnosystems/okey-dokey/bad"
 )
 
 type Command struct {
+	// Reference is the command in components to use in place of this one, e.g. "#/components/commands/Server".
+	// The other fields are ignored when it is set
+	Reference string `yaml:"$ref"`
+
 	// Usage explains how to use this option in a one-liner
 	// e.g. --name=Bob
 	Usage optional.String `yaml:"usage"`
@@ -19,6 +24,16 @@ type Command struct {
 	// Commands are the sub-commands of this command
 	Commands NamedCommands `yaml:"commands"`
 
+	// Aliases are other names that run this command, e.g. "rm" for "remove"
+	Aliases []string `yaml:"aliases"`
+
+	// Hidden commands run as usual, but are left out of help, documentation and completion
+	Hidden bool `yaml:"hidden"`
+
+	// Deprecated explains what to use instead of this command, e.g. "use remove instead". A warning is printed when
+	// a deprecated command is used
+	Deprecated string `yaml:"deprecated"`
+
 	// MinArgs is the minimum number of arguments that this command takes,
 	// this is incompatible with Commands, as commands are "arguments" and is ignored when Commands is not empty
 	MinArgs uint `yaml:"minArgs"`
@@ -26,6 +41,9 @@ type Command struct {
 	// MaxArgs is the maximum number of arguments that this command takes,
 	// this is incompatible with Commands, as commands are "arguments" and is ignored when Commands is not empty
 	MaxArgs uint `yaml:"maxArgs"`
+
+	// Position is where the command was declared, it is set by Parse
+	Position Position `yaml:"-"`
 }
 
 var commandValidations = commandValidationDefs{}
@@ -34,21 +52,43 @@ type commandValidationDefs struct {
 }
 
 func (d commandValidationDefs) Validate(on *Command, emitter bad.MemberEmitter) {
-	validateMinMaxArgs(on.MinArgs, on.MaxArgs, emitter)
-	validateMaxArgsWithSubCommands(on.MaxArgs, on.Commands, emitter)
+	validateMinMaxArgs(on.MinArgs, on.MaxArgs, on.Position, emitter)
+	validateMaxArgsWithSubCommands(on.MaxArgs, on.Commands, on.Position, emitter)
+	for _, option := range on.Options {
+		optionValidations.Validate(&option.Option, emitter)
+	}
+	validateCommandAliases(on.Commands, emitter)
 	for commandName, command := range on.Commands {
 		commandValidations.Validate(&command, emitter.Into(commandName))
 	}
 }
 
-func validateMinMaxArgs(minArgs, maxArgs uint, emitter bad.Emitter) {
+func validateMinMaxArgs(minArgs, maxArgs uint, position Position, emitter bad.Emitter) {
 	if minArgs > maxArgs {
-		emitter.Emit("minArgs must be less than maxArgs")
+		emitter.Emit(atPosition("minArgs must be less than maxArgs", position))
 	}
 }
 
-func validateMaxArgsWithSubCommands(maxArgs uint, commands NamedCommands, emitter bad.MemberEmitter) {
+func validateMaxArgsWithSubCommands(maxArgs uint, commands NamedCommands, position Position, emitter bad.MemberEmitter) {
 	if commands.HasAny() && maxArgs != 0 {
-		emitter.Emit("when sub-commands are specified, maxArgs must be 0")
+		emitter.Emit(atPosition("when sub-commands are specified, maxArgs must be 0", position))
+	}
+}
+
+// validateCommandAliases checks that no alias is the name or alias of a sibling command, or repeats the command's own name
+func validateCommandAliases(commands NamedCommands, emitter bad.MemberEmitter) {
+	claimed := make(map[string]string, len(commands))
+	for _, name := range commands.names() {
+		claimed[name] = name
+	}
+	for _, name := range commands.names() {
+		command := commands[name]
+		for _, alias := range command.Aliases {
+			if previous, ok := claimed[alias]; ok {
+				emitter.Into(name).Emit(atPosition(fmt.Sprintf(`alias "%s" is already used by command "%s"`, alias, previous), command.Position))
+				continue
+			}
+			claimed[alias] = name
+		}
 	}
 }
@@ -2,4 +2,8 @@ package dsl
 
 type Components struct {
 	Options NamedOptions `yaml:"options"`
+	// Commands are reusable commands, along with their options and sub-commands
+	Commands NamedCommands `yaml:"commands"`
+	// OptionGroups are reusable lists of options, a reference to a group adds every option in it
+	OptionGroups NamedOptionGroups `yaml:"optionGroups"`
 }
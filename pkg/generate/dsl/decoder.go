@@ -0,0 +1,309 @@
+package dsl
+
+import (
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	parse_register "github.com/wojnosystems/go-parse-register"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+var positionType = reflect.TypeOf(Position{})
+
+// decoder reads an optionapi document. Unlike the decoder used for configuration files, every key must be part of
+// the DSL when strict. Misspelled keys, such as minArg, are reported with their line and column instead of being ignored.
+// Structs with a Position field have it set to where they were declared
+type decoder struct {
+	registry parse_register.ValueSetter
+	// fileName is recorded in every Position, leave blank if the document was not read from a file
+	fileName string
+	// strict decoders fail on the first key that is not part of the DSL, others skip it and record it in unknownFields
+	strict        bool
+	unknownFields []*ErrUnknownField
+	// anchors are the nodes named with &name in the document being decoded, so *name can be read again
+	anchors map[string]ast.Node
+}
+
+func newDecoder(registry parse_register.ValueSetter, fileName string) *decoder {
+	return &decoder{
+		registry: registry,
+		fileName: fileName,
+		strict:   true,
+	}
+}
+
+func (d *decoder) Decode(r io.Reader, out interface{}) (err error) {
+	buffer, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	tree, err := parser.ParseBytes(buffer, 0)
+	if err != nil {
+		return
+	}
+	outV := reflect.ValueOf(out).Elem()
+	for _, doc := range tree.Docs {
+		d.anchors = make(map[string]ast.Node)
+		err = d.walk(doc, outV)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (d *decoder) walk(node ast.Node, out reflect.Value) (err error) {
+	switch n := node.(type) {
+	case nil, *ast.NullNode, *ast.CommentNode:
+		return
+	case *ast.DocumentNode:
+		return d.walk(n.Body, out)
+	case *ast.MappingNode:
+		if out.Kind() != reflect.Struct && out.Kind() != reflect.Map {
+			return d.errInvalidValue(node, "expected "+d.describe(out)+", not a mapping")
+		}
+		if len(n.Values) != 0 {
+			d.setPosition(n.Values[0].Key, out)
+		}
+		// merged keys are read first, so the keys declared next to them take precedence
+		for _, value := range n.Values {
+			if isMergeKey(value) {
+				err = d.walkMerge(value.Value, out)
+				if err != nil {
+					return
+				}
+			}
+		}
+		for _, value := range n.Values {
+			if isMergeKey(value) {
+				continue
+			}
+			err = d.walk(value, out)
+			if err != nil {
+				return
+			}
+		}
+	case *ast.MappingValueNode:
+		if out.Kind() != reflect.Struct && out.Kind() != reflect.Map {
+			return d.errInvalidValue(node, "expected "+d.describe(out)+", not a mapping")
+		}
+		d.setPosition(n.Key, out)
+		if isMergeKey(n) {
+			return d.walkMerge(n.Value, out)
+		}
+		return d.walkMappingValue(n, out)
+	case *ast.AnchorNode:
+		d.anchors[scalarText(n.Name)] = n.Value
+		return d.walk(n.Value, out)
+	case *ast.AliasNode:
+		anchored, err := d.anchored(n)
+		if err != nil {
+			return err
+		}
+		return d.walk(anchored, out)
+	case *ast.TagNode:
+		// the DSL types every key, so explicit tags such as !!str add nothing
+		return d.walk(n.Value, out)
+	case *ast.SequenceNode:
+		if out.Kind() != reflect.Slice {
+			return d.errInvalidValue(node, "expected "+d.describe(out)+", not a list")
+		}
+		out.Set(reflect.MakeSlice(out.Type(), len(n.Values), len(n.Values)))
+		for i, value := range n.Values {
+			err = d.walk(value, out.Index(i))
+			if err != nil {
+				return
+			}
+		}
+	case *ast.StringNode:
+		return d.setScalar(node, n.Value, out)
+	case *ast.LiteralNode:
+		return d.setScalar(node, n.Value.Value, out)
+	case *ast.IntegerNode, *ast.FloatNode, *ast.BoolNode:
+		return d.setScalar(node, node.GetToken().Value, out)
+	default:
+		return d.errInvalidValue(node, "unsupported yaml feature: "+node.Type().String())
+	}
+	return
+}
+
+// walkMerge reads the mappings merged with "<<" into out. node is a mapping, an alias of one, or a list of either
+func (d *decoder) walkMerge(node ast.Node, out reflect.Value) (err error) {
+	switch n := node.(type) {
+	case *ast.AliasNode:
+		anchored, err := d.anchored(n)
+		if err != nil {
+			return err
+		}
+		return d.walkMerge(anchored, out)
+	case *ast.AnchorNode:
+		d.anchors[scalarText(n.Name)] = n.Value
+		return d.walkMerge(n.Value, out)
+	case *ast.SequenceNode:
+		// earlier mappings in the list take precedence over later ones
+		for i := len(n.Values) - 1; i >= 0; i-- {
+			err = d.walkMerge(n.Values[i], out)
+			if err != nil {
+				return
+			}
+		}
+		return
+	case *ast.MappingNode, *ast.MappingValueNode:
+		return d.walk(n, out)
+	default:
+		return d.errInvalidValue(node, "only mappings can be merged with <<")
+	}
+}
+
+// anchored is the node declared with the anchor that alias refers to
+func (d *decoder) anchored(alias *ast.AliasNode) (node ast.Node, err error) {
+	name := scalarText(alias.Value)
+	node, ok := d.anchors[name]
+	if !ok {
+		err = d.errInvalidValue(alias, "unknown anchor: "+name)
+	}
+	return
+}
+
+func isMergeKey(n *ast.MappingValueNode) bool {
+	_, ok := n.Key.(*ast.MergeKeyNode)
+	return ok
+}
+
+func (d *decoder) walkMappingValue(n *ast.MappingValueNode, out reflect.Value) (err error) {
+	key := scalarText(n.Key)
+	switch out.Kind() {
+	case reflect.Struct:
+		field, ok := fieldWithYamlName(out, key)
+		if !ok {
+			unknown := &ErrUnknownField{
+				Field:    key,
+				Position: newPosition(d.fileName, n.Key),
+			}
+			if d.strict {
+				return unknown
+			}
+			d.unknownFields = append(d.unknownFields, unknown)
+			return
+		}
+		return d.walk(n.Value, field)
+	case reflect.Map:
+		if out.IsNil() {
+			out.Set(reflect.MakeMap(out.Type()))
+		}
+		keyOut := reflect.New(out.Type().Key()).Elem()
+		err = d.setScalar(n.Key, key, keyOut)
+		if err != nil {
+			return
+		}
+		valueOut := reflect.New(out.Type().Elem()).Elem()
+		// named entries, such as commands, are declared by their key, even when they have no value
+		d.setPosition(n.Key, valueOut)
+		err = d.walk(n.Value, valueOut)
+		if err != nil {
+			return
+		}
+		out.SetMapIndex(keyOut, valueOut)
+	default:
+		return d.errInvalidValue(n, "expected "+d.describe(out)+", not a mapping")
+	}
+	return
+}
+
+func (d *decoder) setScalar(node ast.Node, value string, out reflect.Value) (err error) {
+	ok, err := d.registry.SetValue(out.Addr().Interface(), value)
+	if err != nil {
+		return d.errInvalidValue(node, err.Error())
+	}
+	if !ok {
+		return d.errInvalidValue(node, "expected "+d.describe(out)+", not a value")
+	}
+	return
+}
+
+func (d *decoder) errInvalidValue(node ast.Node, reason string) *ErrInvalidValue {
+	return &ErrInvalidValue{
+		Reason:   reason,
+		Position: newPosition(d.fileName, node),
+	}
+}
+
+// setPosition records the position of node in the Position field of out, if it has one that is not yet set
+func (d *decoder) setPosition(node ast.Node, out reflect.Value) {
+	if out.Kind() != reflect.Struct {
+		return
+	}
+	field, ok := positionField(out)
+	if !ok {
+		return
+	}
+	position := field.Addr().Interface().(*Position)
+	if !position.IsSet() {
+		*position = newPosition(d.fileName, node)
+	}
+}
+
+// positionField finds the Position field of structV, searching embedded structs as well
+func positionField(structV reflect.Value) (field reflect.Value, ok bool) {
+	structT := structV.Type()
+	for i := 0; i < structT.NumField(); i++ {
+		fieldT := structT.Field(i)
+		if fieldT.Type == positionType {
+			return structV.Field(i), true
+		}
+		if fieldT.Anonymous && fieldT.Type.Kind() == reflect.Struct {
+			field, ok = positionField(structV.Field(i))
+			if ok {
+				return
+			}
+		}
+	}
+	return
+}
+
+// fieldWithYamlName finds the field decoded from the yaml key name, searching embedded structs as well
+func fieldWithYamlName(structV reflect.Value, name string) (field reflect.Value, ok bool) {
+	structT := structV.Type()
+	for i := 0; i < structT.NumField(); i++ {
+		fieldT := structT.Field(i)
+		yamlName := strings.Split(fieldT.Tag.Get("yaml"), ",")[0]
+		if yamlName == "-" {
+			continue
+		}
+		if fieldT.Anonymous && yamlName == "" && fieldT.Type.Kind() == reflect.Struct {
+			field, ok = fieldWithYamlName(structV.Field(i), name)
+			if ok {
+				return
+			}
+			continue
+		}
+		if yamlName == name {
+			return structV.Field(i), true
+		}
+	}
+	return
+}
+
+func scalarText(node ast.Node) string {
+	if s, ok := node.(*ast.StringNode); ok {
+		return s.Value
+	}
+	return node.GetToken().Value
+}
+
+// describe explains what the yaml needs to contain to be decoded into v
+func (d *decoder) describe(v reflect.Value) string {
+	if d.registry.IsSupported(v.Addr().Interface()) {
+		return "a value"
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "a mapping"
+	case reflect.Slice:
+		return "a list"
+	default:
+		return "a value"
+	}
+}
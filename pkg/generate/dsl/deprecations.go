@@ -0,0 +1,78 @@
+package dsl
+
+import (
+	"fmt"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"strings"
+)
+
+const (
+	DeprecatedCommand = "command"
+	DeprecatedFlag    = "flag"
+	DeprecatedEnv     = "environment variable"
+)
+
+// Deprecation is a deprecated command, flag or environment variable that was used
+type Deprecation struct {
+	// Kind is DeprecatedCommand, DeprecatedFlag or DeprecatedEnv
+	Kind string
+	// Name is the command, flag or environment variable as it was used, e.g. "rm", "--host" or "HOST"
+	Name string
+	// Message is the Deprecated value of the command or option, e.g. "use remove instead"
+	Message string
+}
+
+func (d Deprecation) String() string {
+	return fmt.Sprintf(`%s "%s" is deprecated: %s`, d.Kind, d.Name, d.Message)
+}
+
+// Deprecations lists the deprecated commands and flags used in groups, the command line split by parse.SplitGNU with
+// TakesValue, followed by the deprecated environment variables that are set for the command that runs. Negated flags,
+// such as --no-force, are deprecated along with their option. getEnv returns the value of an environment variable, or
+// blank if it is not set
+func (d *Document) Deprecations(groups []flag_unmarshaler.Group, getEnv func(name string) string) (out []Deprecation) {
+	path := make([]string, 0, len(groups))
+	commands := d.Commands
+	for i, group := range groups {
+		if i != 0 {
+			// once a group is not a command, the rest are arguments of the last command
+			if name, command, ok := commands.Find(group.CommandName); ok {
+				path = append(path, name)
+				commands = command.Commands
+				if !isBlank(command.Deprecated) {
+					out = append(out, Deprecation{Kind: DeprecatedCommand, Name: group.CommandName, Message: command.Deprecated})
+				}
+			} else {
+				commands = nil
+			}
+		}
+		accepted, _ := d.AcceptedOptions(path)
+		for _, flag := range group.Flags {
+			for _, option := range accepted {
+				if !isBlank(option.Deprecated) && hasFlagNamed(option, flag.Key) {
+					out = append(out, Deprecation{Kind: DeprecatedFlag, Name: flag.Key, Message: option.Deprecated})
+				}
+			}
+		}
+	}
+	accepted, _ := d.AcceptedOptions(path)
+	for _, option := range accepted {
+		if !isBlank(option.Deprecated) && !isBlank(option.Env.Name) && getEnv(option.Env.Name) != "" {
+			out = append(out, Deprecation{Kind: DeprecatedEnv, Name: option.Env.Name, Message: option.Deprecated})
+		}
+	}
+	return
+}
+
+// hasFlagNamed is true when flagName is one of the flags of option, or the negation of one of its long flags
+func hasFlagNamed(option Option, flagName string) bool {
+	for _, name := range option.Flag.Names() {
+		if name == flagName {
+			return true
+		}
+		if strings.HasPrefix(name, longFlagPrefix) && flagName == negatedFlagPrefix+name[len(longFlagPrefix):] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package dsl
+
+import (
+	"github.com/stretchr/testify/assert"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"testing"
+)
+
+func TestDocument_Deprecations(t *testing.T) {
+	document := Document{
+		Options: []OptionOrReference{
+			{
+				Option: Option{
+					Name:       "host",
+					Type:       "string",
+					Flag:       FlagDef{Name: "host", Aliases: []string{"H"}},
+					Env:        EnvDef{Name: "HOST"},
+					Deprecated: "use --address instead",
+				},
+			},
+		},
+		Commands: NamedCommands{
+			"remove": Command{
+				Aliases:    []string{"rm"},
+				Deprecated: "use delete instead",
+				Options: []OptionOrReference{
+					{
+						Option: Option{
+							Name:       "force",
+							Type:       "bool",
+							Flag:       FlagDef{Name: "force"},
+							Deprecated: "it is always forced",
+						},
+					},
+				},
+			},
+		},
+	}
+	env := map[string]string{
+		"HOST": "example.com",
+	}
+	getEnv := func(name string) string {
+		return env[name]
+	}
+
+	cases := map[string]struct {
+		args     []string
+		getEnv   func(string) string
+		expected []Deprecation
+	}{
+		"nothing deprecated used": {
+			args: []string{"delete"},
+		},
+		"command by alias with flags": {
+			args: []string{"-H", "rm", "--force", "thing"},
+			expected: []Deprecation{
+				{Kind: DeprecatedFlag, Name: "-H", Message: "use --address instead"},
+				{Kind: DeprecatedCommand, Name: "rm", Message: "use delete instead"},
+				{Kind: DeprecatedFlag, Name: "--force", Message: "it is always forced"},
+			},
+		},
+		"negated flag": {
+			args: []string{"remove", "--no-force"},
+			expected: []Deprecation{
+				{Kind: DeprecatedCommand, Name: "remove", Message: "use delete instead"},
+				{Kind: DeprecatedFlag, Name: "--no-force", Message: "it is always forced"},
+			},
+		},
+		"flag of another command": {
+			args: []string{"--force"},
+		},
+		"environment variable": {
+			args:   []string{},
+			getEnv: getEnv,
+			expected: []Deprecation{
+				{Kind: DeprecatedEnv, Name: "HOST", Message: "use --address instead"},
+			},
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			if c.getEnv == nil {
+				c.getEnv = func(string) string {
+					return ""
+				}
+			}
+			actual := document.Deprecations(flag_unmarshaler.Split(c.args), c.getEnv)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestDocument_TakesValue(t *testing.T) {
+	document := Document{
+		Options: []OptionOrReference{
+			{Option: Option{Name: "host", Type: "string", Flag: FlagDef{Name: "host", Aliases: []string{"H"}}}},
+			{Option: Option{Name: "verbose", Type: TypeCount, Flag: FlagDef{Name: "verbose", Aliases: []string{"v"}}}},
+		},
+		Commands: NamedCommands{
+			"remove": Command{
+				Aliases: []string{"rm"},
+				Options: []OptionOrReference{
+					{Option: Option{Name: "force", Type: "bool", Flag: FlagDef{Name: "force"}}},
+					{Option: Option{Name: "reason", Type: "string", Flag: FlagDef{Name: "reason"}}},
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		args     []string
+		flag     string
+		expected bool
+	}{
+		"value of the root": {
+			flag:     "-H",
+			expected: true,
+		},
+		"count": {
+			flag: "--verbose",
+		},
+		"switch of a command by alias": {
+			args: []string{"rm"},
+			flag: "--force",
+		},
+		"value of a command": {
+			args:     []string{"remove"},
+			flag:     "--reason",
+			expected: true,
+		},
+		"inherited value": {
+			args:     []string{"remove"},
+			flag:     "--host",
+			expected: true,
+		},
+		"negated": {
+			flag: "--no-host",
+		},
+		"value of another command": {
+			flag: "--reason",
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			assert.Equal(t, c.expected, document.TakesValue(flag_unmarshaler.Split(c.args), c.flag))
+		})
+	}
+}
+
+func TestDeprecation_String(t *testing.T) {
+	assert.Equal(t, `flag "--host" is deprecated: use --address instead`, Deprecation{
+		Kind:    DeprecatedFlag,
+		Name:    "--host",
+		Message: "use --address instead",
+	}.String())
+}
@@ -9,6 +9,14 @@ type Document struct {
 	Components Components          `yaml:"components"`
 	MinArgs    uint                `yaml:"minArgs"`
 	MaxArgs    uint                `yaml:"maxArgs"`
+	// EnvPrefix, e.g. MYAPP_, gives every option without an environment variable of its own one named after the
+	// prefix, the path of the command declaring it and its name: MYAPP_SERVER_CONNECT_TIMEOUT
+	EnvPrefix string `yaml:"envPrefix"`
+	// PrintConfig gives the root options, and so every command, --print-config, see parse.ConfigDump and
+	// cli.PrintConfig
+	PrintConfig bool `yaml:"printConfig"`
+	// Position is where the document starts, it is set by Parse
+	Position Position `yaml:"-"`
 }
 
 var DocumentValidations = DocumentValidationDefs{}
@@ -17,9 +25,26 @@ type DocumentValidationDefs struct {
 }
 
 func (d DocumentValidationDefs) Validate(on *Document, emitter bad.MemberEmitter) {
-	validateMinMaxArgs(on.MinArgs, on.MaxArgs, emitter)
-	validateMaxArgsWithSubCommands(on.MaxArgs, on.Commands, emitter)
+	validateMinMaxArgs(on.MinArgs, on.MaxArgs, on.Position, emitter)
+	validateMaxArgsWithSubCommands(on.MaxArgs, on.Commands, on.Position, emitter)
+	validateEnvPrefix(on.EnvPrefix, on.Position, emitter)
+	for _, option := range on.Options {
+		optionValidations.Validate(&option.Option, emitter)
+	}
+	for optionName, option := range on.Components.Options {
+		optionValidations.Validate(&option.Option, emitter.Into("components").Into("options").Into(optionName))
+	}
+	for groupName, group := range on.Components.OptionGroups {
+		for _, option := range group {
+			optionValidations.Validate(&option.Option, emitter.Into("components").Into("optionGroups").Into(groupName))
+		}
+	}
+	validateCommandAliases(on.Commands, emitter)
 	for commandName, command := range on.Commands {
 		commandValidations.Validate(&command, emitter.Into(commandName))
 	}
+	validateCommandAliases(on.Components.Commands, emitter.Into("components").Into("commands"))
+	for commandName, command := range on.Components.Commands {
+		commandValidations.Validate(&command, emitter.Into("components").Into("commands").Into(commandName))
+	}
 }
@@ -0,0 +1,97 @@
+package dsl
+
+import (
+	"encoding/json"
+	"github.com/wojnosystems/go-optional/v2"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const documentSchemaId = "https://github.com/wojnosystems/flick/optionapi.schema.json"
+
+// WriteDocumentSchema writes the JSON Schema of the optionapi DSL. The schema is derived from Document so that it
+// always accepts exactly what Parse accepts. The published copy is optionapi.schema.json in the root of this repository
+func WriteDocumentSchema(w io.Writer) error {
+	definitions := make(map[string]interface{})
+	root := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         documentSchemaId,
+		"title":       "optionapi",
+		"definitions": definitions,
+	}
+	// the document itself is the root of the schema, not a reference, as keywords next to "$ref" are ignored
+	typeSchema(reflect.TypeOf(Document{}), definitions)
+	for key, value := range definitions["Document"].(map[string]interface{}) {
+		root[key] = value
+	}
+	delete(definitions, "Document")
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(root)
+}
+
+var optionalTesterType = reflect.TypeOf((*optional.Tester)(nil)).Elem()
+
+// typeSchema is the schema for values decoded into t. Structs are added to definitions and referenced by name
+func typeSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	if t.Implements(optionalTesterType) || t.Kind() == reflect.String {
+		// the decoder accepts any scalar for strings: version: 1, default: false
+		return map[string]interface{}{
+			"type": []string{"string", "number", "boolean"},
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{
+			"type": "boolean",
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{
+			"type":    "integer",
+			"minimum": 0,
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), definitions),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": typeSchema(t.Elem(), definitions),
+		}
+	case reflect.Struct:
+		if _, ok := definitions[t.Name()]; !ok {
+			properties := make(map[string]interface{})
+			// reserve the name before descending to support recursive types, such as Command
+			definitions[t.Name()] = nil
+			addStructProperties(t, properties, definitions)
+			definitions[t.Name()] = map[string]interface{}{
+				"type":                 []string{"object", "null"},
+				"properties":           properties,
+				"additionalProperties": false,
+			}
+		}
+		return map[string]interface{}{
+			"$ref": "#/definitions/" + t.Name(),
+		}
+	}
+	return map[string]interface{}{}
+}
+
+func addStructProperties(t reflect.Type, properties map[string]interface{}, definitions map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		yamlName := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if yamlName == "-" {
+			continue
+		}
+		if field.Anonymous && yamlName == "" && field.Type.Kind() == reflect.Struct {
+			addStructProperties(field.Type, properties, definitions)
+			continue
+		}
+		properties[yamlName] = typeSchema(field.Type, definitions)
+	}
+}
@@ -0,0 +1,17 @@
+package dsl
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWriteDocumentSchema_MatchesPublished(t *testing.T) {
+	published, err := ioutil.ReadFile("../../../optionapi.schema.json")
+	require.NoError(t, err)
+	actual := bytes.Buffer{}
+	require.NoError(t, WriteDocumentSchema(&actual))
+	assert.Equal(t, string(published), actual.String(), "regenerate optionapi.schema.json with: flick schema > optionapi.schema.json")
+}
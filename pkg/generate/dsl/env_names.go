@@ -0,0 +1,101 @@
+package dsl
+
+import (
+	"github.com/wojnosystems/okey-dokey/bad"
+	"strings"
+	"unicode"
+)
+
+const envNameSeparator = "_"
+
+// EnvName joins prefix and words, each converted to upper snake case, into an environment variable name:
+// EnvName("MYAPP_", "server", "connectTimeout") is MYAPP_SERVER_CONNECT_TIMEOUT
+func EnvName(prefix string, words ...string) string {
+	parts := make([]string, 0, len(words))
+	for _, word := range words {
+		if snake := upperSnake(word); !isBlank(snake) {
+			parts = append(parts, snake)
+		}
+	}
+	return prefix + strings.Join(parts, envNameSeparator)
+}
+
+// upperSnake converts camelCase, UpperCamelCase, dashed or snake_case names to UPPER_SNAKE_CASE.
+// Runs of capitals are kept together: HTTPPort is HTTP_PORT
+func upperSnake(name string) string {
+	runes := []rune(name)
+	out := strings.Builder{}
+	pendingSeparator := false
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingSeparator = out.Len() != 0
+			continue
+		}
+		if unicode.IsUpper(r) && i != 0 {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				pendingSeparator = out.Len() != 0
+			}
+		}
+		if pendingSeparator {
+			out.WriteString(envNameSeparator)
+			pendingSeparator = false
+		}
+		out.WriteRune(unicode.ToUpper(r))
+	}
+	return out.String()
+}
+
+// derivedEnvName is the environment variable of option, declared by the command at path, once the document's
+// envPrefix applies. Options that name their own environment variable keep it, unnamed options get none
+func derivedEnvName(envPrefix string, path []string, option Option) string {
+	if isBlank(envPrefix) || !isBlank(option.Env.Name) || isBlank(option.Name) {
+		return option.Env.Name
+	}
+	return EnvName(envPrefix, append(append(make([]string, 0, len(path)+1), path...), option.Name)...)
+}
+
+// applyEnvPrefix names the environment variable of every option, of the document and its commands, that does not
+// name its own. References must already be replaced, so that options used by several commands get a name for each
+func applyEnvPrefix(doc *Document) {
+	if isBlank(doc.EnvPrefix) {
+		return
+	}
+	doc.Options = withDerivedEnvNames(doc.EnvPrefix, nil, doc.Options)
+	applyEnvPrefixRecursive(doc.EnvPrefix, nil, doc.Commands)
+}
+
+func applyEnvPrefixRecursive(envPrefix string, parentPath []string, commands NamedCommands) {
+	for _, name := range commands.names() {
+		command := commands[name]
+		path := append(append(make([]string, 0, len(parentPath)+1), parentPath...), name)
+		command.Options = withDerivedEnvNames(envPrefix, path, command.Options)
+		applyEnvPrefixRecursive(envPrefix, path, command.Commands)
+		commands[name] = command
+	}
+}
+
+// withDerivedEnvNames copies options, as they may be shared with components, naming their environment variables
+func withDerivedEnvNames(envPrefix string, path []string, options []OptionOrReference) (out []OptionOrReference) {
+	if options == nil {
+		return
+	}
+	out = make([]OptionOrReference, len(options))
+	for i, option := range options {
+		option.Option.Env.Name = derivedEnvName(envPrefix, path, option.Option)
+		out[i] = option
+	}
+	return
+}
+
+// validateEnvPrefix checks that envPrefix is something that environment variable names can begin with
+func validateEnvPrefix(envPrefix string, position Position, emitter bad.Emitter) {
+	for i, r := range envPrefix {
+		if r == '_' || (r <= unicode.MaxASCII && unicode.IsLetter(r)) || (i != 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		emitter.Emit(atPosition("envPrefix may only contain letters, digits and underscores, and may not begin with a digit", position))
+		return
+	}
+}
@@ -1,15 +1,76 @@
 package dsl
 
+import (
+	"fmt"
+	"strings"
+)
+
 type ErrReference struct {
 	refValue string
+	Position Position
+	// Chain lists the references that were followed to reach the undefined one, qualified by the file containing them
+	Chain []string
 }
 
-func newErrReference(refValue string) *ErrReference {
+func newErrReference(refValue string, position Position, chain []string) *ErrReference {
 	return &ErrReference{
 		refValue: refValue,
+		Position: position,
+		Chain:    chain,
 	}
 }
 
 func (e *ErrReference) Error() string {
-	return "undefined reference: '" + e.refValue + "'"
+	message := "undefined reference: '" + e.refValue + "'"
+	if len(e.Chain) > 1 {
+		message += " (reference chain: " + strings.Join(e.Chain, " -> ") + ")"
+	}
+	return atPosition(message, e.Position)
+}
+
+// ErrReferenceCycle is returned when following references leads back to a reference that was already followed
+type ErrReferenceCycle struct {
+	// Chain lists the references that were followed, qualified by the file containing them. The last is the repeat
+	Chain    []string
+	Position Position
+}
+
+func (e *ErrReferenceCycle) Error() string {
+	return atPosition("reference cycle: "+strings.Join(e.Chain, " -> "), e.Position)
+}
+
+// ErrUnknownField is returned when the optionapi document contains a key that is not part of the DSL
+type ErrUnknownField struct {
+	Field    string
+	Position Position
+}
+
+func (e *ErrUnknownField) Error() string {
+	return atPosition(fmt.Sprintf(`unknown field "%s"`, e.Field), e.Position)
+}
+
+// ErrInvalidValue is returned when a value in the optionapi document cannot be decoded into the DSL or
+// cannot be generated
+type ErrInvalidValue struct {
+	Reason   string
+	Position Position
+}
+
+func (e *ErrInvalidValue) Error() string {
+	if !e.Position.IsSet() {
+		return "invalid value: " + e.Reason
+	}
+	return fmt.Sprintf(`invalid value at %s: %s`, e.Position, e.Reason)
+}
+
+// ErrUnsupportedVersion is returned when the optionapi document declares a major version that flick cannot read,
+// usually because it was written for a newer flick
+type ErrUnsupportedVersion struct {
+	Version  string
+	Position Position
+}
+
+func (e *ErrUnsupportedVersion) Error() string {
+	return atPosition(fmt.Sprintf(`unsupported optionapi version "%s"`, e.Version), e.Position) +
+		", the newest version this flick supports is " + CurrentVersion
 }
@@ -0,0 +1,7 @@
+package dsl
+
+// FileDef names the key used to read an option from the configuration file.
+// The file is flat: the root and every command read their options from the top level keys of the same file
+type FileDef struct {
+	Name string `yaml:"name"`
+}
@@ -1,6 +1,31 @@
 package dsl
 
+const (
+	longFlagPrefix = "--"
+	// negatedFlagPrefix turns off a switch, --no-verbose is the negation of --verbose
+	negatedFlagPrefix = "--no-"
+)
+
 type FlagDef struct {
 	Name    string   `yaml:"name"`
 	Aliases []string `yaml:"aliases"`
 }
+
+// Names is the flag and its aliases as they would be typed on the command line: --connectTimeout, -c
+func (f FlagDef) Names() (out []string) {
+	if isBlank(f.Name) {
+		return
+	}
+	out = append(out, flagNameWithDashes(f.Name))
+	for _, alias := range f.Aliases {
+		out = append(out, flagNameWithDashes(alias))
+	}
+	return
+}
+
+func flagNameWithDashes(name string) string {
+	if len([]rune(name)) == 1 {
+		return "-" + name
+	}
+	return longFlagPrefix + name
+}
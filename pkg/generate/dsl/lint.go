@@ -0,0 +1,140 @@
+package dsl
+
+import (
+	"fmt"
+	"github.com/wojnosystems/okey-dokey/bad"
+	"io"
+	"os"
+)
+
+// Lint parses the document just like Parse, but also runs LintValidations, which catch mistakes that are
+// allowed by the DSL, but are likely unintended. err is ErrValidation if either found a problem
+func Lint(r io.Reader, emitter bad.MemberEmitter) (out Document, err error) {
+	return lintNamed(r, "", emitter)
+}
+
+// LintFile is Lint, reading the document from fileName. The file name is included in every Position
+func LintFile(fileName string, emitter bad.MemberEmitter) (out Document, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return lintNamed(file, fileName, emitter)
+}
+
+func lintNamed(r io.Reader, fileName string, emitter bad.MemberEmitter) (out Document, err error) {
+	out, ignored, err := decodeDocument(r, fileName)
+	if err != nil {
+		return
+	}
+
+	tracked := newTrackedEmitter(emitter)
+	validateVersionIsCurrent(out.OptionApi, ignored, tracked)
+	resolver := newReferenceResolver(&out, fileName)
+	DocumentValidations.Validate(&out, tracked)
+	validateOptionCollisions(&out, resolver, fileName, tracked)
+	// references must be checked before they are replaced
+	validateComponentsAreReferenced(&out, fileName, tracked.Into("components"))
+
+	err = replaceDocumentReferences(&out, resolver, fileName)
+	if err != nil {
+		return
+	}
+	if !tracked.isInvalid() {
+		validateResolved(&out, resolver, fileName, tracked)
+	}
+
+	LintValidations.Validate(&out, tracked)
+	if tracked.isInvalid() {
+		err = ErrValidation
+	}
+	return
+}
+
+// validateVersionIsCurrent reports documents written in an older major version, along with the fields that are not
+// part of the DSL, which the older version ignored
+func validateVersionIsCurrent(api OptionApi, ignored []*ErrUnknownField, emitter bad.Emitter) {
+	major, _ := api.majorVersion()
+	if major == currentMajorVersion {
+		return
+	}
+	emitter.Emit(atPosition(fmt.Sprintf(`optionapi version %s is out of date, run "flick migrate" to upgrade it to version %s`, api.version(), CurrentVersion), api.Position))
+	validateNoUnknownFields(ignored, emitter)
+}
+
+var LintValidations = LintValidationDefs{}
+
+type LintValidationDefs struct {
+}
+
+func (d LintValidationDefs) Validate(on *Document, emitter bad.MemberEmitter) {
+	_ = on.Commands.Walk(func(path []string, command Command) error {
+		commandEmitter := intoPath(emitter, path)
+		if !command.Description.IsSet() {
+			commandEmitter.Emit(atPosition("command has no description", command.Position))
+		}
+		return nil
+	})
+}
+
+// validateComponentsAreReferenced checks that every component is used by a reference within the document, read from
+// fileName. References that name fileName itself, such as "cli.yaml#/components/options/Name", count as well
+func validateComponentsAreReferenced(on *Document, fileName string, emitter bad.MemberEmitter) {
+	referenced := make(map[string]bool)
+	addReference := func(reference string) {
+		referencedFile, kind, name, ok := splitReference(fileName, reference)
+		if ok && referencedFile == cleanFileName(fileName) {
+			referenced[componentsPrefix+kind+"/"+name] = true
+		}
+	}
+	addReferences := func(options []OptionOrReference) {
+		for _, option := range options {
+			addReference(option.Reference)
+		}
+	}
+	addCommandReferences := func(_ []string, command Command) error {
+		addReference(command.Reference)
+		addReferences(command.Options)
+		return nil
+	}
+	addReferences(on.Options)
+	_ = on.Commands.Walk(addCommandReferences)
+	_ = on.Components.Commands.Walk(addCommandReferences)
+	for _, option := range on.Components.Options {
+		addReference(option.Reference)
+	}
+	for _, group := range on.Components.OptionGroups {
+		addReferences(group)
+	}
+
+	for _, name := range on.Components.Options.names() {
+		if !referenced[componentOptionsPrefix+name] {
+			emitter.Into(componentKindOptions).Into(name).Emit(atPosition("component is never referenced", on.Components.Options[name].Position))
+		}
+	}
+	for _, name := range on.Components.Commands.names() {
+		if !referenced[componentCommandsPrefix+name] {
+			emitter.Into(componentKindCommands).Into(name).Emit(atPosition("component is never referenced", on.Components.Commands[name].Position))
+		}
+	}
+	for _, name := range on.Components.OptionGroups.names() {
+		if !referenced[componentOptionGroupsPrefix+name] {
+			// groups are lists, which have no position of their own
+			var position Position
+			if group := on.Components.OptionGroups[name]; len(group) != 0 {
+				position = group[0].Position
+			}
+			emitter.Into(componentKindOptionGroups).Into(name).Emit(atPosition("component is never referenced", position))
+		}
+	}
+}
+
+func intoPath(emitter bad.MemberEmitter, path []string) bad.MemberEmitter {
+	for _, name := range path {
+		emitter = emitter.Into(name)
+	}
+	return emitter
+}
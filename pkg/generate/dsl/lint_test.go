@@ -0,0 +1,171 @@
+package dsl
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/okey-dokey/bad"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	cases := map[string]struct {
+		input       string
+		expected    bad.ReceiveCollector
+		expectedErr error
+	}{
+		"clean": {
+			input: `---
+commands:
+  server:
+    description: "runs the server"
+    options:
+      - $ref: "#/components/options/Verbose"
+components:
+  options:
+    Verbose:
+      type: bool
+      flag:
+        name: verbose
+`,
+			expected: bad.NewCollection(),
+		},
+		"command without description": {
+			input: `---
+commands:
+  server:
+    commands:
+      start:
+        description: "starts the server"
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("server").Emit("command has no description at 3:3")
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"unused component": {
+			input: `---
+components:
+  options:
+    Verbose:
+      type: bool
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("components").Into("options").Into("Verbose").Emit("component is never referenced at 4:5")
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"unused command and option group": {
+			input: `---
+components:
+  commands:
+    Server:
+      description: "runs the server"
+  optionGroups:
+    Tls:
+      - name: cert
+        type: string
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("components").Into("commands").Into("Server").Emit("component is never referenced at 4:5")
+				c.Into("components").Into("optionGroups").Into("Tls").Emit("component is never referenced at 8:9")
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"duplicate flag and env names": {
+			input: `---
+options:
+  - name: verbose
+    type: bool
+    flag:
+      name: verbose
+      aliases: ["v"]
+    env:
+      name: VERBOSE
+  - name: version
+    type: bool
+    flag:
+      name: version
+      aliases: ["v"]
+commands:
+  server:
+    description: "runs the server"
+    options:
+      - name: verbose
+        type: bool
+        env:
+          name: VERBOSE
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Emit(`flag "-v" of options[1] at 10:5 is already declared by options[0] at 3:5`)
+				c.Into("server").Emit(`environment variable "VERBOSE" of commands.server.options[0] at 19:9 is already declared by options[0] at 3:5`)
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"out of date version": {
+			input: `---
+optionapi:
+  version: 1
+commands:
+  server:
+    description: "runs the server"
+    minArg: 1
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Emit(`optionapi version 1 is out of date, run "flick migrate" to upgrade it to version 2 at 3:3`)
+				c.Emit(`unknown field "minArg", run "flick migrate" to remove it at 7:5`)
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			input := bytes.NewReader([]byte(c.input))
+			validationErrors := bad.NewCollection()
+			_, err := Lint(input, validationErrors)
+			assertEqualNilSafeError(t, err, c.expectedErr)
+			assert.Equal(t, c.expected, validationErrors)
+		})
+	}
+}
+
+func TestLintFile_ReferencesNamingTheSameFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "optionapi")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	fileName := filepath.Join(dir, "cli.yaml")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(`---
+commands:
+  server:
+    description: "runs the server"
+    options:
+      - $ref: "cli.yaml#/components/options/Verbose"
+components:
+  options:
+    Verbose:
+      type: bool
+      flag:
+        name: verbose
+`), 0600))
+
+	validationErrors := bad.NewCollection()
+	_, err = LintFile(fileName, validationErrors)
+	require.NoError(t, err)
+	assert.Equal(t, bad.NewCollection(), validationErrors)
+}
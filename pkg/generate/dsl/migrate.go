@@ -0,0 +1,292 @@
+package dsl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migration upgrades documents written in one major version to the next
+type migration struct {
+	from int
+	// upgrade makes the changes needed by the next version, other than changing the version itself
+	upgrade func(m *migrating) error
+}
+
+var migrations = []migration{
+	{
+		// version 1 ignored keys that are not part of the DSL, version 2 rejects them
+		from: 1,
+		upgrade: func(m *migrating) (err error) {
+			for _, field := range m.ignored {
+				err = m.removeField(field)
+				if err != nil {
+					return
+				}
+			}
+			return
+		},
+	},
+}
+
+// Migrate upgrades the optionapi document read from r to CurrentVersion and writes it to w. The text of the document
+// is edited, rather than the document being written out again, so that comments and formatting are kept. changes
+// describes every edit made, documents that are already current are written unchanged. fileName is used in positions
+// and may be blank
+func Migrate(r io.Reader, w io.Writer, fileName string) (changes []string, err error) {
+	source, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	for {
+		var doc Document
+		var ignored []*ErrUnknownField
+		doc, ignored, err = decodeDocument(bytes.NewReader(source), fileName)
+		if err != nil {
+			return
+		}
+		major, _ := doc.OptionApi.majorVersion()
+		if major == currentMajorVersion && doc.OptionApi.Version.IsSet() {
+			break
+		}
+		var m *migrating
+		m, err = newMigrating(source, fileName, ignored)
+		if err != nil {
+			return
+		}
+		version := CurrentVersion
+		if major != currentMajorVersion {
+			version = strconv.Itoa(major + 1)
+			err = migrationFrom(major).upgrade(m)
+			if err != nil {
+				return
+			}
+		}
+		err = m.setVersion(doc.OptionApi, version)
+		if err != nil {
+			return
+		}
+		source = m.apply()
+		changes = append(changes, m.changes...)
+	}
+	_, err = w.Write(source)
+	return
+}
+
+// MigrateFile is Migrate, upgrading fileName in place. The file is only written when something changed
+func MigrateFile(fileName string) (changes []string, err error) {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return
+	}
+	source, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	migrated := bytes.Buffer{}
+	changes, err = Migrate(bytes.NewReader(source), &migrated, fileName)
+	if err != nil || len(changes) == 0 {
+		return
+	}
+	err = ioutil.WriteFile(fileName, migrated.Bytes(), info.Mode())
+	return
+}
+
+func migrationFrom(major int) migration {
+	for _, m := range migrations {
+		if m.from == major {
+			return m
+		}
+	}
+	panic(fmt.Sprintf("no migration from optionapi version %d", major))
+}
+
+// migrating is a document being upgraded by one version
+type migrating struct {
+	fileName string
+	lines    []string
+	// root is the top-level node of the document, nil when the document is empty
+	root ast.Node
+	// ignored are the fields that were ignored when the document was decoded
+	ignored []*ErrUnknownField
+	edits   []lineEdit
+	changes []string
+}
+
+// lineEdit replaces remove lines, starting at line, with insert. Lines are numbered from 1
+type lineEdit struct {
+	line   int
+	remove int
+	insert []string
+}
+
+func newMigrating(source []byte, fileName string, ignored []*ErrUnknownField) (m *migrating, err error) {
+	tree, err := parser.ParseBytes(source, 0)
+	if err != nil {
+		return
+	}
+	m = &migrating{
+		fileName: fileName,
+		lines:    strings.Split(string(source), "\n"),
+		ignored:  ignored,
+	}
+	if len(tree.Docs) != 0 {
+		m.root = tree.Docs[0].Body
+	}
+	return
+}
+
+// removeField removes the lines declaring field, along with its value
+func (m *migrating) removeField(field *ErrUnknownField) (err error) {
+	line, indent := field.Position.Line, field.Position.Column-1
+	if !m.startsBlockLine(line, indent) {
+		return m.errEditByHand(fmt.Sprintf(`remove unknown field "%s"`, field.Field), field.Position)
+	}
+	last := line
+	for i := line + 1; i <= len(m.lines); i++ {
+		text := strings.TrimSpace(m.lines[i-1])
+		if text == "" {
+			continue
+		}
+		lineIndent := len(m.lines[i-1]) - len(strings.TrimLeft(m.lines[i-1], " "))
+		if strings.HasPrefix(text, "#") {
+			// comments are only removed when they are indented within the field
+			if lineIndent > indent {
+				last = i
+			}
+			continue
+		}
+		// lists may be indented as much as the key they belong to
+		if lineIndent < indent || (lineIndent == indent && !strings.HasPrefix(text, "-")) {
+			break
+		}
+		last = i
+	}
+	m.edits = append(m.edits, lineEdit{
+		line:   line,
+		remove: last - line + 1,
+	})
+	m.changes = append(m.changes, atPosition(fmt.Sprintf(`removed unknown field "%s"`, field.Field), field.Position))
+	return
+}
+
+// setVersion sets optionapi.version to version, adding it, and optionapi, when they are missing
+func (m *migrating) setVersion(api OptionApi, version string) (err error) {
+	if api.Version.IsSet() {
+		m.changes = append(m.changes, fmt.Sprintf("upgraded optionapi version %s to %s", api.version(), version))
+	} else {
+		m.changes = append(m.changes, "set optionapi version to "+version)
+	}
+	apiNode := findMappingValue(m.root, "optionapi")
+	if apiNode == nil {
+		line, indent := len(m.lines), 0
+		if values := mappingValues(m.root); len(values) != 0 {
+			position := newPosition(m.fileName, values[0].Key)
+			line, indent = position.Line, position.Column-1
+		} else if m.lines[len(m.lines)-1] != "" {
+			line++
+		}
+		prefix := strings.Repeat(" ", indent)
+		m.edits = append(m.edits, lineEdit{
+			line:   line,
+			insert: []string{prefix + "optionapi:", prefix + "  version: " + version},
+		})
+		return
+	}
+	apiPosition := newPosition(m.fileName, apiNode.Key)
+	versionNode := findMappingValue(apiNode.Value, "version")
+	if versionNode == nil {
+		line, indent := apiPosition.Line+1, apiPosition.Column+1
+		if values := mappingValues(apiNode.Value); len(values) != 0 {
+			position := newPosition(m.fileName, values[0].Key)
+			line, indent = position.Line, position.Column-1
+		} else if _, isNull := apiNode.Value.(*ast.NullNode); apiNode.Value != nil && !isNull {
+			return m.errEditByHand("add the optionapi version", apiPosition)
+		}
+		m.edits = append(m.edits, lineEdit{
+			line:   line,
+			insert: []string{strings.Repeat(" ", indent) + "version: " + version},
+		})
+		return
+	}
+	position := newPosition(m.fileName, versionNode.Key)
+	if !m.startsBlockLine(position.Line, position.Column-1) || !isOnLine(versionNode.Value, position.Line) {
+		return m.errEditByHand("set the optionapi version", position)
+	}
+	text := m.lines[position.Line-1]
+	replacement := text[:position.Column-1] + "version: " + version
+	if comment := strings.Index(text, " #"); comment != -1 {
+		replacement += text[comment:]
+	}
+	m.edits = append(m.edits, lineEdit{
+		line:   position.Line,
+		remove: 1,
+		insert: []string{replacement},
+	})
+	return
+}
+
+// startsBlockLine is true when line has nothing but indent spaces before the key at column indent+1
+func (m *migrating) startsBlockLine(line, indent int) bool {
+	if line < 1 || line > len(m.lines) || len(m.lines[line-1]) < indent {
+		return false
+	}
+	return strings.TrimLeft(m.lines[line-1][:indent], " ") == ""
+}
+
+func (m *migrating) errEditByHand(what string, position Position) error {
+	return fmt.Errorf("%s, please %s by hand", atPosition("unable to migrate flow style yaml", position), what)
+}
+
+// apply makes every edit, starting from the end so that earlier line numbers stay valid. Lines that are removed are
+// removed before lines are inserted at the same place
+func (m *migrating) apply() []byte {
+	sort.SliceStable(m.edits, func(i, j int) bool {
+		if m.edits[i].line != m.edits[j].line {
+			return m.edits[i].line > m.edits[j].line
+		}
+		return m.edits[i].remove > m.edits[j].remove
+	})
+	lines := m.lines
+	for _, edit := range m.edits {
+		start := edit.line - 1
+		edited := append(append(make([]string, 0, len(lines)+len(edit.insert)), lines[:start]...), edit.insert...)
+		lines = append(edited, lines[start+edit.remove:]...)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// isOnLine is true when node is declared on line, or has no text at all, such as a missing value
+func isOnLine(node ast.Node, line int) bool {
+	if node == nil || node.GetToken() == nil {
+		return true
+	}
+	return node.GetToken().Position.Line == line
+}
+
+// mappingValues lists the keys and values of node, which is a mapping, or a single key and value
+func mappingValues(node ast.Node) []*ast.MappingValueNode {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}
+	}
+	return nil
+}
+
+func findMappingValue(node ast.Node, key string) *ast.MappingValueNode {
+	for _, value := range mappingValues(node) {
+		if scalarText(value.Key) == key {
+			return value
+		}
+	}
+	return nil
+}
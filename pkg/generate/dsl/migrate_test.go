@@ -0,0 +1,137 @@
+package dsl
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/okey-dokey/bad"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	cases := map[string]struct {
+		input           string
+		expected        string
+		expectedChanges []string
+		expectedErr     string
+	}{
+		"current version is unchanged": {
+			input: `optionapi:
+  version: 2
+`,
+			expected: `optionapi:
+  version: 2
+`,
+		},
+		"version 1 loses the fields it ignored": {
+			input: `---
+# the greeter
+optionapi:
+  version: 1 # pinned
+commands:
+  greet:
+    minArg: 1
+    descripton:
+      says hello
+      # to everyone
+
+    options:
+      - name: name
+        type: string
+        colour: blue
+        flag:
+          name: name
+    futures:
+    - one
+    - two
+`,
+			expected: `---
+# the greeter
+optionapi:
+  version: 2 # pinned
+commands:
+  greet:
+
+    options:
+      - name: name
+        type: string
+        flag:
+          name: name
+`,
+			expectedChanges: []string{
+				`removed unknown field "minArg" at 7:5`,
+				`removed unknown field "descripton" at 8:5`,
+				`removed unknown field "colour" at 15:9`,
+				`removed unknown field "futures" at 18:5`,
+				"upgraded optionapi version 1 to 2",
+			},
+		},
+		"missing optionapi": {
+			input: `---
+commands:
+  greet:
+`,
+			expected: `---
+optionapi:
+  version: 2
+commands:
+  greet:
+`,
+			expectedChanges: []string{
+				"set optionapi version to 2",
+			},
+		},
+		"missing version": {
+			input: `optionapi:
+commands:
+  greet:
+`,
+			expected: `optionapi:
+  version: 2
+commands:
+  greet:
+`,
+			expectedChanges: []string{
+				"set optionapi version to 2",
+			},
+		},
+		"empty": {
+			input: "",
+			expected: `optionapi:
+  version: 2
+`,
+			expectedChanges: []string{
+				"set optionapi version to 2",
+			},
+		},
+		"flow style": {
+			input: `optionapi: {version: 1}
+commands: {greet: {minArg: 1}}
+`,
+			expectedErr: `unable to migrate flow style yaml at 2:20, please remove unknown field "minArg" by hand`,
+		},
+		"unsupported version": {
+			input: `optionapi:
+  version: 3
+`,
+			expectedErr: `unsupported optionapi version "3" at 2:3, the newest version this flick supports is 2`,
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := bytes.Buffer{}
+			changes, err := Migrate(bytes.NewReader([]byte(c.input)), &actual, "")
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual.String())
+			assert.Equal(t, c.expectedChanges, changes)
+
+			_, err = Parse(bytes.NewReader(actual.Bytes()), bad.NewCollection())
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -1,7 +1,56 @@
 package dsl
 
+import "sort"
+
 type NamedCommands map[string]Command
 
 func (c NamedCommands) HasAny() bool {
 	return len(c) != 0
 }
+
+// Find gets the command called nameOrAlias, which is either its name or one of its aliases. name is the name the
+// command is declared with
+func (c NamedCommands) Find(nameOrAlias string) (name string, command Command, ok bool) {
+	if command, ok = c[nameOrAlias]; ok {
+		return nameOrAlias, command, true
+	}
+	for _, name = range c.names() {
+		for _, alias := range c[name].Aliases {
+			if alias == nameOrAlias {
+				return name, c[name], true
+			}
+		}
+	}
+	return "", Command{}, false
+}
+
+// Walk calls callback for every command in the tree, depth-first with siblings in name order.
+// path is the list of command names leading to, and including, the command
+func (c NamedCommands) Walk(callback func(path []string, command Command) error) error {
+	return c.walkRecursive([]string{}, callback)
+}
+
+func (c NamedCommands) walkRecursive(parentPath []string, callback func(path []string, command Command) error) (err error) {
+	for _, name := range c.names() {
+		path := append(append(make([]string, 0, len(parentPath)+1), parentPath...), name)
+		err = callback(path, c[name])
+		if err != nil {
+			return
+		}
+		err = c[name].Commands.walkRecursive(path, callback)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// names lists the command names in order
+func (c NamedCommands) names() (out []string) {
+	out = make([]string, 0, len(c))
+	for name := range c {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return
+}
@@ -0,0 +1,21 @@
+package dsl
+
+import "sort"
+
+// NamedOptionGroups are lists of options that are used together, such as the certificate, key and CA of "tls".
+// The options in a group may be references to options or to other groups
+type NamedOptionGroups map[string][]OptionOrReference
+
+func (g NamedOptionGroups) HasAny() bool {
+	return len(g) != 0
+}
+
+// names lists the names in order, so that problems are always reported in the same order
+func (o NamedOptionGroups) names() (out []string) {
+	out = make([]string, 0, len(o))
+	for name := range o {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return
+}
@@ -1,7 +1,20 @@
 package dsl
 
-type NamedOptions map[string]Option
+import "sort"
+
+// NamedOptions are reusable options. They may also be a reference to an option in another file
+type NamedOptions map[string]OptionOrReference
 
 func (o NamedOptions) HasAny() bool {
 	return len(o) != 0
 }
+
+// names lists the names in order, so that problems are always reported in the same order
+func (o NamedOptions) names() (out []string) {
+	out = make([]string, 0, len(o))
+	for name := range o {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return
+}
@@ -1,6 +1,20 @@
 package dsl
 
-import "github.com/wojnosystems/go-optional/v2"
+import (
+	"github.com/wojnosystems/go-optional/v2"
+	"github.com/wojnosystems/okey-dokey/bad"
+)
+
+const (
+	ScopePersistent = "persistent"
+	ScopeLocal      = "local"
+)
+
+// TypeCount is the type of options that count how many times their flag is given, such as -vvv for a verbosity of 3.
+// Environment variables and files give the count as a number
+const TypeCount = "count"
+
+const typeBool = "bool"
 
 type Option struct {
 	Name        string          `yaml:"name"`
@@ -9,6 +23,50 @@ type Option struct {
 	Usage       optional.String `yaml:"usage"`
 	Env         EnvDef          `yaml:"env"`
 	Flag        FlagDef         `yaml:"flag"`
+	File        FileDef         `yaml:"file"`
 	Default     optional.String `yaml:"default"`
 	Required    bool            `yaml:"required"`
+	// Enum lists every value this option accepts, leave empty to accept any value of Type
+	Enum []string `yaml:"enum"`
+	// List options may be given more than once, every value is kept, in order, rather than the last one winning
+	List bool `yaml:"list"`
+	// Secret is true for values that must not be shown, such as passwords. They are not echoed when prompted for
+	Secret bool `yaml:"secret"`
+	// FilePath is true when the value of this option is a path on the file system, shells will complete it as one
+	FilePath bool `yaml:"filePath"`
+	// Scope is ScopePersistent, the default, when the option may also be given to every sub-command of the command
+	// declaring it, or ScopeLocal when only the declaring command accepts it
+	Scope string `yaml:"scope"`
+	// Hidden options are parsed as usual, but are left out of help, documentation and completion
+	Hidden bool `yaml:"hidden"`
+	// Deprecated explains what to use instead of this option, e.g. "use --host instead". A warning is printed when
+	// the flag or environment variable of a deprecated option is used
+	Deprecated string `yaml:"deprecated"`
+	// Position is where the option was declared, it is set by Parse. Options copied from a reference keep the
+	// position of the component
+	Position Position `yaml:"-"`
+}
+
+// TakesValue is false for switches, options of type bool or TypeCount, which are given without a value
+func (o Option) TakesValue() bool {
+	return o.Type != typeBool && o.Type != TypeCount
+}
+
+// IsPersistent is true when the sub-commands of the command declaring this option also accept it
+func (o Option) IsPersistent() bool {
+	return o.Scope != ScopeLocal
+}
+
+var optionValidations = optionValidationDefs{}
+
+type optionValidationDefs struct {
+}
+
+func (d optionValidationDefs) Validate(on *Option, emitter bad.Emitter) {
+	if !isBlank(on.Scope) && on.Scope != ScopePersistent && on.Scope != ScopeLocal {
+		emitter.Emit(atPosition(`scope must be "`+ScopePersistent+`" or "`+ScopeLocal+`"`, on.Position))
+	}
+	if on.List && on.Type == TypeCount {
+		emitter.Emit(atPosition(`options of type "`+TypeCount+`" cannot be a list`, on.Position))
+	}
 }
@@ -1,7 +1,62 @@
 package dsl
 
-import "github.com/wojnosystems/go-optional/v2"
+import (
+	"github.com/wojnosystems/go-optional/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CurrentVersion is the version of the optionapi format written by flick migrate. Documents that do not declare
+	// a version are read as this version
+	CurrentVersion      = "2"
+	currentMajorVersion = 2
+
+	// lastLenientMajorVersion is the last major version that ignored keys which are not part of the DSL
+	lastLenientMajorVersion = 1
+)
 
 type OptionApi struct {
+	// Version is the version of the optionapi format the document is written in, either MAJOR or MAJOR.MINOR
 	Version optional.String `yaml:"version"`
+	// Position is where the version was declared, it is set by Parse
+	Position Position `yaml:"-"`
+}
+
+// version is the declared version, or CurrentVersion when none was declared
+func (o OptionApi) version() (out string) {
+	out = CurrentVersion
+	o.Version.IfSet(func(value string) {
+		out = value
+	})
+	return
+}
+
+// majorVersion is the major part of the version, e.g. 1 for "1.2". err is set when the version is malformed or is
+// not a version that this flick can read
+func (o OptionApi) majorVersion() (major int, err error) {
+	parts := strings.SplitN(o.version(), ".", 2)
+	major, err = strconv.Atoi(parts[0])
+	if err == nil && len(parts) == 2 {
+		_, err = strconv.Atoi(parts[1])
+	}
+	if err != nil {
+		err = &ErrInvalidValue{
+			Reason:   `optionapi version must be MAJOR or MAJOR.MINOR, such as "` + CurrentVersion + `", not "` + o.version() + `"`,
+			Position: o.Position,
+		}
+		return
+	}
+	if major < 1 || major > currentMajorVersion {
+		err = &ErrUnsupportedVersion{
+			Version:  o.version(),
+			Position: o.Position,
+		}
+	}
+	return
+}
+
+// ignoresUnknownFields is true for versions that ignore keys which are not part of the DSL, instead of rejecting them
+func ignoresUnknownFields(major int) bool {
+	return major <= lastLenientMajorVersion
 }
@@ -0,0 +1,107 @@
+package dsl
+
+import (
+	"fmt"
+	"github.com/wojnosystems/okey-dokey/bad"
+)
+
+// declaredOption is an option along with the yaml path and position where it was declared, e.g. commands.server.options[1]
+type declaredOption struct {
+	yamlPath string
+	position Position
+	option   Option
+}
+
+func (d declaredOption) String() string {
+	return atPosition(d.yamlPath, d.position)
+}
+
+// optionNames tracks which option claimed each flag name, alias, and environment variable name
+type optionNames struct {
+	flags map[string]declaredOption
+	envs  map[string]declaredOption
+}
+
+func newOptionNames() optionNames {
+	return optionNames{
+		flags: make(map[string]declaredOption),
+		envs:  make(map[string]declaredOption),
+	}
+}
+
+// persistent copies the names claimed by persistent options, which are the names that sub-commands inherit
+func (n optionNames) persistent() (out optionNames) {
+	out = newOptionNames()
+	for name, declared := range n.flags {
+		if declared.option.IsPersistent() {
+			out.flags[name] = declared
+		}
+	}
+	for name, declared := range n.envs {
+		if declared.option.IsPersistent() {
+			out.envs[name] = declared
+		}
+	}
+	return
+}
+
+// claim records the names used by declared, emitting a message for every name that was already claimed
+func (n optionNames) claim(declared declaredOption, emitter bad.Emitter) {
+	for _, name := range declared.option.Flag.Names() {
+		if previous, ok := n.flags[name]; ok {
+			emitter.Emit(fmt.Sprintf(`flag "%s" of %s is already declared by %s`, name, declared, previous))
+			continue
+		}
+		n.flags[name] = declared
+	}
+	name := declared.option.Env.Name
+	if isBlank(name) {
+		return
+	}
+	if previous, ok := n.envs[name]; ok {
+		emitter.Emit(fmt.Sprintf(`environment variable "%s" of %s is already declared by %s`, name, declared, previous))
+		return
+	}
+	n.envs[name] = declared
+}
+
+// validateOptionCollisions checks that the options visible to every command, which are the persistent options of the
+// document and of every ancestor command, along with the command's own, never share a flag name, alias, or environment variable name.
+// References are resolved here, rather than replaced first, so that collisions are reported where the option was used.
+// Environment variables derived from the envPrefix are checked along with those that are named
+func validateOptionCollisions(doc *Document, resolver *referenceResolver, fileName string, emitter bad.MemberEmitter) {
+	visible := newOptionNames()
+	claimOptions(visible, resolver, fileName, doc.EnvPrefix, nil, doc.Options, "options", emitter)
+	validateOptionCollisionsRecursive(resolver, fileName, doc.EnvPrefix, nil, doc.Commands, "commands", nil, visible.persistent(), emitter)
+}
+
+func validateOptionCollisionsRecursive(resolver *referenceResolver, fileName string, envPrefix string, parentPath []string, commands NamedCommands, yamlPath string, chain []string, parentVisible optionNames, emitter bad.MemberEmitter) {
+	for _, name := range commands.names() {
+		// unresolvable and cyclic references are reported when references are replaced
+		command, commandFileName, commandChain, err := resolver.commandComponent(fileName, commands[name], chain)
+		if err != nil {
+			continue
+		}
+		path := append(append(make([]string, 0, len(parentPath)+1), parentPath...), name)
+		commandYamlPath := yamlPath + "." + name
+		commandEmitter := emitter.Into(name)
+		visible := parentVisible.persistent()
+		claimOptions(visible, resolver, commandFileName, envPrefix, path, command.Options, commandYamlPath+".options", commandEmitter)
+		validateOptionCollisionsRecursive(resolver, commandFileName, envPrefix, path, command.Commands, commandYamlPath+".commands", commandChain, visible.persistent(), commandEmitter)
+	}
+}
+
+// claimOptions claims the names of every option in options, declared by the command at path. The options in a group
+// are all declared by the reference to the group
+func claimOptions(visible optionNames, resolver *referenceResolver, fileName string, envPrefix string, path []string, options []OptionOrReference, yamlPath string, emitter bad.Emitter) {
+	for dex, opt := range options {
+		for _, resolved := range resolver.optionsOrEmpty(fileName, opt) {
+			resolved.Option.Env.Name = derivedEnvName(envPrefix, path, resolved.Option)
+			visible.claim(declaredOption{
+				yamlPath: fmt.Sprintf("%s[%d]", yamlPath, dex),
+				position: opt.Position,
+				option:   resolved.Option,
+			}, emitter)
+		}
+	}
+}
@@ -1,62 +1,108 @@
 package dsl
 
 import (
+	"bytes"
 	"errors"
-	"github.com/wojnosystems/flick/parse"
+	"fmt"
 	optional_parse_registry "github.com/wojnosystems/go-optional-parse-registry/v2"
 	"github.com/wojnosystems/okey-dokey/bad"
 	"io"
+	"io/ioutil"
+	"os"
 )
 
 var ErrValidation = errors.New("failed to validate optionapi spec")
 
+// Parse reads an optionapi document, validates it and replaces the references to components.
+// Validation problems are sent to emitter, along with their position, and err will be ErrValidation
 func Parse(r io.Reader, emitter bad.MemberEmitter) (out Document, err error) {
-	registry := optional_parse_registry.RegisterFluent(optional_parse_registry.NewWithGoPrimitives())
+	return parseNamed(r, "", emitter)
+}
+
+// ParseFile is Parse, reading the document from fileName. The file name is included in every Position
+func ParseFile(fileName string, emitter bad.MemberEmitter) (out Document, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return parseNamed(file, fileName, emitter)
+}
 
-	yamlParser := parse.YamlWithParseRegister(registry)
-	err = yamlParser.UnmarshalFile(r, &out)
+func parseNamed(r io.Reader, fileName string, emitter bad.MemberEmitter) (out Document, err error) {
+	out, ignored, err := decodeDocument(r, fileName)
 	if err != nil {
 		return
 	}
 
 	tracked := newTrackedEmitter(emitter)
+	validateNoUnknownFields(ignored, tracked)
+	resolver := newReferenceResolver(&out, fileName)
 	DocumentValidations.Validate(&out, tracked)
+	validateOptionCollisions(&out, resolver, fileName, tracked)
 	if tracked.isInvalid() {
 		err = ErrValidation
 		return
 	}
 
 	// populate Document Refs, walk the entire tree
-	err = replaceDocumentReferences(&out)
+	err = replaceDocumentReferences(&out, resolver, fileName)
+	if err != nil {
+		return
+	}
+	validateResolved(&out, resolver, fileName, tracked)
+	if tracked.isInvalid() {
+		err = ErrValidation
+		return
+	}
+	applyEnvPrefix(&out)
 	return
 }
 
-func replaceDocumentReferences(doc *Document) (err error) {
-	refLookup := make(map[string]*Option)
-	for optionName, opt := range doc.Components.Options {
-		refLookup["#/components/options/"+optionName] = &opt
+// validateResolved validates doc again once its references are replaced. Components read from other files are only
+// validated here, as they are not part of the document until they are referenced. Only call it when the document
+// passed validation before its references were replaced, otherwise problems within the document are reported twice
+func validateResolved(doc *Document, resolver *referenceResolver, fileName string, emitter bad.MemberEmitter) {
+	DocumentValidations.Validate(doc, emitter)
+	validateOptionCollisions(doc, resolver, fileName, emitter)
+}
+
+// validateNoUnknownFields reports the keys that are not part of the DSL, which versions that ignore unknown fields
+// return from decodeDocument rather than failing on the first one
+func validateNoUnknownFields(ignored []*ErrUnknownField, emitter bad.Emitter) {
+	for _, field := range ignored {
+		emitter.Emit(atPosition(fmt.Sprintf(`unknown field "%s", run "flick migrate" to remove it`, field.Field), field.Position))
 	}
-	return replaceDocumentReferencesRecursive(doc.Commands, refLookup)
 }
 
-// replaceDocumentReferencesRecursive is inefficient, replace with stack-based one later
-func replaceDocumentReferencesRecursive(namedCommand NamedCommands, lookup map[string]*Option) (err error) {
-	for cmdName, cmd := range namedCommand {
-		for dex, opt := range cmd.Options {
-			if isBlank(opt.Reference) {
-				continue
-			}
-			if ref, ok := lookup[opt.Reference]; !ok {
-				return newErrReference(opt.Reference)
-			} else {
-				namedCommand[cmdName].Options[dex].Reference = ""
-				namedCommand[cmdName].Options[dex].Option = *ref
-			}
-		}
-		err = replaceDocumentReferencesRecursive(cmd.Commands, lookup)
-		if err != nil {
-			return
-		}
+// decodeDocument reads a document after checking that its version is supported. Versions that ignore unknown fields
+// return them in ignored, for Parse and Lint to report and Migrate to remove, other versions fail on the first one
+func decodeDocument(r io.Reader, fileName string) (out Document, ignored []*ErrUnknownField, err error) {
+	source, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
 	}
-	return
+	registry := optional_parse_registry.RegisterFluent(optional_parse_registry.NewWithGoPrimitives())
+
+	// the version decides how the rest of the document is read, so it is read on its own first
+	var versioned struct {
+		OptionApi OptionApi `yaml:"optionapi"`
+	}
+	versionDecoder := newDecoder(registry, fileName)
+	versionDecoder.strict = false
+	err = versionDecoder.Decode(bytes.NewReader(source), &versioned)
+	if err != nil {
+		return
+	}
+	major, err := versioned.OptionApi.majorVersion()
+	if err != nil {
+		return
+	}
+
+	documentDecoder := newDecoder(registry, fileName)
+	documentDecoder.strict = !ignoresUnknownFields(major)
+	err = documentDecoder.Decode(bytes.NewReader(source), &out)
+	return out, documentDecoder.unknownFields, err
 }
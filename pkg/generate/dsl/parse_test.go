@@ -2,10 +2,15 @@ package dsl
 
 import (
 	"bytes"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wojnosystems/go-optional/v2"
 	"github.com/wojnosystems/okey-dokey/bad"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -18,13 +23,41 @@ func TestParse(t *testing.T) {
 			input:    "",
 			expected: Document{},
 		},
+		"version 1": {
+			input: `
+optionapi:
+  version: 1
+commands:
+  server:
+    minArgs: 3
+    maxArgs: 3
+`,
+			expected: Document{
+				OptionApi: OptionApi{
+					Version:  optional.StringFrom("1"),
+					Position: Position{Line: 3, Column: 3},
+				},
+				Commands: NamedCommands{
+					"server": Command{
+						MinArgs:  3,
+						MaxArgs:  3,
+						Position: Position{Line: 5, Column: 3},
+					},
+				},
+				Position: Position{Line: 2, Column: 1},
+			},
+		},
 		"version": {
 			input: `
 optionapi:
-  version: 4
+  version: 2.1
 `,
 			expected: Document{
-				OptionApi: OptionApi{Version: optional.StringFrom("4")},
+				OptionApi: OptionApi{
+					Version:  optional.StringFrom("2.1"),
+					Position: Position{Line: 3, Column: 3},
+				},
+				Position: Position{Line: 2, Column: 1},
 			},
 		},
 		"command with option": {
@@ -64,31 +97,59 @@ components:
 										Name:    "connectTimeout",
 										Aliases: []string{"c"},
 									},
-									Default: optional.StringFrom("30s"),
+									Default:  optional.StringFrom("30s"),
+									Position: Position{Line: 10, Column: 5},
 								},
 							},
 						},
-						MinArgs: 2,
-						MaxArgs: 3,
+						MinArgs:  2,
+						MaxArgs:  3,
+						Position: Position{Line: 3, Column: 3},
 					},
 				},
 				Components: Components{
 					Options: NamedOptions{
 						"ConnectTimeout": {
-							Type:        "duration",
-							Description: optional.StringFrom("how long to wait when connecting to the server"),
-							Usage:       optional.StringFrom("Ns"),
-							Env: EnvDef{
-								Name: "CONNECT_TIMEOUT",
-							},
-							Flag: FlagDef{
-								Name:    "connectTimeout",
-								Aliases: []string{"c"},
+							Option: Option{
+								Type:        "duration",
+								Description: optional.StringFrom("how long to wait when connecting to the server"),
+								Usage:       optional.StringFrom("Ns"),
+								Env: EnvDef{
+									Name: "CONNECT_TIMEOUT",
+								},
+								Flag: FlagDef{
+									Name:    "connectTimeout",
+									Aliases: []string{"c"},
+								},
+								Default:  optional.StringFrom("30s"),
+								Position: Position{Line: 10, Column: 5},
 							},
-							Default: optional.StringFrom("30s"),
 						},
 					},
 				},
+				Position: Position{Line: 2, Column: 1},
+			},
+		},
+		"inline option": {
+			input: `
+options:
+  - name: verbose
+    type: bool
+    flag:
+      name: verbose
+`,
+			expected: Document{
+				Options: []OptionOrReference{
+					{
+						Option: Option{
+							Name:     "verbose",
+							Type:     "bool",
+							Flag:     FlagDef{Name: "verbose"},
+							Position: Position{Line: 3, Column: 5},
+						},
+					},
+				},
+				Position: Position{Line: 2, Column: 1},
 			},
 		},
 		"command with subcommands": {
@@ -108,17 +169,76 @@ commands:
 					"server": Command{
 						Commands: NamedCommands{
 							"start": Command{
-								Usage: optional.StringFrom("start"),
+								Usage:    optional.StringFrom("start"),
+								Position: Position{Line: 5, Column: 7},
 							},
 							"stop": Command{
-								Usage: optional.StringFrom("stop"),
+								Usage:    optional.StringFrom("stop"),
+								Position: Position{Line: 7, Column: 7},
 							},
 							"restart": Command{
-								Usage: optional.StringFrom("restart"),
+								Usage:    optional.StringFrom("restart"),
+								Position: Position{Line: 9, Column: 7},
+							},
+						},
+						Position: Position{Line: 3, Column: 3},
+					},
+				},
+				Position: Position{Line: 2, Column: 1},
+			},
+		},
+		"envPrefix names environment variables": {
+			input: `
+envPrefix: MYAPP_
+options:
+  - name: verbose
+    type: bool
+commands:
+  server:
+    options:
+      - name: connectTimeout
+        type: duration
+      - name: host
+        type: string
+        env:
+          name: HOST
+`,
+			expected: Document{
+				EnvPrefix: "MYAPP_",
+				Options: []OptionOrReference{
+					{
+						Option: Option{
+							Name:     "verbose",
+							Type:     "bool",
+							Env:      EnvDef{Name: "MYAPP_VERBOSE"},
+							Position: Position{Line: 4, Column: 5},
+						},
+					},
+				},
+				Commands: NamedCommands{
+					"server": Command{
+						Options: []OptionOrReference{
+							{
+								Option: Option{
+									Name:     "connectTimeout",
+									Type:     "duration",
+									Env:      EnvDef{Name: "MYAPP_SERVER_CONNECT_TIMEOUT"},
+									Position: Position{Line: 9, Column: 9},
+								},
+							},
+							{
+								Option: Option{
+									Name:     "host",
+									Type:     "string",
+									Env:      EnvDef{Name: "HOST"},
+									Position: Position{Line: 11, Column: 9},
+								},
 							},
 						},
+						Position: Position{Line: 7, Column: 3},
 					},
 				},
+				Position: Position{Line: 2, Column: 1},
 			},
 		},
 	}
@@ -146,7 +266,7 @@ maxArgs: 1
 `,
 			expected: func() (c bad.ReceiveCollector) {
 				c = bad.NewCollection()
-				c.Emit("minArgs must be less than maxArgs")
+				c.Emit("minArgs must be less than maxArgs at 2:1")
 				return
 			}(),
 			expectedErr: ErrValidation,
@@ -160,7 +280,24 @@ commands:
 `,
 			expected: func() (c bad.ReceiveCollector) {
 				c = bad.NewCollection()
-				c.Into("server").Emit("minArgs must be less than maxArgs")
+				c.Into("server").Emit("minArgs must be less than maxArgs at 3:3")
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"version 1 reports unknown fields": {
+			input: `---
+optionapi:
+  version: 1
+minArg: 3
+commands:
+  server:
+    maxArg: 1
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Emit(`unknown field "minArg", run "flick migrate" to remove it at 4:1`)
+				c.Emit(`unknown field "maxArg", run "flick migrate" to remove it at 7:5`)
 				return
 			}(),
 			expectedErr: ErrValidation,
@@ -173,7 +310,182 @@ commands:
 `,
 			expected: func() (c bad.ReceiveCollector) {
 				c = bad.NewCollection()
-				c.Emit("when sub-commands are specified, maxArgs must be 0")
+				c.Emit("when sub-commands are specified, maxArgs must be 0 at 2:1")
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"options at the same level share a flag alias": {
+			input: `---
+commands:
+  server:
+    options:
+      - name: verbose
+        type: bool
+        flag:
+          name: verbose
+          aliases: ["v"]
+      - name: version
+        type: bool
+        flag:
+          name: version
+          aliases: ["v"]
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("server").Emit(`flag "-v" of commands.server.options[1] at 10:9 is already declared by commands.server.options[0] at 5:9`)
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"sub-command flag shadows a root option referenced from components": {
+			input: `---
+options:
+  - $ref: "#/components/options/Host"
+commands:
+  server:
+    commands:
+      start:
+        options:
+          - name: host
+            type: string
+            flag:
+              name: host
+components:
+  options:
+    Host:
+      type: string
+      flag:
+        name: host
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("server").Into("start").Emit(`flag "--host" of commands.server.commands.start.options[0] at 9:13 is already declared by options[0] at 3:5`)
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"sub-command env shadows a parent option": {
+			input: `---
+commands:
+  server:
+    options:
+      - $ref: "#/components/options/Host"
+    commands:
+      start:
+        options:
+          - $ref: "#/components/options/Address"
+components:
+  options:
+    Host:
+      type: string
+      env:
+        name: HOST
+    Address:
+      type: string
+      env:
+        name: HOST
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("server").Into("start").Emit(`environment variable "HOST" of commands.server.commands.start.options[0] at 9:13 is already declared by commands.server.options[0] at 5:9`)
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"sibling commands may reuse names": {
+			input: `---
+commands:
+  start:
+    options:
+      - $ref: "#/components/options/Host"
+  stop:
+    options:
+      - $ref: "#/components/options/Host"
+components:
+  options:
+    Host:
+      type: string
+      flag:
+        name: host
+      env:
+        name: HOST
+`,
+			expected: bad.NewCollection(),
+		},
+		"sub-commands may reuse the names of local options": {
+			input: `---
+options:
+  - name: host
+    type: string
+    scope: local
+    flag:
+      name: host
+commands:
+  start:
+    options:
+      - name: host
+        type: string
+        flag:
+          name: host
+`,
+			expected: bad.NewCollection(),
+		},
+		"derived environment variable is declared by another option": {
+			input: `---
+envPrefix: MYAPP_
+commands:
+  server:
+    options:
+      - name: host
+        type: string
+      - name: address
+        type: string
+        env:
+          name: MYAPP_SERVER_HOST
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("server").Emit(`environment variable "MYAPP_SERVER_HOST" of commands.server.options[1] at 8:9 is already declared by commands.server.options[0] at 6:9`)
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"envPrefix is not an environment variable name": {
+			input: `---
+envPrefix: my-app
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Emit("envPrefix may only contain letters, digits and underscores, and may not begin with a digit at 2:1")
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"command alias is the name of a sibling": {
+			input: `---
+commands:
+  delete:
+  remove:
+    aliases: ["rm", "delete"]
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Into("remove").Emit(`alias "delete" is already used by command "delete" at 4:3`)
+				return
+			}(),
+			expectedErr: ErrValidation,
+		},
+		"unknown scope": {
+			input: `---
+options:
+  - name: host
+    type: string
+    scope: global
+`,
+			expected: func() (c bad.ReceiveCollector) {
+				c = bad.NewCollection()
+				c.Emit(`scope must be "persistent" or "local" at 3:5`)
 				return
 			}(),
 			expectedErr: ErrValidation,
@@ -191,6 +503,498 @@ commands:
 	}
 }
 
+func TestParse_Anchors(t *testing.T) {
+	input := `
+optionapi:
+  version: 2
+commands:
+  server:
+    options:
+      - &connect
+        name: connectTimeout
+        type: &to duration
+        description: !!str how long to wait when connecting
+        default: 30s
+      - name: readTimeout
+        type: *to
+      - <<: *connect
+        name: writeTimeout
+        default: 10s
+  client:
+    options:
+      - *connect
+`
+	actual, err := Parse(strings.NewReader(input), bad.NewCollection())
+	require.NoError(t, err)
+
+	server := actual.Commands["server"].Options
+	require.Len(t, server, 3)
+	assert.Equal(t, "connectTimeout", server[0].Name)
+	assert.Equal(t, "duration", server[0].Type)
+	assert.Equal(t, optional.StringFrom("how long to wait when connecting"), server[0].Description)
+	assert.Equal(t, "duration", server[1].Type)
+	assert.Equal(t, "writeTimeout", server[2].Name)
+	assert.Equal(t, "duration", server[2].Type)
+	assert.Equal(t, optional.StringFrom("how long to wait when connecting"), server[2].Description)
+	assert.Equal(t, optional.StringFrom("10s"), server[2].Default)
+
+	client := actual.Commands["client"].Options
+	require.Len(t, client, 1)
+	assert.Equal(t, server[0].Option, client[0].Option)
+}
+
+func TestParse_AnchorErrors(t *testing.T) {
+	cases := map[string]struct {
+		input       string
+		expectedErr string
+	}{
+		"unknown anchor": {
+			input: `---
+commands:
+  server:
+    usage: *missing
+`,
+			expectedErr: `invalid value at 4:12: unknown anchor: missing`,
+		},
+		"merging a value": {
+			input: `---
+commands:
+  server:
+    <<: 3
+`,
+			expectedErr: `invalid value at 4:8: only mappings can be merged with <<`,
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(c.input), bad.NewCollection())
+			assert.EqualError(t, err, c.expectedErr)
+		})
+	}
+}
+
+func TestParseWithDecodeErrors(t *testing.T) {
+	cases := map[string]struct {
+		input       string
+		expectedErr string
+	}{
+		"unknown field": {
+			input: `---
+commands:
+  server:
+    minArg: 3
+`,
+			expectedErr: `unknown field "minArg" at 4:5`,
+		},
+		"unknown field in version 2": {
+			input: `---
+optionapi:
+  version: 2
+minArg: 3
+`,
+			expectedErr: `unknown field "minArg" at 4:1`,
+		},
+		"version": {
+			input: `
+optionapi:
+  version: 4
+`,
+			expectedErr: `unsupported optionapi version "4" at 3:3, the newest version this flick supports is 2`,
+		},
+		"unsupported major version": {
+			input: `---
+optionapi:
+  version: 3.0
+commands:
+  server:
+    synopsis: "a field from the future"
+`,
+			expectedErr: `unsupported optionapi version "3.0" at 3:3, the newest version this flick supports is 2`,
+		},
+		"malformed version": {
+			input: `---
+optionapi:
+  version: latest
+`,
+			expectedErr: `invalid value at 3:3: optionapi version must be MAJOR or MAJOR.MINOR, such as "2", not "latest"`,
+		},
+		"unknown option field": {
+			input: `---
+options:
+  - name: verbose
+    typ: bool
+`,
+			expectedErr: `unknown field "typ" at 4:5`,
+		},
+		"wrong value type": {
+			input: `---
+minArgs: many
+`,
+			expectedErr: `invalid value at 2:10: strconv.ParseUint: parsing "many": invalid syntax`,
+		},
+		"list instead of mapping": {
+			input: `---
+commands:
+  - server
+`,
+			expectedErr: `invalid value at 3:3: expected a mapping, not a list`,
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			input := bytes.NewReader([]byte(c.input))
+			_, err := Parse(input, bad.NewCollection())
+			assert.EqualError(t, err, c.expectedErr)
+		})
+	}
+}
+
+func TestParse_ComponentReferences(t *testing.T) {
+	components := `
+components:
+  options:
+    Verbose:
+      type: bool
+      flag:
+        name: verbose
+    Cert:
+      type: string
+      flag:
+        name: cert
+  optionGroups:
+    Tls:
+      - $ref: "#/components/options/Cert"
+      - name: key
+        type: string
+        flag:
+          name: key
+  commands:
+    Server:
+      description: "runs the server"
+      options:
+        - $ref: "#/components/optionGroups/Tls"
+      commands:
+        start:
+          options:
+            - $ref: "#/components/options/Verbose"
+`
+	verbose := Option{
+		Type:     "bool",
+		Flag:     FlagDef{Name: "verbose"},
+		Position: Position{Line: 4, Column: 5},
+	}
+	cert := Option{
+		Type:     "string",
+		Flag:     FlagDef{Name: "cert"},
+		Position: Position{Line: 8, Column: 5},
+	}
+	key := Option{
+		Name:     "key",
+		Type:     "string",
+		Flag:     FlagDef{Name: "key"},
+		Position: Position{Line: 15, Column: 9},
+	}
+	cases := map[string]struct {
+		input       string
+		commandPath []string
+		expected    []OptionOrReference
+	}{
+		"root option": {
+			input: `---
+options:
+  - $ref: "#/components/options/Verbose"
+`,
+			expected: []OptionOrReference{{Option: verbose}},
+		},
+		"option group": {
+			input: `---
+commands:
+  server:
+    options:
+      - $ref: "#/components/optionGroups/Tls"
+      - $ref: "#/components/options/Verbose"
+`,
+			commandPath: []string{"server"},
+			expected:    []OptionOrReference{{Option: cert}, {Option: key}, {Option: verbose}},
+		},
+		"command": {
+			input: `---
+commands:
+  server:
+    $ref: "#/components/commands/Server"
+`,
+			commandPath: []string{"server"},
+			expected:    []OptionOrReference{{Option: cert}, {Option: key}},
+		},
+		"sub-command of a command": {
+			input: `---
+commands:
+  server:
+    $ref: "#/components/commands/Server"
+`,
+			commandPath: []string{"server", "start"},
+			expected:    []OptionOrReference{{Option: verbose}},
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			// positions above are lines within components, which follow the lines of the input
+			input := c.input + strings.TrimPrefix(components, "\n")
+			lines := strings.Count(c.input, "\n")
+			shift := func(options []OptionOrReference) (out []OptionOrReference) {
+				for _, option := range options {
+					option.Position.Line += lines - 1
+					out = append(out, option)
+				}
+				return
+			}
+			actual, err := Parse(bytes.NewReader([]byte(input)), bad.NewCollection())
+			require.NoError(t, err)
+			options := actual.Options
+			commands := actual.Commands
+			for _, name := range c.commandPath {
+				options = commands[name].Options
+				commands = commands[name].Commands
+			}
+			assert.Equal(t, shift(c.expected), options)
+		})
+	}
+}
+
+func TestParse_ReferenceErrors(t *testing.T) {
+	cases := map[string]struct {
+		input       string
+		expectedErr string
+	}{
+		"command that contains itself": {
+			input: `---
+commands:
+  server:
+    $ref: "#/components/commands/Server"
+components:
+  commands:
+    Server:
+      commands:
+        again:
+          $ref: "#/components/commands/Server"
+`,
+			expectedErr: "reference cycle: #/components/commands/Server -> #/components/commands/Server at 9:9",
+		},
+		"option group that contains itself": {
+			input: `---
+options:
+  - $ref: "#/components/optionGroups/Loop"
+components:
+  optionGroups:
+    Loop:
+      - $ref: "#/components/optionGroups/Loop"
+`,
+			expectedErr: "reference cycle: #/components/optionGroups/Loop -> #/components/optionGroups/Loop at 7:9",
+		},
+		"command referring to an option": {
+			input: `---
+commands:
+  server:
+    $ref: "#/components/options/Server"
+components:
+  options:
+    Server:
+      type: string
+`,
+			expectedErr: "undefined reference: '#/components/options/Server' at 3:3",
+		},
+		"undefined option group": {
+			input: `---
+options:
+  - $ref: "#/components/optionGroups/Missing"
+`,
+			expectedErr: "undefined reference: '#/components/optionGroups/Missing' at 3:5",
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			_, err := Parse(bytes.NewReader([]byte(c.input)), bad.NewCollection())
+			assert.EqualError(t, err, c.expectedErr)
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "optionapi")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	fileName := filepath.Join(dir, "cli.yaml")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(`---
+commands:
+  server:
+    options:
+      - $ref: "#/components/options/Missing"
+`), 0600))
+
+	_, err = ParseFile(fileName, bad.NewCollection())
+	var refErr *ErrReference
+	require.True(t, errors.As(err, &refErr))
+	assert.Equal(t, Position{File: fileName, Line: 5, Column: 9}, refErr.Position)
+	assert.EqualError(t, err, "undefined reference: '#/components/options/Missing' at "+fileName+":5:9")
+}
+
+func TestParseFile_CrossFileReferences(t *testing.T) {
+	common := `---
+components:
+  options:
+    ConnectTimeout:
+      type: duration
+      flag:
+        name: connectTimeout
+    Loop:
+      $ref: "../cli.yaml#/components/options/Loop"
+`
+	cases := map[string]struct {
+		cli         string
+		expected    Option
+		expectedErr string
+	}{
+		"option in another file": {
+			cli: `---
+commands:
+  server:
+    options:
+      - $ref: "shared/common.yaml#/components/options/ConnectTimeout"
+`,
+			expected: Option{
+				Type:     "duration",
+				Flag:     FlagDef{Name: "connectTimeout"},
+				Position: Position{File: "shared/common.yaml", Line: 4, Column: 5},
+			},
+		},
+		"component that refers to another file": {
+			cli: `---
+commands:
+  server:
+    options:
+      - $ref: "#/components/options/Timeout"
+components:
+  options:
+    Timeout:
+      $ref: "shared/common.yaml#/components/options/ConnectTimeout"
+`,
+			expected: Option{
+				Type:     "duration",
+				Flag:     FlagDef{Name: "connectTimeout"},
+				Position: Position{File: "shared/common.yaml", Line: 4, Column: 5},
+			},
+		},
+		"undefined in another file": {
+			cli: `---
+commands:
+  server:
+    options:
+      - $ref: "#/components/options/Timeout"
+components:
+  options:
+    Timeout:
+      $ref: "shared/common.yaml#/components/options/Missing"
+`,
+			expectedErr: "undefined reference: 'shared/common.yaml#/components/options/Missing' (reference chain: " +
+				"cli.yaml#/components/options/Timeout -> shared/common.yaml#/components/options/Missing) at cli.yaml:8:5",
+		},
+		"cycle": {
+			cli: `---
+commands:
+  server:
+    options:
+      - $ref: "#/components/options/Loop"
+components:
+  options:
+    Loop:
+      $ref: "shared/common.yaml#/components/options/Loop"
+`,
+			expectedErr: "reference cycle: cli.yaml#/components/options/Loop -> shared/common.yaml#/components/options/Loop -> " +
+				"cli.yaml#/components/options/Loop at shared/common.yaml:8:5",
+		},
+		"missing file": {
+			cli: `---
+commands:
+  server:
+    options:
+      - $ref: "missing.yaml#/components/options/Missing"
+`,
+			expectedErr: "unable to read reference 'missing.yaml#/components/options/Missing' at cli.yaml:5:9: " +
+				"open missing.yaml: no such file or directory",
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "optionapi")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			require.NoError(t, os.Mkdir(filepath.Join(dir, "shared"), 0700))
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shared", "common.yaml"), []byte(common), 0600))
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cli.yaml"), []byte(c.cli), 0600))
+			// file names in positions and errors are relative to the working directory, keep them short
+			wd, err := os.Getwd()
+			require.NoError(t, err)
+			require.NoError(t, os.Chdir(dir))
+			defer func() {
+				_ = os.Chdir(wd)
+			}()
+
+			actual, err := ParseFile("cli.yaml", bad.NewCollection())
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual.Commands["server"].Options[0].Option)
+		})
+	}
+}
+
+func TestParseFile_ValidatesReferencedComponents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "optionapi")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "common.yaml"), []byte(`---
+components:
+  options:
+    Verbose:
+      type: count
+      list: true
+      scope: everywhere
+`), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cli.yaml"), []byte(`---
+commands:
+  server:
+    options:
+      - $ref: "common.yaml#/components/options/Verbose"
+`), 0600))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+
+	validationErrors := bad.NewCollection()
+	_, err = ParseFile("cli.yaml", validationErrors)
+	assert.Equal(t, ErrValidation, err)
+	expected := bad.NewCollection()
+	expected.Into("server").Emit(`scope must be "persistent" or "local" at common.yaml:4:5`)
+	expected.Into("server").Emit(`options of type "count" cannot be a list at common.yaml:4:5`)
+	assert.Equal(t, expected, validationErrors)
+}
+
 func assertEqualNilSafeError(t *testing.T, actual error, expectedOrNil error) {
 	if expectedOrNil != nil {
 		assert.EqualError(t, actual, expectedOrNil.Error())
@@ -198,3 +1002,39 @@ func assertEqualNilSafeError(t *testing.T, actual error, expectedOrNil error) {
 		require.NoError(t, actual)
 	}
 }
+
+func TestEnvName(t *testing.T) {
+	cases := map[string]struct {
+		prefix   string
+		words    []string
+		expected string
+	}{
+		"camel case": {
+			prefix:   "MYAPP_",
+			words:    []string{"server", "connectTimeout"},
+			expected: "MYAPP_SERVER_CONNECT_TIMEOUT",
+		},
+		"upper camel case": {
+			words:    []string{"ConnectTimeout"},
+			expected: "CONNECT_TIMEOUT",
+		},
+		"dashed": {
+			prefix:   "MYAPP_",
+			words:    []string{"set-config", "dry-run"},
+			expected: "MYAPP_SET_CONFIG_DRY_RUN",
+		},
+		"run of capitals": {
+			words:    []string{"HTTPPort", "useTLS"},
+			expected: "HTTP_PORT_USE_TLS",
+		},
+		"already upper snake case": {
+			words:    []string{"CONFIG_FILE_PATH"},
+			expected: "CONFIG_FILE_PATH",
+		},
+	}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			assert.Equal(t, c.expected, EnvName(c.prefix, c.words...))
+		})
+	}
+}
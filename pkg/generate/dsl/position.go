@@ -0,0 +1,43 @@
+package dsl
+
+import (
+	"fmt"
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Position is where a node was declared in an optionapi document. File is blank when the document was not read from
+// a file and Line is 0 when the node was not decoded at all, such as when a Document is built in code
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func newPosition(fileName string, node ast.Node) Position {
+	position := node.GetToken().Position
+	return Position{
+		File:   fileName,
+		Line:   position.Line,
+		Column: position.Column,
+	}
+}
+
+func (p Position) IsSet() bool {
+	return p.Line != 0
+}
+
+// String is the position in the format understood by most editors: file:line:column
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// atPosition appends the position to message, if it is known
+func atPosition(message string, p Position) string {
+	if !p.IsSet() {
+		return message
+	}
+	return message + " at " + p.String()
+}
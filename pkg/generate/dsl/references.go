@@ -0,0 +1,297 @@
+package dsl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	componentKindOptions      = "options"
+	componentKindCommands     = "commands"
+	componentKindOptionGroups = "optionGroups"
+
+	componentsPrefix            = "#/components/"
+	componentOptionsPrefix      = componentsPrefix + componentKindOptions + "/"
+	componentCommandsPrefix     = componentsPrefix + componentKindCommands + "/"
+	componentOptionGroupsPrefix = componentsPrefix + componentKindOptionGroups + "/"
+)
+
+// referenceResolver finds the components that references point to. References may point to components in the same
+// document, "#/components/options/Name", or in another file, "common.yaml#/components/options/Name". Other files are
+// read relative to the file containing the reference and are only read once.
+//
+// Every method takes chain, the references followed so far qualified by the file that contains the component, which
+// is used to detect cycles. Pass nil to start a new chain
+type referenceResolver struct {
+	// documents holds every document read so far by its file name, including the document being parsed
+	documents map[string]*Document
+}
+
+func newReferenceResolver(root *Document, fileName string) *referenceResolver {
+	return &referenceResolver{
+		documents: map[string]*Document{
+			cleanFileName(fileName): root,
+		},
+	}
+}
+
+// component is a reference that was found
+type component struct {
+	// fileName is the file containing the component, references within it are relative to this file
+	fileName string
+	name     string
+	doc      *Document
+	// chain is the chain of references that ends with this component
+	chain []string
+}
+
+// find locates the component of kind that reference points to. fromFile is the file containing the reference and
+// position is where the reference was declared
+func (r *referenceResolver) find(fromFile, reference, kind string, position Position, chain []string) (found component, err error) {
+	fileName, referenceKind, name, ok := splitReference(fromFile, reference)
+	if !ok || referenceKind != kind {
+		err = newErrReference(reference, position, chain)
+		return
+	}
+	qualified := fileName + componentsPrefix + kind + "/" + name
+	for _, followed := range chain {
+		if followed == qualified {
+			err = &ErrReferenceCycle{
+				Chain:    appendChain(chain, qualified),
+				Position: position,
+			}
+			return
+		}
+	}
+	found = component{
+		fileName: fileName,
+		name:     name,
+		chain:    appendChain(chain, qualified),
+	}
+	found.doc, err = r.document(fileName)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", atPosition("unable to read reference '"+reference+"'", position), err)
+	}
+	return
+}
+
+// option finds the option that reference points to. The returned option keeps the position of the component
+func (r *referenceResolver) option(fromFile, reference string, position Position, chain []string) (out Option, err error) {
+	found, err := r.find(fromFile, reference, componentKindOptions, position, chain)
+	if err != nil {
+		return
+	}
+	opt, ok := found.doc.Components.Options[found.name]
+	if !ok {
+		err = newErrReference(reference, position, found.chain)
+		return
+	}
+	if isBlank(opt.Reference) {
+		return opt.Option, nil
+	}
+	return r.option(found.fileName, opt.Reference, opt.Position, found.chain)
+}
+
+// options replaces every reference in options. References to option groups are replaced with every option in the group
+func (r *referenceResolver) options(fromFile string, options []OptionOrReference, chain []string) (out []OptionOrReference, err error) {
+	for _, opt := range options {
+		if isBlank(opt.Reference) {
+			out = append(out, opt)
+			continue
+		}
+		if referenceKindOf(opt.Reference) != componentKindOptionGroups {
+			var resolved Option
+			resolved, err = r.option(fromFile, opt.Reference, opt.Position, chain)
+			if err != nil {
+				return
+			}
+			out = append(out, OptionOrReference{Option: resolved})
+			continue
+		}
+		var found component
+		found, err = r.find(fromFile, opt.Reference, componentKindOptionGroups, opt.Position, chain)
+		if err != nil {
+			return
+		}
+		group, ok := found.doc.Components.OptionGroups[found.name]
+		if !ok {
+			err = newErrReference(opt.Reference, opt.Position, found.chain)
+			return
+		}
+		var groupOptions []OptionOrReference
+		groupOptions, err = r.options(found.fileName, group, found.chain)
+		if err != nil {
+			return
+		}
+		out = append(out, groupOptions...)
+	}
+	return
+}
+
+// optionsOrEmpty is the options that opt stands for, which is more than one for option groups. Unresolvable references
+// stand for no options. Use it for checks that run before references are replaced, as the replacement reports the problem
+func (r *referenceResolver) optionsOrEmpty(fromFile string, opt OptionOrReference) []OptionOrReference {
+	resolved, err := r.options(fromFile, []OptionOrReference{opt}, nil)
+	if err != nil {
+		return nil
+	}
+	return resolved
+}
+
+// commandComponent follows the reference of command, if it has one. fileName is the file that the options and
+// sub-commands of out are relative to
+func (r *referenceResolver) commandComponent(fromFile string, command Command, chain []string) (out Command, fileName string, outChain []string, err error) {
+	if isBlank(command.Reference) {
+		return command, fromFile, chain, nil
+	}
+	found, err := r.find(fromFile, command.Reference, componentKindCommands, command.Position, chain)
+	if err != nil {
+		return
+	}
+	referenced, ok := found.doc.Components.Commands[found.name]
+	if !ok {
+		err = newErrReference(command.Reference, command.Position, found.chain)
+		return
+	}
+	return r.commandComponent(found.fileName, referenced, found.chain)
+}
+
+// command returns a copy of command with every reference within it, and within its sub-commands, replaced
+func (r *referenceResolver) command(fromFile string, command Command, chain []string) (out Command, err error) {
+	out, fromFile, chain, err = r.commandComponent(fromFile, command, chain)
+	if err != nil {
+		return
+	}
+	out.Options, err = r.options(fromFile, out.Options, chain)
+	if err != nil {
+		return
+	}
+	out.Commands, err = r.commands(fromFile, out.Commands, chain)
+	return
+}
+
+func (r *referenceResolver) commands(fromFile string, commands NamedCommands, chain []string) (out NamedCommands, err error) {
+	if commands == nil {
+		return
+	}
+	out = make(NamedCommands, len(commands))
+	for _, name := range commands.names() {
+		out[name], err = r.command(fromFile, commands[name], chain)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *referenceResolver) document(fileName string) (doc *Document, err error) {
+	if doc, ok := r.documents[fileName]; ok {
+		return doc, nil
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	loaded, _, err := decodeDocument(file, fileName)
+	if err != nil {
+		return
+	}
+	r.documents[fileName] = &loaded
+	return &loaded, nil
+}
+
+// splitReference separates the file name, kind of component and component name of reference. The file name is
+// relative to fromFile and is fromFile itself when reference points within the same document
+func splitReference(fromFile, reference string) (fileName, kind, name string, ok bool) {
+	hash := strings.Index(reference, "#")
+	if hash == -1 {
+		return
+	}
+	kind = referenceKindOf(reference)
+	if isBlank(kind) {
+		return
+	}
+	name = reference[hash+len(componentsPrefix)+len(kind)+1:]
+	if isBlank(name) {
+		return
+	}
+	fileName = reference[:hash]
+	switch {
+	case fileName == "":
+		fileName = cleanFileName(fromFile)
+	case filepath.IsAbs(fileName):
+		fileName = filepath.Clean(fileName)
+	default:
+		fileName = filepath.Join(filepath.Dir(fromFile), fileName)
+	}
+	return fileName, kind, name, true
+}
+
+// referenceKindOf is the kind of component that reference points to, or blank if it is not a component
+func referenceKindOf(reference string) string {
+	hash := strings.Index(reference, "#")
+	if hash == -1 {
+		return ""
+	}
+	pointer := reference[hash:]
+	for _, kind := range []string{componentKindOptions, componentKindCommands, componentKindOptionGroups} {
+		if strings.HasPrefix(pointer, componentsPrefix+kind+"/") {
+			return kind
+		}
+	}
+	return ""
+}
+
+func cleanFileName(fileName string) string {
+	if fileName == "" {
+		return ""
+	}
+	return filepath.Clean(fileName)
+}
+
+// appendChain returns a new chain, chains are shared by sibling commands and must not be appended to in place
+func appendChain(chain []string, reference string) []string {
+	return append(append(make([]string, 0, len(chain)+1), chain...), reference)
+}
+
+// replaceDocumentReferences replaces every reference in doc, read from fileName, with a copy of what it points to.
+// Components that are references are replaced too, each starting its own reference chain
+func replaceDocumentReferences(doc *Document, resolver *referenceResolver, fileName string) (err error) {
+	for _, name := range doc.Components.Options.names() {
+		var resolved Option
+		resolved, err = resolver.option(fileName, componentOptionsPrefix+name, doc.Components.Options[name].Position, nil)
+		if err != nil {
+			return
+		}
+		doc.Components.Options[name] = OptionOrReference{Option: resolved}
+	}
+	for _, name := range doc.Components.OptionGroups.names() {
+		doc.Components.OptionGroups[name], err = resolver.options(fileName, []OptionOrReference{
+			{Reference: componentOptionGroupsPrefix + name},
+		}, nil)
+		if err != nil {
+			return
+		}
+	}
+	for _, name := range doc.Components.Commands.names() {
+		doc.Components.Commands[name], err = resolver.command(fileName, Command{
+			Reference: componentCommandsPrefix + name,
+			Position:  doc.Components.Commands[name].Position,
+		}, nil)
+		if err != nil {
+			return
+		}
+	}
+
+	doc.Options, err = resolver.options(fileName, doc.Options, nil)
+	if err != nil {
+		return
+	}
+	doc.Commands, err = resolver.commands(fileName, doc.Commands, nil)
+	return
+}
@@ -2,11 +2,10 @@ package goland
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"github.com/wojnosystems/flick/pkg/generate/completion"
 	"github.com/wojnosystems/flick/pkg/generate/dsl"
 	"github.com/wojnosystems/flick/pkg/string_writer"
-	"github.com/wojnosystems/go-string-set/string_set"
 	"io"
 	"sort"
 	"strings"
@@ -28,15 +27,21 @@ const (
 	defaultInterfaceName          = "Interface"
 	defaultStructName             = "Unimplemented"
 	defaultGlobalOptionStructName = "AllCommand"
+	persistentStructSuffix        = "Persistent"
 
 	goOptionalLibraryImportPath = "github.com/wojnosystems/go-optional/v2"
 	goFlickLibraryImportPath    = "github.com/wojnosystems/flick/cli"
+	goFlickParseImportPath      = "github.com/wojnosystems/flick/parse"
+	goFlickDslImportPath        = "github.com/wojnosystems/flick/pkg/generate/dsl"
+	configDumpStructName        = "parse.ConfigDump"
 )
 
 type optionStruct struct {
 	name       string
 	parentName string
-	options    []dsl.Option
+	// embedded are the structs embedded in this one, such as parse.ConfigDump or the persistent options of the root
+	embedded []string
+	options  []dsl.Option
 }
 
 type structMethodDefinition struct {
@@ -49,7 +54,8 @@ type collected struct {
 	baseStructMethodDefs  []structMethodDefinition
 	globalStruct          *optionStruct
 	optionStructs         []optionStruct
-	optionStructRegistry  string_set.Interface
+	// persistentStructs are the structs that sub-commands embed, by the path of the command that declares them
+	persistentStructs map[string]string
 }
 
 func (c *collected) addGlobalStruct(o optionStruct) {
@@ -59,11 +65,50 @@ func (c *collected) addGlobalStruct(o optionStruct) {
 
 func (c *collected) addOptionStruct(o optionStruct) {
 	c.optionStructs = append(c.optionStructs, o)
-	c.optionStructRegistry.Add(o.name)
 }
 
-func (c collected) getParentStructName(prefix []string) string {
-	return getStructOrBlank(stringSlicePop(prefix), c.optionStructRegistry, c.globalStruct)
+// inheritedStructName is the struct of the options that the command at prefix inherits: the persistent options of its
+// nearest parent that declares any, or of the root. It is blank when there are none
+func (c collected) inheritedStructName(prefix []string) string {
+	for i := len(prefix) - 1; i > 0; i-- {
+		if name, ok := c.persistentStructs[strings.Join(prefix[:i], " ")]; ok {
+			return name
+		}
+	}
+	if c.globalStruct != nil {
+		return c.globalStruct.name
+	}
+	return ""
+}
+
+// addCommandStructs adds the options struct of the command at prefix, which embeds the struct it inherits. Like the
+// root, a command with sub-commands and local options gets a second struct for its persistent options, which its own
+// struct and its sub-commands embed, as sub-commands only inherit the persistent options
+func (c *collected) addCommandStructs(prefix []string, cmd dsl.Command) {
+	name := prefixToOptionStructName(prefix)
+	inherited := c.inheritedStructName(prefix)
+	if !cmd.Commands.HasAny() {
+		options := make([]dsl.Option, len(cmd.Options))
+		for i, reference := range cmd.Options {
+			options[i] = reference.Option
+		}
+		c.addOptionStruct(optionStruct{name: name, parentName: inherited, options: options})
+		return
+	}
+	persistent, local := splitOptionsByScope(cmd.Options)
+	path := strings.Join(prefix, " ")
+	switch {
+	case len(local) == 0:
+		c.addOptionStruct(optionStruct{name: name, parentName: inherited, options: persistent})
+		c.persistentStructs[path] = name
+	case len(persistent) == 0:
+		c.addOptionStruct(optionStruct{name: name, parentName: inherited, options: local})
+	default:
+		persistentName := name + persistentStructSuffix
+		c.addOptionStruct(optionStruct{name: persistentName, parentName: inherited, options: persistent})
+		c.persistentStructs[path] = persistentName
+		c.addOptionStruct(optionStruct{name: name, parentName: persistentName, options: local})
+	}
 }
 
 func (g *GoLang) Generate(_ context.Context, document *dsl.Document, output io.Writer) (bytesWritten int, err error) {
@@ -97,7 +142,7 @@ func (g *GoLang) Generate(_ context.Context, document *dsl.Document, output io.W
 		interfaceDeclarations: make([]string, 0, 10),
 		baseStructMethodDefs:  make([]structMethodDefinition, 0, 10),
 		optionStructs:         make([]optionStruct, 0, 10),
-		optionStructRegistry:  string_set.New(),
+		persistentStructs:     make(map[string]string),
 	}
 
 	err = g.collectComponents(document, &generatedComponents)
@@ -181,12 +226,30 @@ func collectImports(document *dsl.Document, optionTypes optionTypeRegistry) (out
 		Path:  goFlickLibraryImportPath,
 		Alias: "",
 	}
+	if document.PrintConfig {
+		out[goFlickParseImportPath] = goImport{
+			Path: goFlickParseImportPath,
+		}
+	}
+	if completion.NeedsValueCompleter(document) {
+		out[goFlickDslImportPath] = goImport{
+			Path: goFlickDslImportPath,
+		}
+	}
 	return
 }
 
+// hasGlobalOptions is true when the root has an options struct, which the hooks of the root are given
+func hasGlobalOptions(document *dsl.Document) bool {
+	return len(document.Options) != 0 || document.PrintConfig
+}
+
 func addOptionToImports(out importRegistryType, prefix []string, option *dsl.OptionOrReference, optionTypes optionTypeRegistry) (err error) {
 	if t, ok := optionTypes[option.Type]; !ok {
-		err = fmt.Errorf(`unsupported option type: "%s"`, option.Type)
+		err = &dsl.ErrInvalidValue{
+			Reason:   fmt.Sprintf(`unsupported option type: "%s"`, option.Type),
+			Position: option.Position,
+		}
 		return
 	} else {
 		var imp goImport
@@ -240,7 +303,10 @@ func shouldUseOptional(option dsl.Option, prefix []string) (useOptional bool, er
 	if option.Required {
 		if option.Default.IsSet() {
 			path := "\"" + strings.Join(prefix, "/") + "/" + option.Name + "\""
-			err = errors.New("option at " + path + " cannot have a default value and also be required")
+			err = &dsl.ErrInvalidValue{
+				Reason:   "option at " + path + " cannot have a default value and also be required",
+				Position: option.Position,
+			}
 			return
 		}
 		// optional not required
@@ -252,11 +318,15 @@ func shouldUseOptional(option dsl.Option, prefix []string) (useOptional bool, er
 			useOptional = true
 		}
 	}
+	// lists are empty when there is no value
+	if option.List {
+		useOptional = false
+	}
 	return
 }
 
 func (g *GoLang) collectComponents(document *dsl.Document, c *collected) (err error) {
-	if len(document.Options) != 0 {
+	if hasGlobalOptions(document) {
 		// BEFORE HOOK
 		c.interfaceDeclarations = append(c.interfaceDeclarations,
 			fmt.Sprintf(`HookBefore(ctx context.Context, opts *%sOptions) error`,
@@ -290,25 +360,47 @@ func (g *GoLang) collectComponents(document *dsl.Document, c *collected) (err er
 		})
 	}
 
-	if len(document.Options) != 0 {
-		globalStruct := optionStruct{
-			name:    g.globalOptionStructName(),
-			options: make([]dsl.Option, len(document.Options)),
+	if hasGlobalOptions(document) {
+		persistent, local := splitOptionsByScope(document.Options)
+		var embedded []string
+		if document.PrintConfig {
+			// inherited, so that every command can print its configuration
+			embedded = append(embedded, configDumpStructName)
 		}
-		for i, reference := range document.Options {
-			globalStruct.options[i] = reference.Option
+		if len(local) == 0 {
+			c.addGlobalStruct(optionStruct{
+				name:     g.globalOptionStructName(),
+				embedded: embedded,
+				options:  persistent,
+			})
+		} else {
+			// sub-commands only inherit the persistent options, so they get a struct of their own for the hooks to embed.
+			// The root embeds it too, so that the persistent options are promoted to the root's struct
+			var rootEmbedded []string
+			if len(persistent) != 0 || len(embedded) != 0 {
+				persistentName := g.globalOptionStructName() + persistentStructSuffix
+				c.addGlobalStruct(optionStruct{
+					name:     persistentName,
+					embedded: embedded,
+					options:  persistent,
+				})
+				rootEmbedded = append(rootEmbedded, persistentName+"Options")
+			}
+			c.addOptionStruct(optionStruct{
+				name:     g.globalOptionStructName(),
+				embedded: rootEmbedded,
+				options:  local,
+			})
 		}
-		c.addGlobalStruct(globalStruct)
 	}
 
 	err = walkCommands(document, func(prefix []string, cmd dsl.Command) (walkErr error) {
 		methodName := joinPrefixesAsMethodName(prefix)
 
-		var optionStructName string
+		optionStructName := c.inheritedStructName(prefix)
 		if len(cmd.Options) != 0 {
+			c.addCommandStructs(prefix, cmd)
 			optionStructName = methodName
-		} else {
-			optionStructName = getStructOrBlank(stringSlicePop(prefix), c.optionStructRegistry, c.globalStruct)
 		}
 
 		optionFormal := ""
@@ -337,21 +429,34 @@ func (g *GoLang) collectComponents(document *dsl.Document, c *collected) (err er
 				declaration: fmt.Sprintf(`%s(_ context.Context%s) error`, methodName, optionFormalWithoutNamedParam),
 				body:        `return cli.ErrCommandUnimplemented`,
 			})
-
-			if len(cmd.Options) != 0 {
-				commandOption := optionStruct{
-					name:       prefixToOptionStructName(prefix),
-					parentName: c.getParentStructName(prefix),
-					options:    make([]dsl.Option, len(cmd.Options)),
-				}
-				for i, reference := range cmd.Options {
-					commandOption.options[i] = reference.Option
-				}
-				c.addOptionStruct(commandOption)
-			}
 		}
 		return
 	})
+	if err != nil {
+		return
+	}
+
+	if completion.NeedsValueCompleter(document) {
+		// the hidden __complete command asks the application for option values, see cli.WithCompletion
+		c.interfaceDeclarations = append(c.interfaceDeclarations,
+			`CompleteValue(ctx context.Context, commandPath []string, option dsl.Option, partial string) []string`)
+		c.baseStructMethodDefs = append(c.baseStructMethodDefs, structMethodDefinition{
+			declaration: `CompleteValue(_ context.Context, _ []string, _ dsl.Option, _ string) []string`,
+			body:        `return nil`,
+		})
+	}
+	return
+}
+
+// splitOptionsByScope separates the options inherited by sub-commands from those that are not
+func splitOptionsByScope(options []dsl.OptionOrReference) (persistent, local []dsl.Option) {
+	for _, option := range options {
+		if option.IsPersistent() {
+			persistent = append(persistent, option.Option)
+		} else {
+			local = append(local, option.Option)
+		}
+	}
 	return
 }
 
@@ -415,7 +520,14 @@ func (g *GoLang) writeOptionStructs(out *string_writer.Type, declarations *colle
 		}
 		err = out.In(func(out *string_writer.Type) (err error) {
 			if len(subStruct.parentName) != 0 {
-				err = out.WriteLnF(`%s %sOptions`, subStruct.parentName, subStruct.parentName)
+				// embedded, so that the options inherited from the parent are promoted and read from the same flags
+				err = out.WriteLnF(`%sOptions`, subStruct.parentName)
+				if err != nil {
+					return
+				}
+			}
+			for _, embedded := range subStruct.embedded {
+				err = out.WriteLn(embedded)
 				if err != nil {
 					return
 				}
@@ -471,6 +583,34 @@ func (g *GoLang) writeUnimplementedStruct(out *string_writer.Type, declarations
 	return nil
 }
 
+// MethodName is the name of the Interface method generated for the command at path, e.g. ServerStart for server start
+func MethodName(path []string) string {
+	return joinPrefixesAsMethodName(path)
+}
+
+// FieldType is the Go type of the options struct field generated for option, e.g. optional.Int or time.Duration
+func FieldType(option dsl.Option) (goType string, err error) {
+	t, ok := registerOptionalTypes(make(optionTypeRegistry))[option.Type]
+	if !ok {
+		err = &dsl.ErrInvalidValue{
+			Reason:   `unsupported option type: "` + option.Type + `"`,
+			Position: option.Position,
+		}
+		return
+	}
+	useOptional, err := shouldUseOptional(option, []string{})
+	if err != nil {
+		return
+	}
+	if option.List {
+		return "[]" + t.Type, nil
+	}
+	if useOptional {
+		return t.OptionalType, nil
+	}
+	return t.Type, nil
+}
+
 func joinPrefixesAsMethodName(prefix []string) string {
 	title := make([]string, len(prefix))
 	for i, s := range prefix {
@@ -484,7 +624,10 @@ func writeOptionStructField(out *string_writer.Type, optionKey string, optionDef
 	useOptional, _ = shouldUseOptional(optionDef, []string{})
 
 	if t, ok := optionTypes[optionDef.Type]; !ok {
-		err = errors.New(`unsupported option type: "` + optionDef.Type + `"`)
+		err = &dsl.ErrInvalidValue{
+			Reason:   `unsupported option type: "` + optionDef.Type + `"`,
+			Position: optionDef.Position,
+		}
 	} else {
 		typeToUse := ""
 		if !useOptional {
@@ -492,7 +635,60 @@ func writeOptionStructField(out *string_writer.Type, optionKey string, optionDef
 		} else {
 			typeToUse = t.OptionalType
 		}
-		err = out.WriteLnF(`%s %s`, optionDef.Name, typeToUse)
+		if optionDef.List {
+			typeToUse = "[]" + typeToUse
+		}
+		var tags []string
+		if optionDef.Env.Name != "" {
+			// read by parse.Env, whichever command the field belongs to
+			tags = append(tags, fmt.Sprintf(`env:"%s"`, optionDef.Env.Name))
+		}
+		tags = append(tags, flagTags(optionDef.Flag)...)
+		if optionDef.File.Name != "" {
+			// read by parse.Yaml from the top level of the configuration file, whichever command the field belongs to
+			tags = append(tags, fmt.Sprintf(`yaml:"%s"`, optionDef.File.Name))
+		}
+		if optionDef.Type == dsl.TypeCount {
+			// each time the flag is given adds one
+			tags = append(tags, `flag-count:"true"`)
+		}
+		if optionDef.Secret {
+			// redacted when the configuration is printed or traced
+			tags = append(tags, `secret:"true"`)
+		}
+		if len(tags) == 0 {
+			err = out.WriteLnF(`%s %s`, optionDef.Name, typeToUse)
+		} else {
+			err = out.WriteLnF("%s %s `%s`", optionDef.Name, typeToUse, strings.Join(tags, " "))
+		}
+	}
+	return
+}
+
+// flagTags are the tags that parse.Flags finds the field by: the first long and the first one letter name among the
+// name and aliases of flag, then the rest of them as flag-aliases
+func flagTags(flag dsl.FlagDef) (tags []string) {
+	long, short := "", ""
+	var aliases []string
+	for _, name := range append([]string{flag.Name}, flag.Aliases...) {
+		switch {
+		case name == "":
+		case len([]rune(name)) == 1 && short == "":
+			short = name
+		case len([]rune(name)) != 1 && long == "":
+			long = name
+		default:
+			aliases = append(aliases, name)
+		}
+	}
+	if long != "" {
+		tags = append(tags, fmt.Sprintf(`flag:"%s"`, long))
+	}
+	if short != "" {
+		tags = append(tags, fmt.Sprintf(`flag-short:"%s"`, short))
+	}
+	if len(aliases) != 0 {
+		tags = append(tags, fmt.Sprintf(`flag-aliases:"%s"`, strings.Join(aliases, ",")))
 	}
 	return
 }
@@ -510,23 +706,3 @@ func eachString(items []string, callback func(string) string) (out []string) {
 	}
 	return
 }
-
-func getStructOrBlank(prefix []string, set string_set.Tester, globalStruct *optionStruct) string {
-	for i := len(prefix) - 1; i > 0; i-- {
-		p := prefixToOptionStructName(prefix[0:i])
-		if set.Includes(p) {
-			return p
-		}
-	}
-	if globalStruct != nil {
-		return globalStruct.name
-	}
-	return ""
-}
-
-func stringSlicePop(s []string) []string {
-	if len(s) > 1 {
-		return s[0 : len(s)-2]
-	}
-	return s[0:0]
-}
@@ -5,7 +5,12 @@ import (
 	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/cmd_definitions"
 	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/go-optional/v2"
+	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -63,6 +68,206 @@ type AllCommandOptions struct {
   key1 optional.Int
 }
 
+type Unimplemented struct {
+  HookBefore(_ context.Context, _ *AllCommandOptions) error {
+    return nil
+  }
+  HookAfter(_ context.Context, _ *AllCommandOptions, _ error) error {
+    return nil
+  }
+}
+`,
+		},
+		"with secret option": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name:     "Password",
+							Type:     "string",
+							Required: true,
+							Secret:   true,
+						},
+					},
+				},
+			},
+			expected: globalHeader + `)
+
+type Interface interface {
+  HookBefore(ctx context.Context, opts *AllCommandOptions) error
+  HookAfter(ctx context.Context, opts *AllCommandOptions, err error) error
+}
+
+type AllCommandOptions struct {
+  Password string ` + "`" + `secret:"true"` + "`" + `
+}
+
+type Unimplemented struct {
+  HookBefore(_ context.Context, _ *AllCommandOptions) error {
+    return nil
+  }
+  HookAfter(_ context.Context, _ *AllCommandOptions, _ error) error {
+    return nil
+  }
+}
+`,
+		},
+		"sub-command option with environment variable": {
+			input: dsl.Document{
+				Commands: dsl.NamedCommands{
+					"start": dsl.Command{
+						Options: []dsl.OptionOrReference{
+							{
+								Option: dsl.Option{
+									Name: "HasBanana",
+									Type: "bool",
+									Env:  dsl.EnvDef{Name: "BANANA"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: globalHeader + `  "github.com/wojnosystems/go-optional/v2"
+)
+
+type Interface interface {
+  HookBefore(ctx context.Context) error
+  HookAfter(ctx context.Context, err error) error
+  Start(ctx context.Context, opts *StartOptions) error
+}
+
+type StartOptions struct {
+  HasBanana optional.Bool ` + "`" + `env:"BANANA"` + "`" + `
+}
+
+type Unimplemented struct {
+  HookBefore(_ context.Context) error {
+    return nil
+  }
+  HookAfter(_ context.Context, _ error) error {
+    return nil
+  }
+  Start(_ context.Context, _ *StartOptions) error {
+    return cli.ErrCommandUnimplemented
+  }
+}
+`,
+		},
+		"print config": {
+			input: dsl.Document{
+				PrintConfig: true,
+				Commands: dsl.NamedCommands{
+					"bar": dsl.Command{},
+				},
+			},
+			expected: globalHeader + `  "github.com/wojnosystems/flick/parse"
+)
+
+type Interface interface {
+  HookBefore(ctx context.Context, opts *AllCommandOptions) error
+  HookAfter(ctx context.Context, opts *AllCommandOptions, err error) error
+  Bar(ctx context.Context, opts *AllCommandOptions) error
+}
+
+type AllCommandOptions struct {
+  parse.ConfigDump
+}
+
+type Unimplemented struct {
+  HookBefore(_ context.Context, _ *AllCommandOptions) error {
+    return nil
+  }
+  HookAfter(_ context.Context, _ *AllCommandOptions, _ error) error {
+    return nil
+  }
+  Bar(_ context.Context, _ *AllCommandOptions) error {
+    return cli.ErrCommandUnimplemented
+  }
+}
+`,
+		},
+		"completed option values": {
+			input: dsl.Document{
+				Commands: dsl.NamedCommands{
+					"start": dsl.Command{
+						Options: []dsl.OptionOrReference{
+							{
+								Option: dsl.Option{
+									Name:     "Name",
+									Type:     "string",
+									Flag:     dsl.FlagDef{Name: "name"},
+									Required: true,
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: globalHeader + `  "github.com/wojnosystems/flick/pkg/generate/dsl"
+)
+
+type Interface interface {
+  HookBefore(ctx context.Context) error
+  HookAfter(ctx context.Context, err error) error
+  Start(ctx context.Context, opts *StartOptions) error
+  CompleteValue(ctx context.Context, commandPath []string, option dsl.Option, partial string) []string
+}
+
+type StartOptions struct {
+  Name string ` + "`" + `flag:"name"` + "`" + `
+}
+
+type Unimplemented struct {
+  HookBefore(_ context.Context) error {
+    return nil
+  }
+  HookAfter(_ context.Context, _ error) error {
+    return nil
+  }
+  Start(_ context.Context, _ *StartOptions) error {
+    return cli.ErrCommandUnimplemented
+  }
+  CompleteValue(_ context.Context, _ []string, _ dsl.Option, _ string) []string {
+    return nil
+  }
+}
+`,
+		},
+		"count and list options": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name: "Verbose",
+							Type: "count",
+							Flag: dsl.FlagDef{Name: "verbose", Aliases: []string{"v"}},
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name:     "Include",
+							Type:     "string",
+							Flag:     dsl.FlagDef{Name: "include", Aliases: []string{"inc", "I"}},
+							List:     true,
+							FilePath: true,
+						},
+					},
+				},
+			},
+			expected: globalHeader + `  "github.com/wojnosystems/go-optional/v2"
+)
+
+type Interface interface {
+  HookBefore(ctx context.Context, opts *AllCommandOptions) error
+  HookAfter(ctx context.Context, opts *AllCommandOptions, err error) error
+}
+
+type AllCommandOptions struct {
+  Verbose optional.Int ` + "`" + `flag:"verbose" flag-short:"v" flag-count:"true"` + "`" + `
+  Include []string ` + "`" + `flag:"include" flag-short:"I" flag-aliases:"inc"` + "`" + `
+}
+
 type Unimplemented struct {
   HookBefore(_ context.Context, _ *AllCommandOptions) error {
     return nil
@@ -241,7 +446,7 @@ type AllCommandOptions struct {
 }
 
 type BarOptions struct {
-  AllCommand AllCommandOptions
+  AllCommandOptions
   barOption optional.Duration
 }
 
@@ -256,6 +461,149 @@ type Unimplemented struct {
     return cli.ErrCommandUnimplemented
   }
 }
+`,
+		},
+		"sub-command does not inherit local global option": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name: "puppy",
+							Type: "int",
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name:  "kitten",
+							Type:  "bool",
+							Scope: dsl.ScopeLocal,
+						},
+					},
+				},
+				Commands: dsl.NamedCommands{
+					"bar": dsl.Command{},
+				},
+			},
+			expected: globalHeader + `  "github.com/wojnosystems/go-optional/v2"
+)
+
+type Interface interface {
+  HookBefore(ctx context.Context, opts *AllCommandOptions) error
+  HookAfter(ctx context.Context, opts *AllCommandOptions, err error) error
+  Bar(ctx context.Context, opts *AllCommandPersistentOptions) error
+}
+
+type AllCommandPersistentOptions struct {
+  puppy optional.Int
+}
+
+type AllCommandOptions struct {
+  AllCommandPersistentOptions
+  kitten optional.Bool
+}
+
+type Unimplemented struct {
+  HookBefore(_ context.Context, _ *AllCommandOptions) error {
+    return nil
+  }
+  HookAfter(_ context.Context, _ *AllCommandOptions, _ error) error {
+    return nil
+  }
+  Bar(_ context.Context, _ *AllCommandPersistentOptions) error {
+    return cli.ErrCommandUnimplemented
+  }
+}
+`,
+		},
+		"commands with sub-commands get persistent and local structs": {
+			input: dsl.Document{
+				Commands: dsl.NamedCommands{
+					"server": dsl.Command{
+						Options: []dsl.OptionOrReference{
+							{
+								Option: dsl.Option{
+									Name:  "Region",
+									Type:  "string",
+									Scope: dsl.ScopePersistent,
+								},
+							},
+							{
+								Option: dsl.Option{
+									Name:  "Dry",
+									Type:  "bool",
+									Scope: dsl.ScopeLocal,
+								},
+							},
+						},
+						Commands: dsl.NamedCommands{
+							"db": dsl.Command{
+								Commands: dsl.NamedCommands{
+									"migrate": dsl.Command{
+										Options: []dsl.OptionOrReference{
+											{
+												Option: dsl.Option{
+													Name: "Steps",
+													Type: "int",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: globalHeader + `  "github.com/wojnosystems/go-optional/v2"
+)
+
+type Interface interface {
+  HookBefore(ctx context.Context) error
+  HookAfter(ctx context.Context, err error) error
+  ServerHookBefore(ctx context.Context, opts *ServerOptions) error
+  ServerHookAfter(ctx context.Context, opts *ServerOptions, err error) error
+  ServerDbHookBefore(ctx context.Context, opts *ServerPersistentOptions) error
+  ServerDbHookAfter(ctx context.Context, opts *ServerPersistentOptions, err error) error
+  ServerDbMigrate(ctx context.Context, opts *ServerDbMigrateOptions) error
+}
+
+type ServerPersistentOptions struct {
+  Region optional.String
+}
+
+type ServerOptions struct {
+  ServerPersistentOptions
+  Dry optional.Bool
+}
+
+type ServerDbMigrateOptions struct {
+  ServerPersistentOptions
+  Steps optional.Int
+}
+
+type Unimplemented struct {
+  HookBefore(_ context.Context) error {
+    return nil
+  }
+  HookAfter(_ context.Context, _ error) error {
+    return nil
+  }
+  ServerHookBefore(_ context.Context, _ *ServerOptions) error {
+    return nil
+  }
+  ServerHookAfter(_ context.Context, _ *ServerOptions, _ error) error {
+    return nil
+  }
+  ServerDbHookBefore(_ context.Context, _ *ServerPersistentOptions) error {
+    return nil
+  }
+  ServerDbHookAfter(_ context.Context, _ *ServerPersistentOptions, _ error) error {
+    return nil
+  }
+  ServerDbMigrate(_ context.Context, _ *ServerDbMigrateOptions) error {
+    return cli.ErrCommandUnimplemented
+  }
+}
 `,
 		},
 	}
@@ -270,3 +618,109 @@ type Unimplemented struct {
 		})
 	}
 }
+
+// TestGoLang_GenerateParses builds the generated options struct with reflect, as the generated code is not compiled
+// here, and checks that the parser reads it by the flags of the document
+func TestGoLang_GenerateParses(t *testing.T) {
+	document := dsl.Document{
+		Options: []dsl.OptionOrReference{
+			{
+				Option: dsl.Option{
+					Name: "Verbose",
+					Type: "count",
+					Flag: dsl.FlagDef{Name: "verbose", Aliases: []string{"v"}},
+				},
+			},
+			{
+				Option: dsl.Option{
+					Name:     "Include",
+					Type:     "string",
+					Flag:     dsl.FlagDef{Name: "include", Aliases: []string{"I", "inc", "i"}},
+					List:     true,
+					FilePath: true,
+				},
+			},
+		},
+	}
+	g := GoLang{}
+	generated := bytes.Buffer{}
+	_, err := g.Generate(context.TODO(), &document, &generated)
+	require.NoError(t, err)
+
+	structBody := regexp.MustCompile("(?s)type AllCommandOptions struct {\n(.*?)\n}").FindStringSubmatch(generated.String())
+	require.Len(t, structBody, 2)
+	fieldTypes := map[string]reflect.Type{
+		"optional.Int": reflect.TypeOf(optional.Int{}),
+		"[]string":     reflect.TypeOf([]string{}),
+	}
+	var fields []reflect.StructField
+	for _, field := range regexp.MustCompile("(?m)^  (\\w+) (\\S+) `(.*)`$").FindAllStringSubmatch(structBody[1], -1) {
+		fieldType, ok := fieldTypes[field[2]]
+		require.True(t, ok, "unexpected field type %s", field[2])
+		fields = append(fields, reflect.StructField{Name: field[1], Type: fieldType, Tag: reflect.StructTag(field[3])})
+	}
+	require.Len(t, fields, 2)
+	optionsType := reflect.StructOf(fields)
+
+	service := &cmd_definitions.ServiceDesc{
+		Root: cmd_definitions.MethodDesc{
+			ObjectMaker: func() interface{} {
+				return reflect.New(optionsType).Interface()
+			},
+		},
+	}
+	exec, err := parse.NewEnvFlagParser(service, &parse.BeforeAndAfter{
+		Flags: parse.GroupFlags(),
+	}, []string{"-vvv", "--include", "a.yaml", "--include=b.yaml", "-Ic.yaml", "--inc", "d.yaml", "-ie.yaml"}).Parse(nil)
+	require.NoError(t, err)
+	options := reflect.ValueOf(exec.Options()[0]).Elem()
+	assert.Equal(t, optional.IntFrom(3), options.FieldByName("Verbose").Interface())
+	assert.Equal(t, []string{"a.yaml", "b.yaml", "c.yaml", "d.yaml", "e.yaml"}, options.FieldByName("Include").Interface())
+}
+
+func TestGoLang_GenerateErrors(t *testing.T) {
+	position := dsl.Position{File: "cli.yaml", Line: 7, Column: 5}
+	cases := map[string]struct {
+		input       dsl.Document
+		expectedErr string
+	}{
+		"unsupported type": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name:     "key1",
+							Type:     "color",
+							Position: position,
+						},
+					},
+				},
+			},
+			expectedErr: `invalid value at cli.yaml:7:5: unsupported option type: "color"`,
+		},
+		"default and required": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name:     "key1",
+							Type:     "int",
+							Default:  optional.StringFrom("3"),
+							Required: true,
+							Position: position,
+						},
+					},
+				},
+			},
+			expectedErr: `invalid value at cli.yaml:7:5: option at "/key1" cannot have a default value and also be required`,
+		},
+	}
+
+	g := GoLang{}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			_, err := g.Generate(context.TODO(), &c.input, &bytes.Buffer{})
+			assert.EqualError(t, err, c.expectedErr)
+		})
+	}
+}
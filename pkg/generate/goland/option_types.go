@@ -1,6 +1,7 @@
 package goland
 
 import (
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
 	"strings"
 )
 
@@ -37,5 +38,13 @@ func registerOptionalTypes(registry optionTypeRegistry) optionTypeRegistry {
 			OptionalType: "optional." + strings.Title(s),
 		}
 	}
+	registry[dsl.TypeCount] = optionType{
+		Import: goImport{},
+		ImportOptional: goImport{
+			Path: goOptionalLibraryImportPath,
+		},
+		Type:         "int",
+		OptionalType: "optional.Int",
+	}
 	return registry
 }
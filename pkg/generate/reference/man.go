@@ -0,0 +1,237 @@
+package reference
+
+import (
+	"context"
+	"fmt"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"strings"
+)
+
+const manSection = "1"
+
+type man struct {
+	programName string
+	commandPath []string
+}
+
+// Man generates the roff man page for the command at commandPath, or the program itself when commandPath is empty.
+// The page is named after the command path: myapp-server-start(1)
+func Man(programName string, commandPath ...string) generate.Maker {
+	return &man{
+		programName: programName,
+		commandPath: commandPath,
+	}
+}
+
+// ManPages generates a man page for the program and every command. create is called with the name of each page,
+// e.g. "myapp-server-start.1", the page is written to it and then it is closed
+func ManPages(ctx context.Context, programName string, document *dsl.Document, create func(fileName string) (io.WriteCloser, error)) (err error) {
+	for _, p := range collectPages(programName, document) {
+		var output io.WriteCloser
+		output, err = create(p.pageName() + "." + manSection)
+		if err != nil {
+			return
+		}
+		err = Man(programName, p.path...).Generate(ctx, document, output)
+		closeErr := output.Close()
+		if err != nil {
+			return
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return
+}
+
+func (m *man) Generate(_ context.Context, document *dsl.Document, output io.Writer) (err error) {
+	p, ok := findPage(m.programName, document, m.commandPath)
+	if !ok {
+		return fmt.Errorf(`command "%s" is not defined`, strings.Join(m.commandPath, " "))
+	}
+	out := string_writer.New(output, "")
+	err = out.WriteLnF(".TH %s %s", roffEscape(strings.ToUpper(p.pageName())), manSection)
+	if err != nil {
+		return
+	}
+	err = writeRoffSection(out, "NAME", func() error {
+		name := roffEscape(p.pageName())
+		if summary := p.summary(); summary != "" {
+			name += ` \- ` + roffEscape(summary)
+		}
+		return out.WriteLn(name)
+	})
+	if err != nil {
+		return
+	}
+	err = writeRoffSection(out, "SYNOPSIS", func() error {
+		return out.WriteLn(roffText(p.synopsis()))
+	})
+	if err != nil {
+		return
+	}
+	if p.description.IsSet() {
+		err = writeRoffSection(out, "DESCRIPTION", func() (err error) {
+			p.description.IfSet(func(description string) {
+				err = out.WriteLn(roffText(strings.TrimSpace(description)))
+			})
+			return
+		})
+		if err != nil {
+			return
+		}
+	}
+	if len(p.options) != 0 {
+		err = writeRoffSection(out, "OPTIONS", func() (err error) {
+			for _, option := range p.options {
+				err = writeRoffOption(out, option)
+				if err != nil {
+					return
+				}
+			}
+			return
+		})
+		if err != nil {
+			return
+		}
+	}
+	if len(p.commands) != 0 {
+		err = writeRoffSection(out, "COMMANDS", func() (err error) {
+			for _, command := range p.commands {
+				err = out.WriteLn(".TP")
+				if err != nil {
+					return
+				}
+				err = out.WriteLn(".B " + roffEscape(strings.Join(append([]string{command.name}, command.aliases...), ", ")))
+				if err != nil {
+					return
+				}
+				if summary := command.summary(); summary != "" {
+					err = out.WriteLn(roffText(summary))
+					if err != nil {
+						return
+					}
+				}
+			}
+			return
+		})
+		if err != nil {
+			return
+		}
+	}
+	seeAlso := make([]string, 0, len(p.commands)+1)
+	if parent := p.parentPageName(); parent != "" {
+		seeAlso = append(seeAlso, parent)
+	}
+	for _, command := range p.commands {
+		seeAlso = append(seeAlso, p.pageName()+"-"+command.name)
+	}
+	if len(seeAlso) != 0 {
+		err = writeRoffSection(out, "SEE ALSO", func() (err error) {
+			for i, name := range seeAlso {
+				separator := ","
+				if i == len(seeAlso)-1 {
+					separator = ""
+				}
+				err = out.WriteLnF(".BR %s (%s)%s", roffEscape(name), manSection, separator)
+				if err != nil {
+					return
+				}
+			}
+			return
+		})
+	}
+	return
+}
+
+func writeRoffSection(out *string_writer.Type, title string, body func() error) (err error) {
+	err = out.WriteLn(".SH " + title)
+	if err != nil {
+		return
+	}
+	return body()
+}
+
+func writeRoffOption(out *string_writer.Type, option dsl.Option) (err error) {
+	err = out.WriteLn(".TP")
+	if err != nil {
+		return
+	}
+	names := option.Flag.Names()
+	if len(names) != 0 {
+		escaped := make([]string, len(names))
+		for i, name := range names {
+			escaped[i] = roffEscape(name)
+		}
+		heading := `\fB` + strings.Join(escaped, `\fR, \fB`) + `\fR`
+		option.Usage.IfSet(func(usage string) {
+			heading += `=\fI` + roffEscape(usage) + `\fR`
+		})
+		err = out.WriteLn(heading)
+	} else {
+		err = out.WriteLn(`\fB` + roffEscape(option.Name) + `\fR`)
+	}
+	if err != nil {
+		return
+	}
+	option.Description.IfSet(func(description string) {
+		err = out.WriteLn(roffText(strings.TrimSpace(description)))
+	})
+	if err != nil {
+		return
+	}
+	details := optionDetails(option)
+	if len(details) != 0 {
+		err = out.WriteLn(".br")
+		if err != nil {
+			return
+		}
+		err = out.WriteLn(roffText(strings.Join(details, " ")))
+	}
+	return
+}
+
+// optionDetails are the sentences describing the requirements, default, environment variable and deprecation of an option
+func optionDetails(option dsl.Option) (out []string) {
+	if option.Required {
+		out = append(out, "Required.")
+	}
+	option.Default.IfSet(func(value string) {
+		out = append(out, "Default: "+value+".")
+	})
+	if len(option.Enum) != 0 {
+		out = append(out, "One of: "+strings.Join(option.Enum, ", ")+".")
+	}
+	if option.Type == dsl.TypeCount || option.List {
+		out = append(out, "May be given more than once.")
+	}
+	if option.Env.Name != "" {
+		out = append(out, "Environment: "+option.Env.Name+".")
+	}
+	if option.Deprecated != "" {
+		out = append(out, "Deprecated: "+option.Deprecated+".")
+	}
+	return
+}
+
+// roffEscape escapes characters that have a special meaning within a line of roff
+func roffEscape(v string) string {
+	v = strings.Replace(v, `\`, `\e`, -1)
+	return strings.Replace(v, "-", `\-`, -1)
+}
+
+// roffText escapes a block of text, making sure no line is mistaken for a roff request
+func roffText(v string) string {
+	lines := strings.Split(v, "\n")
+	for i, line := range lines {
+		line = roffEscape(line)
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = `\&` + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
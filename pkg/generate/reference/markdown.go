@@ -0,0 +1,127 @@
+package reference
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"regexp"
+	"strings"
+)
+
+type markdown struct {
+	programName string
+}
+
+// Markdown generates a single Markdown document describing the program and every one of its commands
+func Markdown(programName string) generate.Maker {
+	return &markdown{
+		programName: programName,
+	}
+}
+
+func (m *markdown) Generate(_ context.Context, document *dsl.Document, output io.Writer) (err error) {
+	out := string_writer.New(output, "")
+	err = out.Write2Ln("# " + m.programName + " command reference")
+	if err != nil {
+		return
+	}
+	for _, p := range collectPages(m.programName, document) {
+		err = writeMarkdownPage(out, p)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeMarkdownPage(out *string_writer.Type, p page) (err error) {
+	err = out.Write2Ln("## " + p.commandLine())
+	if err != nil {
+		return
+	}
+	err = out.Write2Ln("```\n" + p.synopsis() + "\n```")
+	if err != nil {
+		return
+	}
+	p.description.IfSet(func(description string) {
+		err = out.Write2Ln(strings.TrimSpace(description))
+	})
+	if err != nil {
+		return
+	}
+	if len(p.options) != 0 {
+		err = out.Write2Ln("### Options")
+		if err != nil {
+			return
+		}
+		for _, option := range p.options {
+			err = out.WriteLn(markdownOption(option))
+			if err != nil {
+				return
+			}
+		}
+		err = out.WriteLn("")
+		if err != nil {
+			return
+		}
+	}
+	if len(p.commands) != 0 {
+		err = out.Write2Ln("### Commands")
+		if err != nil {
+			return
+		}
+		for _, command := range p.commands {
+			target := p.commandLine() + " " + command.name
+			line := "* [" + command.name + "](#" + markdownAnchor(target) + ")"
+			if len(command.aliases) != 0 {
+				line += " (aliases: " + strings.Join(command.aliases, ", ") + ")"
+			}
+			if summary := command.summary(); summary != "" {
+				line += ": " + summary
+			}
+			err = out.WriteLn(line)
+			if err != nil {
+				return
+			}
+		}
+		err = out.WriteLn("")
+	}
+	return
+}
+
+func markdownOption(option dsl.Option) string {
+	names := option.Flag.Names()
+	quoted := make([]string, 0, len(names))
+	if len(names) != 0 {
+		quoted = append(quoted, "`"+flagWithUsage(option)+"`")
+		for _, name := range names[1:] {
+			quoted = append(quoted, "`"+name+"`")
+		}
+	} else {
+		quoted = append(quoted, "`"+option.Name+"`")
+	}
+	line := "* " + strings.Join(quoted, ", ")
+	sentences := make([]string, 0, 4)
+	option.Description.IfSet(func(description string) {
+		sentence := strings.Join(strings.Fields(description), " ")
+		if !strings.HasSuffix(sentence, ".") {
+			sentence += "."
+		}
+		sentences = append(sentences, sentence)
+	})
+	sentences = append(sentences, optionDetails(option)...)
+	if len(sentences) != 0 {
+		line += ": " + strings.Join(sentences, " ")
+	}
+	return line
+}
+
+var markdownAnchorRemove = regexp.MustCompile(`[^a-z0-9 _-]`)
+
+// markdownAnchor converts a heading into the anchor GitHub generates for it
+func markdownAnchor(heading string) string {
+	anchor := markdownAnchorRemove.ReplaceAllString(strings.ToLower(heading), "")
+	return strings.Replace(anchor, " ", "-", -1)
+}
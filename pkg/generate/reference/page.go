@@ -0,0 +1,185 @@
+package reference
+
+import (
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/go-optional/v2"
+	"sort"
+	"strings"
+)
+
+// page is everything documented about a single command path
+type page struct {
+	programName string
+	// path is the list of command names after the program name, empty for the root
+	path        []string
+	usage       optional.String
+	description optional.String
+	options     []dsl.Option
+	commands    []subCommand
+	minArgs     uint
+	maxArgs     uint
+}
+
+type subCommand struct {
+	name        string
+	aliases     []string
+	description optional.String
+	deprecated  string
+}
+
+// summary is the first line of the description, noting when the command is deprecated
+func (c subCommand) summary() (out string) {
+	c.description.IfSet(func(description string) {
+		out = firstLine(description)
+	})
+	if c.deprecated != "" {
+		out = strings.TrimSpace(out + " (deprecated: " + c.deprecated + ")")
+	}
+	return
+}
+
+// collectPages lists the root page followed by the page for every command, depth-first with siblings in name order.
+// Hidden commands, and their sub-commands, have no page
+func collectPages(programName string, document *dsl.Document) (out []page) {
+	out = append(out, page{
+		programName: programName,
+		options:     optionsOf(document, []string{}),
+		commands:    subCommandsOf(document.Commands),
+		minArgs:     document.MinArgs,
+		maxArgs:     document.MaxArgs,
+	})
+	collectPagesRecursive(programName, document, document.Commands, []string{}, &out)
+	return
+}
+
+func collectPagesRecursive(programName string, document *dsl.Document, commands dsl.NamedCommands, parentPath []string, out *[]page) {
+	for _, name := range sortedCommandNames(commands) {
+		command := commands[name]
+		if command.Hidden {
+			continue
+		}
+		path := append(append(make([]string, 0, len(parentPath)+1), parentPath...), name)
+		*out = append(*out, page{
+			programName: programName,
+			path:        path,
+			usage:       command.Usage,
+			description: command.Description,
+			options:     optionsOf(document, path),
+			commands:    subCommandsOf(command.Commands),
+			minArgs:     command.MinArgs,
+			maxArgs:     command.MaxArgs,
+		})
+		collectPagesRecursive(programName, document, command.Commands, path, out)
+	}
+}
+
+// findPage gets the page for the command at path
+func findPage(programName string, document *dsl.Document, path []string) (p page, ok bool) {
+	for _, p = range collectPages(programName, document) {
+		if strings.Join(p.path, " ") == strings.Join(path, " ") {
+			return p, true
+		}
+	}
+	return page{}, false
+}
+
+// optionsOf lists the options documented for the command at path, which are those it accepts, less hidden options
+func optionsOf(document *dsl.Document, path []string) (out []dsl.Option) {
+	accepted, _ := document.AcceptedOptions(path)
+	out = make([]dsl.Option, 0, len(accepted))
+	for _, option := range accepted {
+		if !option.Hidden {
+			out = append(out, option)
+		}
+	}
+	return
+}
+
+// subCommandsOf lists the commands that are not hidden
+func subCommandsOf(commands dsl.NamedCommands) (out []subCommand) {
+	for _, name := range sortedCommandNames(commands) {
+		command := commands[name]
+		if command.Hidden {
+			continue
+		}
+		out = append(out, subCommand{
+			name:        name,
+			aliases:     command.Aliases,
+			description: command.Description,
+			deprecated:  command.Deprecated,
+		})
+	}
+	return
+}
+
+func sortedCommandNames(commands dsl.NamedCommands) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// commandLine is how the command is typed: myapp server start
+func (p page) commandLine() string {
+	return strings.Join(append([]string{p.programName}, p.path...), " ")
+}
+
+// pageName is the name of the man page: myapp-server-start
+func (p page) pageName() string {
+	return strings.Join(append([]string{p.programName}, p.path...), "-")
+}
+
+// parentPageName is the name of the man page of the parent command or blank for the root
+func (p page) parentPageName() string {
+	if len(p.path) == 0 {
+		return ""
+	}
+	return strings.Join(append([]string{p.programName}, p.path[0:len(p.path)-1]...), "-")
+}
+
+// synopsis uses the command usage if provided, otherwise one is built from the options, commands and arguments
+func (p page) synopsis() string {
+	usage := ""
+	p.usage.IfSetElse(func(value string) {
+		usage = value
+	}, func() {
+		parts := make([]string, 0, 3)
+		if len(p.options) != 0 {
+			parts = append(parts, "[OPTIONS]")
+		}
+		if len(p.commands) != 0 {
+			parts = append(parts, "COMMAND")
+		} else if p.maxArgs != 0 || p.minArgs != 0 {
+			parts = append(parts, "ARGS...")
+		}
+		usage = strings.Join(parts, " ")
+	})
+	return strings.TrimSpace(p.commandLine() + " " + usage)
+}
+
+// summary is the first line of the description
+func (p page) summary() (out string) {
+	p.description.IfSet(func(value string) {
+		out = firstLine(value)
+	})
+	return
+}
+
+func firstLine(v string) string {
+	return strings.SplitN(strings.TrimSpace(v), "\n", 2)[0]
+}
+
+// flagWithUsage is the first flag name with the option usage appended: --connectTimeout=Ns
+func flagWithUsage(option dsl.Option) string {
+	names := option.Flag.Names()
+	if len(names) == 0 {
+		return ""
+	}
+	out := names[0]
+	option.Usage.IfSet(func(usage string) {
+		out += "=" + usage
+	})
+	return out
+}
@@ -0,0 +1,233 @@
+package reference
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/go-optional/v2"
+	"io"
+	"testing"
+)
+
+var testDocument = dsl.Document{
+	Options: []dsl.OptionOrReference{
+		{
+			Option: dsl.Option{
+				Name:        "profile",
+				Type:        "string",
+				Description: optional.StringFrom("the profile to use"),
+				Usage:       optional.StringFrom("NAME"),
+				Env:         dsl.EnvDef{Name: "PROFILE"},
+				Flag: dsl.FlagDef{
+					Name:    "profile",
+					Aliases: []string{"p"},
+				},
+				Required: true,
+			},
+		},
+		{
+			Option: dsl.Option{
+				Name:        "color",
+				Type:        "bool",
+				Description: optional.StringFrom("colors the output"),
+				Flag:        dsl.FlagDef{Name: "color"},
+				Scope:       dsl.ScopeLocal,
+				Deprecated:  "use --theme instead",
+			},
+		},
+		{
+			Option: dsl.Option{
+				Name:   "trace",
+				Type:   "bool",
+				Flag:   dsl.FlagDef{Name: "trace"},
+				Hidden: true,
+			},
+		},
+	},
+	Commands: dsl.NamedCommands{
+		"debug": dsl.Command{
+			Description: optional.StringFrom("dumps internal state"),
+			Hidden:      true,
+		},
+		"server": dsl.Command{
+			Description: optional.StringFrom("manage the server"),
+			Aliases:     []string{"srv"},
+			Commands: dsl.NamedCommands{
+				"start": dsl.Command{
+					Usage:       optional.StringFrom("[--connectTimeout=Ns] HOST"),
+					Description: optional.StringFrom("starts the server\n.and connects to HOST"),
+					Options: []dsl.OptionOrReference{
+						{
+							Option: dsl.Option{
+								Name:        "ConnectTimeout",
+								Type:        "duration",
+								Description: optional.StringFrom("how long to wait when connecting to the server"),
+								Usage:       optional.StringFrom("Ns"),
+								Env:         dsl.EnvDef{Name: "CONNECT_TIMEOUT"},
+								Flag:        dsl.FlagDef{Name: "connectTimeout"},
+								Default:     optional.StringFrom("30s"),
+							},
+						},
+					},
+					MinArgs: 1,
+					MaxArgs: 1,
+				},
+			},
+		},
+	},
+}
+
+func TestMakers_Generate(t *testing.T) {
+	cases := map[string]struct {
+		maker       generate.Maker
+		expected    string
+		expectedErr string
+	}{
+		"man root": {
+			maker: Man("myapp"),
+			expected: `.TH MYAPP 1
+.SH NAME
+myapp
+.SH SYNOPSIS
+myapp [OPTIONS] COMMAND
+.SH OPTIONS
+.TP
+\fB\-\-profile\fR, \fB\-p\fR=\fINAME\fR
+the profile to use
+.br
+Required. Environment: PROFILE.
+.TP
+\fB\-\-color\fR
+colors the output
+.br
+Deprecated: use \-\-theme instead.
+.SH COMMANDS
+.TP
+.B server, srv
+manage the server
+.SH SEE ALSO
+.BR myapp\-server (1)
+`,
+		},
+		"man sub-command": {
+			maker: Man("myapp", "server", "start"),
+			expected: `.TH MYAPP\-SERVER\-START 1
+.SH NAME
+myapp\-server\-start \- starts the server
+.SH SYNOPSIS
+myapp server start [\-\-connectTimeout=Ns] HOST
+.SH DESCRIPTION
+starts the server
+\&.and connects to HOST
+.SH OPTIONS
+.TP
+\fB\-\-connectTimeout\fR=\fINs\fR
+how long to wait when connecting to the server
+.br
+Default: 30s. Environment: CONNECT_TIMEOUT.
+.TP
+\fB\-\-profile\fR, \fB\-p\fR=\fINAME\fR
+the profile to use
+.br
+Required. Environment: PROFILE.
+.SH SEE ALSO
+.BR myapp\-server (1)
+`,
+		},
+		"man undefined command": {
+			maker:       Man("myapp", "client"),
+			expectedErr: `command "client" is not defined`,
+		},
+		"man hidden command": {
+			maker:       Man("myapp", "debug"),
+			expectedErr: `command "debug" is not defined`,
+		},
+		"markdown": {
+			maker: Markdown("myapp"),
+			expected: "# myapp command reference\n" +
+				"\n" +
+				"## myapp\n" +
+				"\n" +
+				"```\n" +
+				"myapp [OPTIONS] COMMAND\n" +
+				"```\n" +
+				"\n" +
+				"### Options\n" +
+				"\n" +
+				"* `--profile=NAME`, `-p`: the profile to use. Required. Environment: PROFILE.\n" +
+				"* `--color`: colors the output. Deprecated: use --theme instead.\n" +
+				"\n" +
+				"### Commands\n" +
+				"\n" +
+				"* [server](#myapp-server) (aliases: srv): manage the server\n" +
+				"\n" +
+				"## myapp server\n" +
+				"\n" +
+				"```\n" +
+				"myapp server [OPTIONS] COMMAND\n" +
+				"```\n" +
+				"\n" +
+				"manage the server\n" +
+				"\n" +
+				"### Options\n" +
+				"\n" +
+				"* `--profile=NAME`, `-p`: the profile to use. Required. Environment: PROFILE.\n" +
+				"\n" +
+				"### Commands\n" +
+				"\n" +
+				"* [start](#myapp-server-start): starts the server\n" +
+				"\n" +
+				"## myapp server start\n" +
+				"\n" +
+				"```\n" +
+				"myapp server start [--connectTimeout=Ns] HOST\n" +
+				"```\n" +
+				"\n" +
+				"starts the server\n" +
+				".and connects to HOST\n" +
+				"\n" +
+				"### Options\n" +
+				"\n" +
+				"* `--connectTimeout=Ns`: how long to wait when connecting to the server. Default: 30s. Environment: CONNECT_TIMEOUT.\n" +
+				"* `--profile=NAME`, `-p`: the profile to use. Required. Environment: PROFILE.\n" +
+				"\n",
+		},
+	}
+
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := bytes.Buffer{}
+			err := c.maker.Generate(context.TODO(), &testDocument, &actual)
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual.String())
+		})
+	}
+}
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+func TestManPages(t *testing.T) {
+	pages := make(map[string]*bufferCloser)
+	err := ManPages(context.TODO(), "myapp", &testDocument, func(fileName string) (io.WriteCloser, error) {
+		pages[fileName] = &bufferCloser{}
+		return pages[fileName], nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, pages, 3)
+	assert.Contains(t, pages, "myapp.1")
+	assert.Contains(t, pages, "myapp-server.1")
+	assert.Contains(t, pages, "myapp-server-start.1")
+}
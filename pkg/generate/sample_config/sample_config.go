@@ -0,0 +1,179 @@
+package sample_config
+
+import (
+	"context"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/string_writer"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const singleIndent = `  `
+
+// SampleConfig generates a commented YAML configuration file that lists every option that can be read from the
+// configuration file. The file is flat, as parse.ConfigFileSource reads the whole file into the options of the root and
+// of each command alike: the options of the root come first, then those of each command, under a comment naming it. A
+// key that several commands read is only listed for the first of them
+type SampleConfig struct {
+}
+
+func (s *SampleConfig) Generate(_ context.Context, document *dsl.Document, output io.Writer) (err error) {
+	out := string_writer.New(output, singleIndent)
+	err = out.WriteLn("# Sample configuration generated from the optionapi specification")
+	if err != nil {
+		return
+	}
+	err = out.WriteLn("---")
+	if err != nil {
+		return
+	}
+	written := make(map[string]bool)
+	err = writeOptions(out, document.Options, written)
+	if err != nil {
+		return
+	}
+	return writeCommands(out, document.Commands, nil, written)
+}
+
+func writeCommands(out *string_writer.Type, commands dsl.NamedCommands, parentPath []string, written map[string]bool) (err error) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command := commands[name]
+		path := append(append(make([]string, 0, len(parentPath)+1), parentPath...), name)
+		if hasNewFileKeys(command.Options, written) {
+			err = out.Write2Ln(`# options of "` + strings.Join(path, " ") + `"`)
+			if err != nil {
+				return
+			}
+			err = writeOptions(out, command.Options, written)
+			if err != nil {
+				return
+			}
+		}
+		err = writeCommands(out, command.Commands, path, written)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// writeOptions writes the options with a file key that is not in written yet, and adds their keys to it
+func writeOptions(out *string_writer.Type, options []dsl.OptionOrReference, written map[string]bool) (err error) {
+	for _, option := range options {
+		if !hasFileKey(option.Option) || written[option.File.Name] {
+			continue
+		}
+		written[option.File.Name] = true
+		err = writeOption(out, option.Option)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeOption(out *string_writer.Type, option dsl.Option) (err error) {
+	comments := make([]string, 0, 6)
+	option.Description.IfSet(func(description string) {
+		for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+			comments = append(comments, line)
+		}
+	})
+	if option.List {
+		comments = append(comments, "type: list of "+option.Type)
+	} else {
+		comments = append(comments, "type: "+option.Type)
+	}
+	option.Default.IfSet(func(value string) {
+		comments = append(comments, "default: "+value)
+	})
+	if option.Required {
+		comments = append(comments, "required: true")
+	}
+	if !isBlank(option.Env.Name) {
+		comments = append(comments, "env: "+option.Env.Name)
+	}
+	if flagNames := option.Flag.Names(); len(flagNames) != 0 {
+		comments = append(comments, "flag: "+strings.Join(flagNames, ", "))
+	}
+	for _, comment := range comments {
+		err = out.WriteLn(strings.TrimRight("# "+comment, " "))
+		if err != nil {
+			return
+		}
+	}
+
+	option.Default.IfSetElse(func(value string) {
+		formatted := formatValue(option.Type, value)
+		if option.List {
+			formatted = "[" + formatted + "]"
+		}
+		err = out.Write2Ln(option.File.Name + ": " + formatted)
+	}, func() {
+		if option.Required {
+			// a value of the right type, so that the sample still matches the config schema
+			err = out.Write2Ln(option.File.Name + ": " + placeholderValue(option))
+		} else {
+			// commented out so that the sample does not set a value for the option
+			err = out.Write2Ln("# " + option.File.Name + ":")
+		}
+	})
+	return
+}
+
+// placeholderValue is a value of the type of option for the sample to hold until it is replaced, the first value of its
+// enum when it has one
+func placeholderValue(option dsl.Option) string {
+	if option.List {
+		return "[]"
+	}
+	if len(option.Enum) != 0 {
+		return formatValue(option.Type, option.Enum[0])
+	}
+	switch option.Type {
+	case "string":
+		return `""`
+	case "bool":
+		return "false"
+	case "float32", "float64":
+		return "0.0"
+	case "duration":
+		return "0s"
+	case "time":
+		return "1970-01-01T00:00:00Z"
+	default:
+		return "0"
+	}
+}
+
+func formatValue(optionType string, value string) string {
+	if optionType == "string" {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// hasNewFileKeys is true when one of options has a file key that is not in written
+func hasNewFileKeys(options []dsl.OptionOrReference, written map[string]bool) bool {
+	for _, option := range options {
+		if hasFileKey(option.Option) && !written[option.File.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFileKey(option dsl.Option) bool {
+	return !isBlank(option.File.Name)
+}
+
+func isBlank(v string) bool {
+	return len(v) == 0
+}
@@ -0,0 +1,322 @@
+package sample_config
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wojnosystems/flick/parse"
+	"github.com/wojnosystems/flick/pkg/generate"
+	"github.com/wojnosystems/flick/pkg/generate/dsl"
+	"github.com/wojnosystems/flick/pkg/generate/goland"
+	flag_unmarshaler "github.com/wojnosystems/go-flag-unmarshaler"
+	"github.com/wojnosystems/go-optional/v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleConfig_Generate(t *testing.T) {
+	header := `# Sample configuration generated from the optionapi specification
+---
+`
+	cases := map[string]struct {
+		input    dsl.Document
+		expected string
+	}{
+		"empty": {
+			input:    dsl.Document{},
+			expected: header,
+		},
+		"options without file keys are skipped": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name: "profile",
+							Type: "string",
+							Env:  dsl.EnvDef{Name: "PROFILE"},
+						},
+					},
+				},
+				Commands: dsl.NamedCommands{
+					"server": dsl.Command{},
+				},
+			},
+			expected: header,
+		},
+		"global options": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name:        "profile",
+							Type:        "string",
+							Description: optional.StringFrom("the profile to use"),
+							Env:         dsl.EnvDef{Name: "PROFILE"},
+							Flag: dsl.FlagDef{
+								Name:    "profile",
+								Aliases: []string{"p"},
+							},
+							File:     dsl.FileDef{Name: "profile"},
+							Required: true,
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name:    "retries",
+							Type:    "int",
+							File:    dsl.FileDef{Name: "retries"},
+							Default: optional.StringFrom("3"),
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name: "host",
+							Type: "string",
+							File: dsl.FileDef{Name: "host"},
+						},
+					},
+				},
+			},
+			expected: header + `# the profile to use
+# type: string
+# required: true
+# env: PROFILE
+# flag: --profile, -p
+profile: ""
+
+# type: int
+# default: 3
+retries: 3
+
+# type: string
+# host:
+
+`,
+		},
+		"list options": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name:    "include",
+							Type:    "string",
+							File:    dsl.FileDef{Name: "include"},
+							List:    true,
+							Default: optional.StringFrom("/usr/include"),
+						},
+					},
+				},
+			},
+			expected: header + `# type: list of string
+# default: /usr/include
+include: ["/usr/include"]
+
+`,
+		},
+		"required placeholders": {
+			input: dsl.Document{
+				Options: []dsl.OptionOrReference{
+					{
+						Option: dsl.Option{
+							Name:     "port",
+							Type:     "uint16",
+							File:     dsl.FileDef{Name: "port"},
+							Required: true,
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name:     "mode",
+							Type:     "string",
+							File:     dsl.FileDef{Name: "mode"},
+							Enum:     []string{"fast", "safe"},
+							Required: true,
+						},
+					},
+					{
+						Option: dsl.Option{
+							Name:     "include",
+							Type:     "string",
+							File:     dsl.FileDef{Name: "include"},
+							List:     true,
+							Required: true,
+						},
+					},
+				},
+			},
+			expected: header + `# type: uint16
+# required: true
+port: 0
+
+# type: string
+# required: true
+mode: "fast"
+
+# type: list of string
+# required: true
+include: []
+
+`,
+		},
+		"command options are flat": {
+			input: dsl.Document{
+				Commands: dsl.NamedCommands{
+					"server": dsl.Command{
+						Commands: dsl.NamedCommands{
+							"start": dsl.Command{
+								Options: []dsl.OptionOrReference{
+									{
+										Option: dsl.Option{
+											Name:        "ConnectTimeout",
+											Type:        "duration",
+											Description: optional.StringFrom("how long to wait when connecting to the server"),
+											Env:         dsl.EnvDef{Name: "CONNECT_TIMEOUT"},
+											File:        dsl.FileDef{Name: "connectTimeout"},
+											Default:     optional.StringFrom("30s"),
+										},
+									},
+								},
+							},
+							"stop": dsl.Command{
+								Options: []dsl.OptionOrReference{
+									{
+										Option: dsl.Option{
+											Name: "ConnectTimeout",
+											Type: "duration",
+											File: dsl.FileDef{Name: "connectTimeout"},
+										},
+									},
+									{
+										Option: dsl.Option{
+											Name: "Force",
+											Type: "bool",
+											File: dsl.FileDef{Name: "force"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: header + `# options of "server start"
+
+# how long to wait when connecting to the server
+# type: duration
+# default: 30s
+# env: CONNECT_TIMEOUT
+connectTimeout: 30s
+
+# options of "server stop"
+
+# type: bool
+# force:
+
+`,
+		},
+	}
+
+	var g generate.Maker = &SampleConfig{}
+	for caseName, c := range cases {
+		t.Run(caseName, func(t *testing.T) {
+			actual := bytes.Buffer{}
+			err := g.Generate(context.TODO(), &c.input, &actual)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual.String())
+		})
+	}
+}
+
+// TestSampleConfig_LoadsIntoGeneratedOptions builds the generated options structs with reflect, as the generated code is
+// not compiled here, and checks that the root and each command read the sample from the keys it lists
+func TestSampleConfig_LoadsIntoGeneratedOptions(t *testing.T) {
+	document := dsl.Document{
+		Options: []dsl.OptionOrReference{
+			{Option: dsl.Option{Name: "Host", Type: "string", File: dsl.FileDef{Name: "host"}, Default: optional.StringFrom("localhost")}},
+		},
+		Commands: dsl.NamedCommands{
+			"server": dsl.Command{
+				Commands: dsl.NamedCommands{
+					"start": dsl.Command{
+						Options: []dsl.OptionOrReference{
+							{Option: dsl.Option{Name: "Port", Type: "uint16", File: dsl.FileDef{Name: "port"}, Required: true}},
+							{Option: dsl.Option{Name: "Timeout", Type: "duration", File: dsl.FileDef{Name: "timeout"}, Default: optional.StringFrom("30s")}},
+							{Option: dsl.Option{Name: "Tags", Type: "string", File: dsl.FileDef{Name: "tags"}, List: true, Default: optional.StringFrom("web")}},
+							{Option: dsl.Option{Name: "Debug", Type: "bool", File: dsl.FileDef{Name: "debug"}}},
+						},
+					},
+				},
+			},
+		},
+	}
+	code := bytes.Buffer{}
+	_, err := (&goland.GoLang{}).Generate(context.TODO(), &document, &code)
+	require.NoError(t, err)
+	sample := bytes.Buffer{}
+	require.NoError(t, (&SampleConfig{}).Generate(context.TODO(), &document, &sample))
+	dir, err := ioutil.TempDir("", "sample_config")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, sample.Bytes(), 0600))
+
+	types := map[string]reflect.Type{
+		"string":        reflect.TypeOf(""),
+		"uint16":        reflect.TypeOf(uint16(0)),
+		"time.Duration": reflect.TypeOf(time.Duration(0)),
+		"[]string":      reflect.TypeOf([]string{}),
+		"optional.Bool": reflect.TypeOf(optional.Bool{}),
+	}
+	rootType := generatedStruct(t, code.String(), "AllCommandOptions", types)
+	types["AllCommandOptions"] = rootType
+	startType := generatedStruct(t, code.String(), "ServerStartOptions", types)
+
+	load := func(optionsType reflect.Type) reflect.Value {
+		options := reflect.New(optionsType)
+		err := parse.Precedence{
+			parse.ConfigFileSource(optional.StringFrom(path), parse.Yaml(), true, nil),
+		}.Unmarshal(options.Interface(), flag_unmarshaler.Group{})
+		require.NoError(t, err)
+		return options.Elem()
+	}
+	root := load(rootType)
+	assert.Equal(t, "localhost", root.FieldByName("Host").Interface())
+	start := load(startType)
+	assert.Equal(t, "localhost", start.FieldByName("Host").Interface())
+	assert.Equal(t, uint16(0), start.FieldByName("Port").Interface())
+	assert.Equal(t, 30*time.Second, start.FieldByName("Timeout").Interface())
+	assert.Equal(t, []string{"web"}, start.FieldByName("Tags").Interface())
+	assert.Equal(t, optional.Bool{}, start.FieldByName("Debug").Interface())
+}
+
+// generatedStruct builds the struct called name in code with reflect. Its fields must have one of types, and fields
+// without a name embed one of types
+func generatedStruct(t *testing.T, code string, name string, types map[string]reflect.Type) reflect.Type {
+	t.Helper()
+	body := regexp.MustCompile("(?s)type " + name + " struct {\n(.*?)\n}").FindStringSubmatch(code)
+	require.Len(t, body, 2, "missing struct %s in:\n%s", name, code)
+	var fields []reflect.StructField
+	for _, line := range strings.Split(body[1], "\n") {
+		field := regexp.MustCompile("^  (\\w+)(?: (\\S+))?(?: `(.*)`)?$").FindStringSubmatch(line)
+		require.NotNil(t, field, "unexpected field %q", line)
+		if field[2] == "" {
+			embedded, ok := types[field[1]]
+			require.True(t, ok, "unexpected embedded struct %s", field[1])
+			fields = append(fields, reflect.StructField{Name: field[1], Type: embedded, Anonymous: true})
+			continue
+		}
+		fieldType, ok := types[field[2]]
+		require.True(t, ok, "unexpected field type %s", field[2])
+		fields = append(fields, reflect.StructField{Name: field[1], Type: fieldType, Tag: reflect.StructTag(field[3])})
+	}
+	return reflect.StructOf(fields)
+}